@@ -0,0 +1,112 @@
+package history
+
+import (
+	"database/sql"
+	"os"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/andeya/gust/result"
+	"github.com/andeya/pholcus/common/mgo"
+	"github.com/andeya/pholcus/common/mysql"
+	"github.com/andeya/pholcus/common/pool"
+	"github.com/andeya/pholcus/common/util"
+	"github.com/andeya/pholcus/config"
+)
+
+const (
+	CookieSuffix = config.HistoryTag + "__c"
+	CookieFile   = config.HistoryDir + "/" + CookieSuffix
+)
+
+// cookieNames returns the table and file name of a spider's saved cookies.
+func cookieNames(name, subName string) (tabName, fileName string) {
+	tabName, fileName = CookieSuffix+"__"+name, CookieFile+"__"+name
+	if subName != "" {
+		tabName += "__" + subName
+		fileName += "__" + subName
+	}
+	return util.FileNameReplace(tabName), fileName
+}
+
+// ReadCookies returns the cookies saved by WriteCookies for the given
+// spider, or nil if there are none.
+func ReadCookies(provider, name, subName string) (r result.Result[[]byte]) {
+	defer r.Catch()
+	tabName, fileName := cookieNames(name, subName)
+	switch provider {
+	case "mgo":
+		result.RetVoid(mgo.Error()).Unwrap()
+		var doc bson.M
+		mgo.Call(func(src pool.Src) error {
+			c := src.(*mgo.MgoSrc).DB(config.Conf().DBName).C(tabName)
+			c.FindId("cookies").One(&doc)
+			return nil
+		}).Unwrap()
+		s, _ := doc["data"].(string)
+		return result.Ok([]byte(s))
+
+	case "mysql":
+		_, err := mysql.DB()
+		result.RetVoid(err).Unwrap()
+		table, ok := getReadMysqlTable(tabName)
+		if !ok {
+			table = mysql.New().Unwrap().SetTableName(tabName)
+			setReadMysqlTable(tabName, table)
+		}
+		rs := table.SelectAll()
+		if rs.IsErr() {
+			return result.Ok[[]byte](nil) // not saved yet
+		}
+		rows := rs.Unwrap()
+		defer rows.Close()
+		var id, data sql.NullString
+		if rows.Next() {
+			result.RetVoid(rows.Scan(&id, &data)).Unwrap()
+		}
+		return result.Ok([]byte(data.String))
+
+	default:
+		b, err := os.ReadFile(fileName)
+		if os.IsNotExist(err) {
+			return result.Ok[[]byte](nil)
+		}
+		return result.Ret(b, err)
+	}
+}
+
+// WriteCookies replaces the saved cookies of the given spider with data.
+func WriteCookies(provider, name, subName string, data []byte) (r result.VoidResult) {
+	defer r.Catch()
+	tabName, fileName := cookieNames(name, subName)
+	switch provider {
+	case "mgo":
+		result.RetVoid(mgo.Error()).Unwrap()
+		mgo.Call(func(src pool.Src) error {
+			c := src.(*mgo.MgoSrc).DB(config.Conf().DBName).C(tabName)
+			_, err := c.UpsertId("cookies", bson.M{"data": string(data)})
+			return err
+		}).Unwrap()
+
+	case "mysql":
+		_, err := mysql.DB()
+		result.RetVoid(err).Unwrap()
+		table, ok := getWriteMysqlTable(tabName)
+		if !ok {
+			table = mysql.New().Unwrap()
+			table.SetTableName(tabName).CustomPrimaryKey(`id VARCHAR(255) NOT NULL PRIMARY KEY`).AddColumn(`data MEDIUMTEXT`)
+			setWriteMysqlTable(tabName, table)
+			table.Create().Unwrap()
+		}
+		table.Truncate().Unwrap()
+		table.AutoInsert([]string{"cookies", string(data)})
+		table.FlushInsert().Unwrap()
+
+	default:
+		// Session cookies are credentials: keep them private to the user,
+		// also when the file was written by an older version.
+		result.RetVoid(os.WriteFile(fileName, data, 0600)).Unwrap()
+		result.RetVoid(os.Chmod(fileName, 0600)).Unwrap()
+	}
+	return result.OkVoid()
+}
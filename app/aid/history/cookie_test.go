@@ -0,0 +1,31 @@
+package history
+
+import (
+	"os"
+	"testing"
+
+	"github.com/andeya/pholcus/config"
+)
+
+func TestCookies_File(t *testing.T) {
+	cleanup := setupHistoryDir(t)
+	defer cleanup()
+	_ = config.Conf()
+
+	if r := ReadCookies("csv", "spider", "sub"); r.IsErr() || r.Unwrap() != nil {
+		t.Fatalf("ReadCookies before write = %v, want empty", r)
+	}
+	if r := WriteCookies("csv", "spider", "sub", []byte(`[{"name":"sid"}]`)); r.IsErr() {
+		t.Fatalf("WriteCookies: %v", r.UnwrapErr())
+	}
+	if got := ReadCookies("csv", "spider", "sub").Unwrap(); string(got) != `[{"name":"sid"}]` {
+		t.Errorf("ReadCookies = %q", got)
+	}
+	_, fileName := cookieNames("spider", "sub")
+	if fi, err := os.Stat(fileName); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("cookie file mode = %v (%v), want 0600", fi.Mode().Perm(), err)
+	}
+	if got := ReadCookies("csv", "spider", "").Unwrap(); got != nil {
+		t.Errorf("ReadCookies of other instance = %q, want nil", got)
+	}
+}
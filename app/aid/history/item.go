@@ -0,0 +1,201 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/andeya/gust/result"
+	"github.com/andeya/pholcus/common/closer"
+	"github.com/andeya/pholcus/common/mgo"
+	"github.com/andeya/pholcus/common/mysql"
+	"github.com/andeya/pholcus/common/pool"
+	"github.com/andeya/pholcus/common/util"
+	"github.com/andeya/pholcus/config"
+	"github.com/andeya/pholcus/logs"
+)
+
+const (
+	ItemSuffix = config.HistoryTag + "__i"
+	ItemFile   = config.HistoryDir + "/" + ItemSuffix
+)
+
+// Items records the keys of output items with a digest of their content,
+// for the collector to deduplicate items across runs.
+type Items struct {
+	tabName  string
+	fileName string
+	new      map[string]string
+	old      map[string]string
+	sync.RWMutex
+}
+
+// NewItems creates the item records of the given spider name and optional subname.
+func NewItems(name string, subName string) *Items {
+	tabName := ItemSuffix + "__" + name
+	fileName := ItemFile + "__" + name
+	if subName != "" {
+		tabName += "__" + subName
+		fileName += "__" + subName
+	}
+	return &Items{
+		tabName:  util.FileNameReplace(tabName),
+		fileName: fileName,
+		new:      make(map[string]string),
+		old:      make(map[string]string),
+	}
+}
+
+// Get returns the digest recorded for key.
+func (s *Items) Get(key string) (digest string, ok bool) {
+	s.RWMutex.RLock()
+	defer s.RWMutex.RUnlock()
+	if digest, ok = s.new[key]; ok {
+		return
+	}
+	digest, ok = s.old[key]
+	return
+}
+
+// Set records the digest of key.
+func (s *Items) Set(key, digest string) {
+	s.RWMutex.Lock()
+	s.new[key] = digest
+	s.RWMutex.Unlock()
+}
+
+// Read reads the records of earlier runs from the given provider.
+func (s *Items) Read(provider string) result.VoidResult {
+	s.RWMutex.Lock()
+	defer s.RWMutex.Unlock()
+
+	switch provider {
+	case "mgo":
+		var docs = map[string]interface{}{}
+		r := mgo.Mgo(&docs, "find", map[string]interface{}{
+			"Database":   config.Conf().DBName,
+			"Collection": s.tabName,
+		})
+		if r.IsErr() {
+			logs.Log().Error(" *     Fail  [read item record][mgo]: %v\n", r.UnwrapErr())
+			return result.OkVoid()
+		}
+		for _, v := range docs["Docs"].([]interface{}) {
+			doc := v.(bson.M)
+			s.old[doc["_id"].(string)], _ = doc["digest"].(string)
+		}
+
+	case "mysql":
+		_, err := mysql.DB()
+		if err != nil {
+			logs.Log().Error(" *     Fail  [read item record][mysql]: %v\n", err)
+			return result.OkVoid()
+		}
+		table, ok := getReadMysqlTable(s.tabName)
+		if !ok {
+			table = mysql.New().Unwrap().SetTableName(s.tabName)
+			setReadMysqlTable(s.tabName, table)
+		}
+		r := table.SelectAll()
+		if r.IsErr() {
+			return result.OkVoid()
+		}
+		rows := r.Unwrap()
+
+		for rows.Next() {
+			var key, digest string
+			if rows.Scan(&key, &digest) == nil {
+				s.old[key] = digest
+			}
+		}
+
+	default:
+		f, err := os.Open(s.fileName)
+		if err != nil {
+			return result.OkVoid()
+		}
+		defer closer.LogClose(f, logs.Log().Error)
+		b, _ := io.ReadAll(f)
+		if len(b) == 0 {
+			return result.OkVoid()
+		}
+		// Records are appended, so later digests of a key override earlier ones.
+		b[0] = '{'
+		json.Unmarshal(append(b, '}'), &s.old)
+	}
+	logs.Log().Informational(" *     [read item record]: %v\n", len(s.old))
+	return result.OkVoid()
+}
+
+// Flush writes the new records to the given provider, returning their number.
+func (s *Items) Flush(provider string) result.Result[int] {
+	s.RWMutex.Lock()
+	defer s.RWMutex.Unlock()
+
+	sLen := len(s.new)
+	if sLen == 0 {
+		return result.Ok(0)
+	}
+
+	switch provider {
+	case "mgo":
+		if mgo.Error() != nil {
+			return result.TryErr[int](fmt.Errorf(" *     Fail  [add item record][mgo]: %v [ERROR]  %v\n", sLen, mgo.Error()))
+		}
+		r := mgo.Call(func(src pool.Src) error {
+			c := src.(*mgo.MgoSrc).DB(config.Conf().DBName).C(s.tabName)
+			for key, digest := range s.new {
+				if _, err := c.UpsertId(key, bson.M{"_id": key, "digest": digest}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if r.IsErr() {
+			return result.TryErr[int](fmt.Errorf(" *     Fail  [add item record][mgo]: %v [ERROR]  %v\n", sLen, r.UnwrapErr()))
+		}
+
+	case "mysql":
+		_, err := mysql.DB()
+		if err != nil {
+			return result.TryErr[int](fmt.Errorf(" *     Fail  [add item record][mysql]: %v [ERROR]  %v\n", sLen, err))
+		}
+		table, ok := getWriteMysqlTable(s.tabName)
+		if !ok {
+			table = mysql.New().Unwrap()
+			table.SetTableName(s.tabName).
+				CustomPrimaryKey(`id VARCHAR(255) NOT NULL PRIMARY KEY`).
+				AddColumn(`digest VARCHAR(255) NOT NULL`).
+				Replace()
+			if r := table.Create(); r.IsErr() {
+				return result.TryErr[int](fmt.Errorf(" *     Fail  [add item record][mysql]: %v [ERROR]  %v\n", sLen, r.UnwrapErr()))
+			}
+			setWriteMysqlTable(s.tabName, table)
+		}
+		for key, digest := range s.new {
+			table.AutoInsert([]string{key, digest})
+		}
+		if r := table.FlushInsert(); r.IsErr() {
+			return result.TryErr[int](fmt.Errorf(" *     Fail  [add item record][mysql]: %v [ERROR]  %v\n", sLen, r.UnwrapErr()))
+		}
+
+	default:
+		f, err := os.OpenFile(s.fileName, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0777)
+		if err != nil {
+			return result.TryErr[int](fmt.Errorf(" *     Fail  [add item record][file]: %v [ERROR]  %v\n", sLen, err))
+		}
+		b, _ := json.Marshal(s.new)
+		b[0] = ','
+		f.Write(b[:len(b)-1])
+		f.Close()
+	}
+	for key, digest := range s.new {
+		s.old[key] = digest
+	}
+	s.new = make(map[string]string)
+	return result.Ok(sLen)
+}
@@ -0,0 +1,38 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/andeya/pholcus/config"
+)
+
+func TestItems_File(t *testing.T) {
+	cleanup := setupHistoryDir(t)
+	defer cleanup()
+	_ = config.Conf()
+
+	s := NewItems("test", "sub")
+	s.Set("a", "1")
+	s.Set("b", "2")
+	if n := s.Flush("file").Unwrap(); n != 2 {
+		t.Errorf("Flush = %d, want 2", n)
+	}
+	s.Set("a", "3")
+	s.Flush("file").Unwrap()
+	if d, ok := s.Get("a"); !ok || d != "3" {
+		t.Errorf("Get(a) = %q, %v", d, ok)
+	}
+
+	s2 := NewItems("test", "sub")
+	if r := s2.Read("file"); r.IsErr() {
+		t.Fatal(r.UnwrapErr())
+	}
+	for key, want := range map[string]string{"a": "3", "b": "2"} {
+		if d, ok := s2.Get(key); !ok || d != want {
+			t.Errorf("Get(%s) = %q, %v, want %q", key, d, ok, want)
+		}
+	}
+	if _, ok := s2.Get("c"); ok {
+		t.Error("Get(c) found")
+	}
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/andeya/gust/result"
+
+	"github.com/andeya/pholcus/common/ping"
+	"github.com/andeya/pholcus/config"
+)
+
+// Check is how proxies are checked for being online.
+type Check string
+
+const (
+	CheckDefault Check = ""        // config.ini [proxy] check
+	CheckTCP     Check = "tcp"     // a TCP connection to the proxy succeeds
+	CheckConnect Check = "connect" // the proxy opens an HTTP CONNECT tunnel to the test URL's host
+	CheckICMP    Check = "icmp"    // the proxy's host answers ping, needs raw socket privileges
+)
+
+// DefaultConnectTarget is tunneled to by CheckConnect when config.ini
+// [proxy] testurl is empty.
+const DefaultConnectTarget = "www.baidu.com:443"
+
+// Checked makes the proxies of src be checked with c instead of the
+// default check.
+func Checked(src Source, c Check) Source {
+	return &checkedSource{Source: src, check: c}
+}
+
+type checkedSource struct {
+	Source
+	check Check
+}
+
+// Notify implements Notifier if the wrapped Source does.
+func (s *checkedSource) Notify(stop <-chan struct{}, changed func()) {
+	if n, ok := s.Source.(Notifier); ok {
+		n.Notify(stop, changed)
+	}
+}
+
+// sourceCheck returns the check of the proxies of src.
+func sourceCheck(src Source) Check {
+	if s, ok := src.(*checkedSource); ok {
+		return s.check
+	}
+	return CheckDefault
+}
+
+// online checks whether proxy, whose host is ip, is online.
+func online(proxy, ip string, c Check) bool {
+	if c == CheckDefault {
+		c = Check(config.Conf().Proxy.Check)
+	}
+	timeout := time.Second * time.Duration(CONN_TIMEOUT)
+	switch c {
+	case CheckICMP:
+		return ping.Ping(ip, CONN_TIMEOUT).IsOk()
+	case CheckConnect:
+		return connectCheck(proxy, connectTarget(), timeout).IsOk()
+	default:
+		return tcpCheck(proxy, timeout).IsOk()
+	}
+}
+
+// checkThreads returns the max number of concurrent proxy checks.
+func checkThreads() int {
+	if n := config.Conf().Proxy.Threads; n > 0 {
+		return n
+	}
+	return MAX_THREAD_NUM
+}
+
+// connectTarget returns the host:port tunneled to by CheckConnect.
+func connectTarget() string {
+	u, _ := url.Parse(config.Conf().Proxy.TestURL)
+	if u == nil || u.Hostname() == "" {
+		return DefaultConnectTarget
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "http" {
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+	return net.JoinHostPort(u.Hostname(), "443")
+}
+
+// proxyAddr returns the host:port of proxy.
+func proxyAddr(proxy string) result.Result[*url.URL] {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return result.TryErr[*url.URL](err)
+	}
+	if u.Hostname() == "" {
+		return result.FmtErr[*url.URL]("invalid proxy %q", proxy)
+	}
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return result.Ok(u)
+}
+
+// tcpCheck connects to proxy.
+func tcpCheck(proxy string, timeout time.Duration) result.VoidResult {
+	u, err := proxyAddr(proxy).Split()
+	if err != nil {
+		return result.TryErrVoid(err)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, timeout)
+	if err != nil {
+		return result.TryErrVoid(err)
+	}
+	conn.Close()
+	return result.OkVoid()
+}
+
+// connectCheck asks proxy to open a tunnel to target.
+func connectCheck(proxy, target string, timeout time.Duration) result.VoidResult {
+	u, err := proxyAddr(proxy).Split()
+	if err != nil {
+		return result.TryErrVoid(err)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, timeout)
+	if err != nil {
+		return result.TryErrVoid(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if u.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	}
+	req := "CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n"
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		req += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+pass)) + "\r\n"
+	}
+	if _, err = conn.Write([]byte(req + "\r\n")); err != nil {
+		return result.TryErrVoid(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return result.TryErrVoid(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result.FmtErrVoid("proxy CONNECT %s: %s", target, resp.Status)
+	}
+	return result.OkVoid()
+}
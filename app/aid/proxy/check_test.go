@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectProxy starts a proxy accepting CONNECT requests with the
+// credentials u:p, recording their targets.
+func connectProxy(t *testing.T, targets chan<- string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("u:p")) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		targets <- r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestTCPCheck(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	if r := tcpCheck(srv.URL, time.Second); r.IsErr() {
+		t.Errorf("tcpCheck of a listening proxy: %v", r.UnwrapErr())
+	}
+	if tcpCheck("http://"+closedAddr(t), time.Second).IsOk() {
+		t.Error("tcpCheck of a closed port want Err")
+	}
+	if tcpCheck("http://", time.Second).IsOk() {
+		t.Error("tcpCheck of an invalid proxy want Err")
+	}
+}
+
+func TestConnectCheck(t *testing.T) {
+	targets := make(chan string, 1)
+	srv := connectProxy(t, targets)
+	proxy := strings.Replace(srv.URL, "http://", "http://u:p@", 1)
+	if r := connectCheck(proxy, "example.com:443", time.Second); r.IsErr() {
+		t.Fatalf("connectCheck: %v", r.UnwrapErr())
+	}
+	if got := <-targets; got != "example.com:443" {
+		t.Errorf("CONNECT target = %q, want example.com:443", got)
+	}
+	if connectCheck(srv.URL, "example.com:443", time.Second).IsOk() {
+		t.Error("connectCheck without credentials want Err")
+	}
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+	if connectCheck(plain.URL, "example.com:443", time.Second).IsOk() {
+		t.Error("connectCheck of a server that is no proxy want Err")
+	}
+}
+
+func TestProxy_SourceCheck(t *testing.T) {
+	targets := make(chan string, 10)
+	srv := connectProxy(t, targets)
+	good := strings.Replace(srv.URL, "http://", "http://u:p@", 1)
+	noAuth := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1) // listening, but CONNECT is refused
+	dead := "http://" + closedAddr(t)
+
+	p := newTestProxy()
+	p.SetSources(
+		Checked(StaticSource{good, noAuth}, CheckConnect),
+		Checked(StaticSource{dead}, CheckTCP),
+	)
+	p.Lock()
+	defer p.Unlock()
+	if !p.all[good] || p.all[noAuth] || p.all[dead] {
+		t.Errorf("online = %v, want only %v", p.all, good)
+	}
+	if p.Count() != 1 {
+		t.Errorf("Count = %v, want 1", p.Count())
+	}
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"time"
+)
+
+// Outcome is the result of a request sent through a proxy.
+type Outcome int
+
+const (
+	Success Outcome = iota // a response was received
+	Timeout                // dialing or reading timed out
+	Failure                // other connection or proxy error
+	Ban                    // the site banned the proxy
+)
+
+const (
+	CooldownDuration = 2 * time.Minute // first cooldown of a bad proxy, doubled each consecutive time
+	MaxCooldowns     = 3               // a proxy cooling down this many times in a row is evicted
+
+	scoreAlpha     = 0.2                   // weight of the latest outcome in Health.Score
+	initialScore   = 0.8                   // score of a newly tested proxy
+	probationScore = 0.5                   // score after a cooldown
+	cooldownScore  = 0.3                   // a proxy below this score cools down
+	minSamples     = 3                     // outcomes needed before a low score counts
+	minLatency     = 50 * time.Millisecond // floor of the latency in selection weights
+)
+
+// Health is the rolling health of a proxy for one host, built from the
+// outcomes of real requests.
+type Health struct {
+	Proxy     string
+	Score     float64       // 0..1, exponentially weighted success rate
+	Latency   time.Duration // exponentially weighted response time
+	Successes uint64
+	Timeouts  uint64
+	Failures  uint64
+	Bans      uint64
+	Cooldowns int       // consecutive cooldowns
+	CoolUntil time.Time // not selected before this time
+	samples   int       // outcomes since the proxy was tested or cooled down
+}
+
+func newHealth(proxy string, latency time.Duration) *Health {
+	return &Health{Proxy: proxy, Score: initialScore, Latency: latency}
+}
+
+// record adds an outcome; cooled reports that h went into cooldown and
+// evicted that it cooled down too often.
+func (h *Health) record(o Outcome, latency time.Duration, now time.Time) (cooled, evicted bool) {
+	var value float64
+	switch o {
+	case Success:
+		h.Successes++
+		value = 1
+		if latency > 0 {
+			h.Latency = time.Duration(float64(h.Latency)*(1-scoreAlpha) + float64(latency)*scoreAlpha)
+		}
+	case Timeout:
+		h.Timeouts++
+	case Failure:
+		h.Failures++
+	case Ban:
+		h.Bans++
+	}
+	h.Score = h.Score*(1-scoreAlpha) + value*scoreAlpha
+	h.samples++
+	if o == Success && h.Score >= initialScore {
+		h.Cooldowns = 0 // recovered
+	}
+	if o != Ban && (h.samples < minSamples || h.Score >= cooldownScore) {
+		return false, false
+	}
+	h.Cooldowns++
+	if h.Cooldowns >= MaxCooldowns {
+		return true, true
+	}
+	h.CoolUntil = now.Add(CooldownDuration << (h.Cooldowns - 1))
+	h.Score, h.samples = probationScore, 0
+	return true, false
+}
+
+// healthy reports whether h may be selected.
+func (h *Health) healthy(now time.Time) bool {
+	return !now.Before(h.CoolUntil)
+}
+
+// weight is the selection weight of h: reliable and fast proxies are
+// picked more often.
+func (h *Health) weight() float64 {
+	return h.Score * h.Score / max(h.Latency, minLatency).Seconds()
+}
@@ -1,16 +1,17 @@
 package proxy
 
 import (
+	"math/rand"
 	"sync"
 	"time"
 )
 
-// ProxyForHost manages proxy IPs for a host, sorted by response time.
+// ProxyForHost manages the usable proxy IPs of a host with their health,
+// sorted by tested response time.
 type ProxyForHost struct {
-	curIndex  int // Index of current proxy IP
-	proxys    []string
-	timedelay []time.Duration
-	isEcho    bool // Whether to print proxy switch info
+	proxys  []*Health
+	tested  time.Time  // last testAndSort
+	testing sync.Mutex // serializes testAndSort, which runs without the lock
 	sync.Mutex
 }
 
@@ -20,10 +21,55 @@ func (ph *ProxyForHost) Len() int {
 }
 
 func (ph *ProxyForHost) Less(i, j int) bool {
-	return ph.timedelay[i] < ph.timedelay[j]
+	return ph.proxys[i].Latency < ph.proxys[j].Latency
 }
 
 func (ph *ProxyForHost) Swap(i, j int) {
 	ph.proxys[i], ph.proxys[j] = ph.proxys[j], ph.proxys[i]
-	ph.timedelay[i], ph.timedelay[j] = ph.timedelay[j], ph.timedelay[i]
+}
+
+// find returns the health of proxy, or nil.
+func (ph *ProxyForHost) find(proxy string) *Health {
+	for _, h := range ph.proxys {
+		if h.Proxy == proxy {
+			return h
+		}
+	}
+	return nil
+}
+
+// remove drops proxy from the usable proxies.
+func (ph *ProxyForHost) remove(proxy string) {
+	for i, h := range ph.proxys {
+		if h.Proxy == proxy {
+			ph.proxys = append(ph.proxys[:i], ph.proxys[i+1:]...)
+			return
+		}
+	}
+}
+
+// pick returns a random healthy proxy, weighted by Health.weight, or nil
+// if none is healthy.
+func (ph *ProxyForHost) pick(now time.Time) *Health {
+	var total float64
+	for _, h := range ph.proxys {
+		if h.healthy(now) {
+			total += h.weight()
+		}
+	}
+	if total <= 0 {
+		return nil
+	}
+	r := rand.Float64() * total
+	var last *Health
+	for _, h := range ph.proxys {
+		if !h.healthy(now) {
+			continue
+		}
+		last = h
+		if r -= h.weight(); r < 0 {
+			break
+		}
+	}
+	return last
 }
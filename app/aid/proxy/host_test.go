@@ -7,13 +7,13 @@ import (
 
 func TestProxyForHost_Len(t *testing.T) {
 	tests := []struct {
-		proxys []string
+		proxys []*Health
 		want   int
 	}{
 		{nil, 0},
-		{[]string{}, 0},
-		{[]string{"a"}, 1},
-		{[]string{"a", "b", "c"}, 3},
+		{[]*Health{}, 0},
+		{[]*Health{newHealth("a", 0)}, 1},
+		{[]*Health{newHealth("a", 0), newHealth("b", 0), newHealth("c", 0)}, 3},
 	}
 	for _, tt := range tests {
 		ph := &ProxyForHost{proxys: tt.proxys}
@@ -25,8 +25,11 @@ func TestProxyForHost_Len(t *testing.T) {
 
 func TestProxyForHost_Less(t *testing.T) {
 	ph := &ProxyForHost{
-		proxys:    []string{"a", "b", "c"},
-		timedelay: []time.Duration{10 * time.Millisecond, 5 * time.Millisecond, 20 * time.Millisecond},
+		proxys: []*Health{
+			newHealth("a", 10*time.Millisecond),
+			newHealth("b", 5*time.Millisecond),
+			newHealth("c", 20*time.Millisecond),
+		},
 	}
 	tests := []struct {
 		i, j int
@@ -46,14 +49,34 @@ func TestProxyForHost_Less(t *testing.T) {
 
 func TestProxyForHost_Swap(t *testing.T) {
 	ph := &ProxyForHost{
-		proxys:    []string{"a", "b"},
-		timedelay: []time.Duration{10 * time.Millisecond, 5 * time.Millisecond},
+		proxys: []*Health{newHealth("a", 10*time.Millisecond), newHealth("b", 5*time.Millisecond)},
 	}
 	ph.Swap(0, 1)
-	if ph.proxys[0] != "b" || ph.proxys[1] != "a" {
-		t.Errorf("Swap proxys = %v", ph.proxys)
+	if ph.proxys[0].Proxy != "b" || ph.proxys[1].Proxy != "a" {
+		t.Errorf("Swap proxys = %v, %v", ph.proxys[0].Proxy, ph.proxys[1].Proxy)
 	}
-	if ph.timedelay[0] != 5*time.Millisecond || ph.timedelay[1] != 10*time.Millisecond {
-		t.Errorf("Swap timedelay = %v", ph.timedelay)
+	if ph.proxys[0].Latency != 5*time.Millisecond || ph.proxys[1].Latency != 10*time.Millisecond {
+		t.Errorf("Swap latency = %v, %v", ph.proxys[0].Latency, ph.proxys[1].Latency)
+	}
+}
+
+func TestProxyForHost_Pick(t *testing.T) {
+	now := time.Now()
+	fast, slow, cooling := newHealth("fast", 100*time.Millisecond), newHealth("slow", time.Second), newHealth("cooling", time.Millisecond)
+	cooling.CoolUntil = now.Add(time.Minute)
+	ph := &ProxyForHost{proxys: []*Health{fast, slow, cooling}}
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		counts[ph.pick(now).Proxy]++
+	}
+	if counts["cooling"] != 0 {
+		t.Errorf("cooling proxy picked %d times", counts["cooling"])
+	}
+	if counts["fast"] < 5*counts["slow"] || counts["slow"] == 0 {
+		t.Errorf("picks = %v, want fast about 10 times as often as slow", counts)
+	}
+	fast.CoolUntil, slow.CoolUntil = cooling.CoolUntil, cooling.CoolUntil
+	if h := ph.pick(now); h != nil {
+		t.Errorf("pick with all proxies cooling = %v, want nil", h.Proxy)
 	}
 }
@@ -2,11 +2,10 @@
 package proxy
 
 import (
-	"io"
+	"errors"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -18,7 +17,6 @@ import (
 	"github.com/andeya/gust/result"
 	"github.com/andeya/pholcus/app/downloader/request"
 	"github.com/andeya/pholcus/app/downloader/surfer"
-	"github.com/andeya/pholcus/common/ping"
 	"github.com/andeya/pholcus/config"
 	"github.com/andeya/pholcus/logs"
 )
@@ -32,10 +30,14 @@ type Proxy struct {
 	all                map[string]bool
 	online             int32
 	usable             map[string]*ProxyForHost
-	ticker             *time.Ticker
-	tickMinute         int64
-	threadPool         chan bool
+	evicted            map[string]map[string]bool // proxies evicted per host key
+	tickMinute         int64                      // minutes between tests of a host's proxies
 	surf               surfer.Surfer
+	sources            []Source
+	lists              []map[string]string // proxy -> ip of each source
+	checks             []Check             // online check of each source
+	stop               chan struct{}       // stops the notifiers of sources
+	updating           sync.Mutex          // serializes source loads and merges
 	sync.Mutex
 }
 
@@ -43,7 +45,7 @@ const (
 	CONN_TIMEOUT = 4 //4s
 	DAIL_TIMEOUT = 4 //4s
 	TRY_TIMES    = 3
-	// Max concurrency for IP speed testing
+	// Default max concurrency of proxy checks and speed tests, see config.ini [proxy] threads
 	MAX_THREAD_NUM = 1000
 )
 
@@ -56,16 +58,15 @@ func New() *Proxy {
 		allIps:             map[string]string{},
 		all:                map[string]bool{},
 		usable:             make(map[string]*ProxyForHost),
-		threadPool:         make(chan bool, MAX_THREAD_NUM),
 		surf:               surfer.New(),
 	}
-	go p.Update()
+	go func() { p.SetSources(ConfigSources(config.Conf())...) }()
 	return p
 }
 
 // Count returns the number of online proxy IPs.
 func (p *Proxy) Count() int32 {
-	return p.online
+	return atomic.LoadInt32(&p.online)
 }
 
 // SetSurfForTest injects a Surfer for testing.
@@ -73,158 +74,385 @@ func (p *Proxy) SetSurfForTest(s surfer.Surfer) {
 	p.surf = s
 }
 
-// Update refreshes the proxy IP list.
-func (p *Proxy) Update() result.VoidResult {
-	f, err := os.Open(config.Conf().ProxyFile)
-	if err != nil {
-		return result.TryErrVoid(err)
+// SetSources replaces the sources of the pool, loads them and keeps
+// merging their changes into the pool without interrupting crawls.
+func (p *Proxy) SetSources(sources ...Source) result.VoidResult {
+	p.updating.Lock()
+	if p.stop != nil {
+		close(p.stop)
+	}
+	stop := make(chan struct{})
+	p.sources, p.lists, p.stop = sources, make([]map[string]string, len(sources)), stop
+	p.checks = make([]Check, len(sources))
+	for i, src := range sources {
+		if n, ok := src.(Notifier); ok {
+			go n.Notify(stop, func() { p.reload(stop, i) })
+		}
 	}
-	b, _ := io.ReadAll(f)
-	f.Close()
+	p.updating.Unlock()
+	return p.Update()
+}
 
-	proxysIPType := p.proxyIPTypeRegexp.FindAllString(string(b), -1)
-	for _, proxy := range proxysIPType {
-		p.allIps[proxy] = p.ipRegexp.FindString(proxy)
-		p.all[proxy] = false
+// Update reloads all sources, by default those of the config file, and
+// checks again which proxy IPs are online.
+func (p *Proxy) Update() result.VoidResult {
+	p.updating.Lock()
+	defer p.updating.Unlock()
+	if p.sources == nil {
+		p.sources = ConfigSources(config.Conf())
+		p.lists = make([]map[string]string, len(p.sources))
+		p.checks = make([]Check, len(p.sources))
 	}
+	var errs []error
+	for i := range p.sources {
+		if err := p.load(i); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.merge(true)
+	return result.RetVoid(errors.Join(errs...))
+}
 
-	proxysUrlType := p.proxyUrlTypeRegexp.FindAllString(string(b), -1)
-	for _, proxy := range proxysUrlType {
-		gvalue := p.proxyUrlTypeRegexp.FindStringSubmatch(proxy)
-		p.allIps[proxy] = gvalue[6]
-		p.all[proxy] = false
+// reload loads source i again after it changed and merges its proxies.
+func (p *Proxy) reload(stop chan struct{}, i int) {
+	p.updating.Lock()
+	defer p.updating.Unlock()
+	select {
+	case <-stop: // the sources were replaced
+		return
+	default:
 	}
+	if p.load(i) == nil {
+		p.merge(false)
+	}
+}
 
-	log.Printf(" *     Read proxy IPs: %v\n", len(p.all))
+// load reads the proxies of source i; on error its previous list is kept.
+func (p *Proxy) load(i int) error {
+	entries, err := p.sources[i].Load().Split()
+	if err != nil {
+		log.Printf(" *     Failed to load proxy IPs: %v\n", err)
+		return err
+	}
+	p.lists[i] = p.parse(strings.Join(entries, "\n"))
+	p.checks[i] = sourceCheck(p.sources[i])
+	return nil
+}
 
-	p.findOnline()
-	return result.OkVoid()
+// parse extracts the proxies of text with their IPs or host names.
+func (p *Proxy) parse(text string) map[string]string {
+	proxys := make(map[string]string)
+	for _, proxy := range p.proxyIPTypeRegexp.FindAllString(text, -1) {
+		proxys[proxy] = p.ipRegexp.FindString(proxy)
+	}
+	for _, proxy := range p.proxyUrlTypeRegexp.FindAllString(text, -1) {
+		gvalue := p.proxyUrlTypeRegexp.FindStringSubmatch(proxy)
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+		proxys[proxy] = gvalue[6]
+	}
+	return proxys
 }
 
-// findOnline filters proxy IPs that are online.
-func (p *Proxy) findOnline() *Proxy {
+// merge makes the pool the union of the source lists: proxies no source
+// lists any more are dropped, new ones are checked for being online, and
+// with all set every proxy is checked again.
+func (p *Proxy) merge(all bool) {
+	union := make(map[string]candidate)
+	for i, list := range p.lists {
+		for proxy, ip := range list {
+			if _, ok := union[proxy]; !ok {
+				union[proxy] = candidate{ip: ip, check: p.checks[i]}
+			}
+		}
+	}
+	check := make(map[string]candidate)
+	p.Lock()
+	for proxy, c := range union {
+		if _, ok := p.all[proxy]; all || !ok {
+			check[proxy] = c
+		}
+	}
+	p.Unlock()
+
 	log.Printf(" *     Filtering online proxy IPs...")
-	p.online = 0
+	alive := p.findOnline(check)
+
+	p.Lock()
+	var added, removed int
 	for proxy := range p.all {
-		p.threadPool <- true
-		go func(proxy string) {
-			alive := ping.Ping(p.allIps[proxy], CONN_TIMEOUT).IsOk()
-			p.Lock()
-			p.all[proxy] = alive
-			p.Unlock()
-			if alive {
-				atomic.AddInt32(&p.online, 1)
+		if _, ok := union[proxy]; !ok {
+			delete(p.all, proxy)
+			delete(p.allIps, proxy)
+			for _, proxyForHost := range p.usable {
+				proxyForHost.Mutex.Lock()
+				proxyForHost.remove(proxy)
+				proxyForHost.Mutex.Unlock()
 			}
-			<-p.threadPool
-		}(proxy)
+			removed++
+		}
+	}
+	for proxy, c := range check {
+		if _, ok := p.all[proxy]; !ok {
+			added++
+		}
+		p.all[proxy], p.allIps[proxy] = alive[proxy], c.ip
 	}
-	for len(p.threadPool) > 0 {
-		time.Sleep(0.2e9)
+	var online int32
+	for _, ok := range p.all {
+		if ok {
+			online++
+		}
 	}
-	p.online = atomic.LoadInt32(&p.online)
-	log.Printf(" *     Online proxy IP filtering complete, total: %v\n", p.online)
+	atomic.StoreInt32(&p.online, online)
+	p.Unlock()
+	log.Printf(" *     Proxy IPs: %v (+%v -%v), online: %v\n", len(union), added, removed, online)
+}
 
-	return p
+// candidate is a proxy to check for being online.
+type candidate struct {
+	ip    string
+	check Check
+}
+
+// findOnline reports which of the given proxy IPs are online.
+func (p *Proxy) findOnline(proxys map[string]candidate) map[string]bool {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		alive = make(map[string]bool, len(proxys))
+		sem   = make(chan bool, checkThreads())
+	)
+	for proxy, c := range proxys {
+		sem <- true
+		wg.Add(1)
+		go func(proxy string, c candidate) {
+			defer func() { <-sem; wg.Done() }()
+			ok := online(proxy, c.ip, c.check)
+			mu.Lock()
+			alive[proxy] = ok
+			mu.Unlock()
+		}(proxy, c)
+	}
+	wg.Wait()
+	return alive
 }
 
-// UpdateTicker updates the ticker.
+// UpdateTicker sets how often the proxies of a host are tested again,
+// refreshing their response times and adding proxies that came online.
 func (p *Proxy) UpdateTicker(tickMinute int64) {
+	p.Lock()
+	defer p.Unlock()
 	p.tickMinute = tickMinute
-	p.ticker = time.NewTicker(time.Duration(p.tickMinute) * time.Minute)
-	for _, proxyForHost := range p.usable {
-		proxyForHost.curIndex++
-		proxyForHost.isEcho = true
-	}
 }
 
-// GetOne returns an unused proxy IP for this cycle and its response time.
+// GetOne returns a proxy IP for the host of u, picked at random among its
+// healthy proxies with a preference for reliable and fast ones.
 func (p *Proxy) GetOne(u string) option.Option[string] {
-	if p.online == 0 {
+	if p.Count() == 0 {
 		return option.None[string]()
 	}
 	u2, _ := url.Parse(u)
-	if u2.Host == "" {
+	if u2 == nil || u2.Host == "" {
 		logs.Log().Informational(" *     [%v] Failed to set proxy IP, invalid target URL\n", u)
 		return option.None[string]()
 	}
-	var key = u2.Host
-	if strings.Count(key, ".") > 1 {
-		key = key[strings.Index(key, ".")+1:]
+	var (
+		key      = hostKey(u2.Host)
+		testHost = u2.Scheme + "://" + u2.Host
+	)
+
+	p.Lock()
+	proxyForHost := p.usable[key]
+	created := proxyForHost == nil
+	if created {
+		proxyForHost = &ProxyForHost{}
+		p.usable[key] = proxyForHost
+	}
+	tickMinute := p.tickMinute
+	p.Unlock()
+
+	proxyForHost.Mutex.Lock()
+	tested := proxyForHost.tested
+	proxyForHost.Mutex.Unlock()
+	if created || tickMinute > 0 && time.Since(tested) >= time.Duration(tickMinute)*time.Minute {
+		p.testAndSort(key, proxyForHost, testHost, tested)
+	}
+	proxyForHost.Mutex.Lock()
+	h := proxyForHost.pick(time.Now())
+	tested = proxyForHost.tested
+	proxyForHost.Mutex.Unlock()
+	if h == nil && time.Since(tested) >= CooldownDuration {
+		p.testAndSort(key, proxyForHost, testHost, tested)
+		proxyForHost.Mutex.Lock()
+		h = proxyForHost.pick(time.Now())
+		proxyForHost.Mutex.Unlock()
+	}
+	if h == nil {
+		logs.Log().Informational(" *     [%v] Failed to set proxy IP, no available proxy IPs\n", key)
+		return option.None[string]()
+	}
+	return option.Some(h.Proxy)
+}
+
+// Report records the outcome of a request to u through proxy. A proxy whose
+// score drops too low, or that is banned, cools down and is not selected
+// for a while; one that cools down MaxCooldowns times in a row is evicted.
+func (p *Proxy) Report(proxy, u string, o Outcome, latency time.Duration) {
+	u2, _ := url.Parse(u)
+	if proxy == "" || u2 == nil || u2.Host == "" {
+		return
 	}
+	key := hostKey(u2.Host)
 
 	p.Lock()
 	defer p.Unlock()
+	proxyForHost := p.usable[key]
+	if proxyForHost == nil {
+		return
+	}
+	proxyForHost.Mutex.Lock()
+	defer proxyForHost.Mutex.Unlock()
+	h := proxyForHost.find(proxy)
+	if h == nil {
+		return
+	}
+	cooled, evicted := h.record(o, latency, time.Now())
+	switch {
+	case evicted:
+		proxyForHost.remove(proxy)
+		if p.evicted == nil {
+			p.evicted = make(map[string]map[string]bool)
+		}
+		if p.evicted[key] == nil {
+			p.evicted[key] = make(map[string]bool)
+		}
+		p.evicted[key][proxy] = true
+		logs.Log().Informational(" *     [%v] Proxy IP [%v] evicted, %v left\n", key, proxy, len(proxyForHost.proxys))
+	case cooled:
+		logs.Log().Informational(" *     [%v] Proxy IP [%v] cooling down until %v\n", key, proxy, h.CoolUntil.Format(time.TimeOnly))
+	}
+}
 
-	var ok = true
-	var proxyForHost = p.usable[key]
+// Penalize records that the site of u banned proxy; the next GetOne for
+// that host returns another proxy.
+func (p *Proxy) Penalize(proxy, u string) {
+	p.Report(proxy, u, Ban, 0)
+}
 
-	select {
-	case <-p.ticker.C:
-		proxyForHost.curIndex++
-		if proxyForHost.curIndex >= proxyForHost.Len() {
-			_, ok = p.testAndSort(key, u2.Scheme+"://"+u2.Host)
-		}
-		proxyForHost.isEcho = true
+// HostHealth is the proxy pool state of one host.
+type HostHealth struct {
+	Host    string
+	Tested  time.Time
+	Proxies []Health // usable proxies, fastest tested first
+	Evicted []string
+}
 
-	default:
-		if proxyForHost == nil {
-			p.usable[key] = &ProxyForHost{
-				proxys:    []string{},
-				timedelay: []time.Duration{},
-				isEcho:    true,
-			}
-			proxyForHost, ok = p.testAndSort(key, u2.Scheme+"://"+u2.Host)
-		} else if l := proxyForHost.Len(); l == 0 {
-			ok = false
-		} else if proxyForHost.curIndex >= l {
-			_, ok = p.testAndSort(key, u2.Scheme+"://"+u2.Host)
-			proxyForHost.isEcho = true
+// Health returns the pool state of every host proxies were requested for.
+func (p *Proxy) Health() []HostHealth {
+	p.Lock()
+	defer p.Unlock()
+	hosts := make([]HostHealth, 0, len(p.usable))
+	for key, proxyForHost := range p.usable {
+		hh := HostHealth{Host: key}
+		proxyForHost.Mutex.Lock()
+		hh.Tested = proxyForHost.tested
+		for _, h := range proxyForHost.proxys {
+			hh.Proxies = append(hh.Proxies, *h)
 		}
+		proxyForHost.Mutex.Unlock()
+		for proxy := range p.evicted[key] {
+			hh.Evicted = append(hh.Evicted, proxy)
+		}
+		sort.Strings(hh.Evicted)
+		hosts = append(hosts, hh)
 	}
-	if !ok {
-		logs.Log().Informational(" *     [%v] Failed to set proxy IP, no available proxy IPs\n", key)
-		return option.None[string]()
-	}
-	curProxy := proxyForHost.proxys[proxyForHost.curIndex]
-	if proxyForHost.isEcho {
-		logs.Log().Informational(" *     Set proxy IP to [%v](%v)\n",
-			curProxy,
-			proxyForHost.timedelay[proxyForHost.curIndex],
-		)
-		proxyForHost.isEcho = false
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	return hosts
+}
+
+// hostKey groups hosts by parent domain, e.g. www.example.com and
+// img.example.com share the proxies of example.com.
+func hostKey(host string) string {
+	if strings.Count(host, ".") > 1 {
+		return host[strings.Index(host, ".")+1:]
 	}
-	return option.Some(curProxy)
+	return host
 }
 
-// testAndSort tests and sorts proxy IPs for the given host.
-func (p *Proxy) testAndSort(key string, testHost string) (*ProxyForHost, bool) {
+// testAndSort tests the proxy IPs for the given host and sorts them by
+// response time. Proxies that pass keep their health; those that fail are
+// dropped until the next test. The checks run without holding p, so that
+// Report, Alive and Health are not blocked; a test of the host finished
+// after since by another caller is not repeated.
+func (p *Proxy) testAndSort(key string, proxyForHost *ProxyForHost, testHost string, since time.Time) (*ProxyForHost, bool) {
+	proxyForHost.testing.Lock()
+	defer proxyForHost.testing.Unlock()
+	proxyForHost.Mutex.Lock()
+	tested := proxyForHost.tested
+	n := proxyForHost.Len()
+	proxyForHost.Mutex.Unlock()
+	if tested.After(since) {
+		return proxyForHost, n > 0
+	}
+
 	logs.Log().Informational(" *     [%v] Testing and sorting proxy IPs...", key)
-	proxyForHost := p.usable[key]
-	proxyForHost.proxys = []string{}
-	proxyForHost.timedelay = []time.Duration{}
-	proxyForHost.curIndex = 0
+	if u := config.Conf().Proxy.TestURL; u != "" {
+		testHost = u
+	}
+	var proxys []string
+	p.Lock()
 	for proxy, online := range p.all {
-		if !online {
-			continue
+		if online && !p.evicted[key][proxy] {
+			proxys = append(proxys, proxy)
 		}
-		p.threadPool <- true
+	}
+	p.Unlock()
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		sem    = make(chan bool, checkThreads())
+		delays = make(map[string]time.Duration)
+	)
+	for _, proxy := range proxys {
+		sem <- true
+		wg.Add(1)
 		go func(proxy string) {
-			alive, timedelay := p.findUsable(proxy, testHost)
-			if alive {
-				proxyForHost.Mutex.Lock()
-				proxyForHost.proxys = append(proxyForHost.proxys, proxy)
-				proxyForHost.timedelay = append(proxyForHost.timedelay, timedelay)
-				proxyForHost.Mutex.Unlock()
+			defer func() { <-sem; wg.Done() }()
+			if alive, timedelay := p.findUsable(proxy, testHost); alive {
+				mu.Lock()
+				delays[proxy] = timedelay
+				mu.Unlock()
 			}
-			<-p.threadPool
 		}(proxy)
 	}
-	for len(p.threadPool) > 0 {
-		time.Sleep(0.2e9)
+	wg.Wait()
+
+	// Swap in the results, keeping the health reported meanwhile.
+	p.Lock()
+	proxyForHost.Mutex.Lock()
+	usable := make([]*Health, 0, len(delays))
+	for proxy, timedelay := range delays {
+		if p.evicted[key][proxy] {
+			continue
+		}
+		h := proxyForHost.find(proxy)
+		if h == nil {
+			h = newHealth(proxy, timedelay)
+		} else {
+			h.Latency = timedelay
+		}
+		usable = append(usable, h)
 	}
-	if proxyForHost.Len() > 0 {
-		sort.Sort(proxyForHost)
-		logs.Log().Informational(" *     [%v] Testing and sorting proxy IPs complete, available: %v\n", key, proxyForHost.Len())
+	proxyForHost.proxys = usable
+	proxyForHost.tested = time.Now()
+	sort.Sort(proxyForHost)
+	proxyForHost.Mutex.Unlock()
+	p.Unlock()
+
+	if n = len(usable); n > 0 {
+		logs.Log().Informational(" *     [%v] Testing and sorting proxy IPs complete, available: %v\n", key, n)
 		return proxyForHost, true
 	}
 	logs.Log().Informational(" *     [%v] Testing and sorting proxy IPs complete, no available proxy IPs\n", key)
@@ -38,7 +38,6 @@ func newTestProxy() *Proxy {
 		allIps:             map[string]string{},
 		all:                map[string]bool{},
 		usable:             make(map[string]*ProxyForHost),
-		threadPool:         make(chan bool, MAX_THREAD_NUM),
 		surf:               surfer.New(),
 	}
 }
@@ -94,11 +93,7 @@ func TestProxy_UpdateTicker(t *testing.T) {
 	p := &Proxy{
 		usable: make(map[string]*ProxyForHost),
 	}
-	p.usable["example.com"] = &ProxyForHost{curIndex: 0, isEcho: false}
 	p.UpdateTicker(5)
-	if p.ticker == nil {
-		t.Error("UpdateTicker should set ticker")
-	}
 	if p.tickMinute != 5 {
 		t.Errorf("tickMinute = %v, want 5", p.tickMinute)
 	}
@@ -119,13 +114,9 @@ func TestProxy_New(t *testing.T) {
 func TestProxy_GetOne_WithUsable(t *testing.T) {
 	p := &Proxy{
 		online: 1,
-		ticker: time.NewTicker(time.Hour),
 		usable: map[string]*ProxyForHost{
 			"example.com": {
-				proxys:    []string{"http://127.0.0.1:8080"},
-				timedelay: []time.Duration{time.Millisecond},
-				curIndex:  0,
-				isEcho:    false,
+				proxys: []*Health{newHealth("http://127.0.0.1:8080", time.Millisecond)},
 			},
 		},
 	}
@@ -138,16 +129,78 @@ func TestProxy_GetOne_WithUsable(t *testing.T) {
 	}
 }
 
+func TestProxy_Penalize(t *testing.T) {
+	p := &Proxy{
+		online: 2,
+		usable: map[string]*ProxyForHost{
+			"example.com": {
+				proxys: []*Health{
+					newHealth("http://127.0.0.1:8080", time.Millisecond),
+					newHealth("http://127.0.0.1:8081", 2*time.Millisecond),
+				},
+			},
+		},
+	}
+	p.Penalize("http://127.0.0.1:8080", "http://www.example.com/a")
+	for i := 0; i < 20; i++ {
+		if got := p.GetOne("http://www.example.com/").Unwrap(); got != "http://127.0.0.1:8081" {
+			t.Fatalf("GetOne after Penalize = %v, want http://127.0.0.1:8081", got)
+		}
+	}
+	h := p.usable["example.com"].find("http://127.0.0.1:8080")
+	if h == nil || h.Bans != 1 || !h.CoolUntil.After(time.Now()) {
+		t.Errorf("penalized proxy health = %+v, want cooling down after 1 ban", h)
+	}
+}
+
+func TestProxy_Report_Eviction(t *testing.T) {
+	const proxy = "http://127.0.0.1:8080"
+	p := &Proxy{
+		online: 1,
+		usable: map[string]*ProxyForHost{
+			"example.com": {proxys: []*Health{newHealth(proxy, time.Millisecond)}},
+		},
+	}
+	for i := 0; i < 3; i++ {
+		p.Report(proxy, "http://www.example.com/", Success, 20*time.Millisecond)
+	}
+	h := p.usable["example.com"].find(proxy)
+	var timeouts uint64
+	for i := 1; i < MaxCooldowns; i++ {
+		for n := 0; h.Cooldowns < i; n++ {
+			if n == 10 {
+				t.Fatalf("no cooldown %d after 10 timeouts: %+v", i, h)
+			}
+			p.Report(proxy, "http://www.example.com/", Timeout, 0)
+			timeouts++
+		}
+		if want := CooldownDuration << (i - 1); time.Until(h.CoolUntil) <= want-time.Second {
+			t.Errorf("cooldown %d until %v, want about %v", i, h.CoolUntil, want)
+		}
+	}
+	if h.Timeouts != timeouts || h.Successes != 3 {
+		t.Errorf("counts = %+v", h)
+	}
+	p.Report(proxy, "http://www.example.com/", Ban, 0)
+	if p.usable["example.com"].Len() != 0 || !p.evicted["example.com"][proxy] {
+		t.Fatal("proxy not evicted after MaxCooldowns")
+	}
+	hosts := p.Health()
+	if len(hosts) != 1 || hosts[0].Host != "example.com" || len(hosts[0].Evicted) != 1 || len(hosts[0].Proxies) != 0 {
+		t.Errorf("Health() = %+v", hosts)
+	}
+	if p.GetOne("http://www.example.com/").IsSome() {
+		t.Error("GetOne returned an evicted proxy")
+	}
+}
+
 func TestProxy_GetOne_NoUsableForHost(t *testing.T) {
 	p := &Proxy{
 		online: 1,
-		ticker: time.NewTicker(time.Hour),
 		usable: map[string]*ProxyForHost{
 			"example.com": {
-				proxys:    []string{},
-				timedelay: []time.Duration{},
-				curIndex:  0,
-				isEcho:    false,
+				proxys: []*Health{},
+				tested: time.Now(),
 			},
 		},
 	}
@@ -178,13 +231,11 @@ func TestProxy_GetOne_TriggersTestAndSort(t *testing.T) {
 	p.all = map[string]bool{"http://127.0.0.1:8080": true}
 	p.allIps = map[string]string{"http://127.0.0.1:8080": "127.0.0.1"}
 	p.online = 1
-	p.ticker = time.NewTicker(time.Hour)
+	p.tickMinute = 1
 	p.usable = map[string]*ProxyForHost{
 		"example.com": {
-			proxys:    []string{"old"}, // curIndex will exceed after tick
-			timedelay: []time.Duration{time.Millisecond},
-			curIndex:  1,
-			isEcho:    false,
+			proxys: []*Health{newHealth("old", time.Millisecond)}, // dropped by the retest
+			tested: time.Now().Add(-time.Hour),
 		},
 	}
 
@@ -197,6 +248,50 @@ func TestProxy_GetOne_TriggersTestAndSort(t *testing.T) {
 	}
 }
 
+type blockingSurfer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSurfer) Download(req surfer.Request) result.Result[*http.Response] {
+	close(b.started)
+	<-b.release
+	return result.Ok(&http.Response{StatusCode: http.StatusOK})
+}
+
+func TestProxy_GetOne_TestsWithoutLock(t *testing.T) {
+	cleanup := setupProxyDir(t)
+	defer cleanup()
+	_ = config.Conf()
+
+	b := &blockingSurfer{started: make(chan struct{}), release: make(chan struct{})}
+	p := newTestProxy()
+	p.SetSurfForTest(b)
+	p.all = map[string]bool{"http://127.0.0.1:8080": true}
+	p.online = 1
+
+	got := make(chan string)
+	go func() { got <- p.GetOne("http://www.example.com/path").UnwrapOr("") }()
+	<-b.started
+
+	done := make(chan struct{})
+	go func() {
+		p.Report("http://127.0.0.1:8080", "http://www.example.com/", Success, time.Millisecond)
+		p.Alive("http://127.0.0.1:8080", "http://www.example.com/")
+		p.Health()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Report, Alive and Health blocked by the proxy test")
+	}
+	close(b.release)
+	if proxy := <-got; proxy != "http://127.0.0.1:8080" {
+		t.Errorf("GetOne = %q, want http://127.0.0.1:8080", proxy)
+	}
+}
+
 func TestProxy_Update_FileNotFound(t *testing.T) {
 	tmp := t.TempDir()
 	orig, _ := os.Getwd()
@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andeya/gust/result"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/andeya/pholcus/config"
+	"github.com/andeya/pholcus/logs"
+)
+
+type (
+	// Source supplies proxy addresses to the pool. Load returns entries in
+	// the format of proxy.lib lines; the pool is the union of all sources.
+	Source interface {
+		Load() result.Result[[]string]
+	}
+
+	// Notifier is optionally implemented by a Source that can tell when its
+	// list changed. Notify calls changed on every change until stop is closed.
+	Notifier interface {
+		Notify(stop <-chan struct{}, changed func())
+	}
+
+	// FileSource reads proxies from a file like proxy.lib, one per line.
+	FileSource struct {
+		Path  string
+		Watch bool // reload when the file is written, replaced or removed
+	}
+
+	// HTTPSource fetches proxies from a provider endpoint answering either
+	// text with one proxy per line or JSON: strings like "ip:port" or
+	// "http://ip:port" and objects with "ip" and "port" (and optionally
+	// "scheme" or "protocol") are taken from anywhere in the document.
+	HTTPSource struct {
+		URL      string
+		Header   http.Header
+		Interval time.Duration // time between fetches, 0 fetches only on Update
+	}
+
+	// StaticSource is a fixed list of proxies.
+	StaticSource []string
+)
+
+// ConfigSources returns the sources set in config.ini: the proxylib file,
+// the [proxy] static list and the [proxy] HTTP providers, each with its
+// configured online check.
+func ConfigSources(c *config.Config) []Source {
+	checked := func(src Source, check string) Source {
+		if check == "" {
+			return src
+		}
+		return Checked(src, Check(check))
+	}
+	sources := []Source{checked(&FileSource{Path: c.ProxyFile, Watch: c.Proxy.Watch}, c.Proxy.FileCheck)}
+	if static := splitList(c.Proxy.Static); len(static) > 0 {
+		sources = append(sources, checked(StaticSource(static), c.Proxy.StaticCheck))
+	}
+	for _, u := range splitList(c.Proxy.URLs) {
+		src := &HTTPSource{URL: u, Interval: time.Duration(c.Proxy.Interval) * time.Second}
+		sources = append(sources, checked(src, c.Proxy.URLCheck))
+	}
+	return sources
+}
+
+// Load implements Source.
+func (s *FileSource) Load() result.Result[[]string] {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return result.TryErr[[]string](err)
+	}
+	return result.Ok([]string{string(b)})
+}
+
+// Notify implements Notifier.
+func (s *FileSource) Notify(stop <-chan struct{}, changed func()) {
+	if !s.Watch {
+		return
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		logs.Log().Error(" *     Failed to watch proxy file %s: %v\n", s.Path, err)
+		return
+	}
+	defer w.Close()
+	// Watch the directory: editors often replace the file instead of writing it.
+	if err = w.Add(filepath.Dir(s.Path)); err != nil {
+		logs.Log().Error(" *     Failed to watch proxy file %s: %v\n", s.Path, err)
+		return
+	}
+	name := filepath.Clean(s.Path)
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) == name && !ev.Has(fsnotify.Chmod) {
+				debounce.Reset(200 * time.Millisecond)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			logs.Log().Error(" *     Watching proxy file %s: %v\n", s.Path, err)
+		case <-debounce.C:
+			changed()
+		}
+	}
+}
+
+// Load implements Source.
+func (s *HTTPSource) Load() result.Result[[]string] {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return result.TryErr[[]string](err)
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return result.TryErr[[]string](err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return result.TryErr[[]string](err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result.FmtErr[[]string]("proxy provider %s: %s", s.URL, resp.Status)
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || (b[0] != '[' && b[0] != '{') {
+		return result.Ok([]string{string(b)})
+	}
+	var doc interface{}
+	if err = json.Unmarshal(b, &doc); err != nil {
+		return result.FmtErr[[]string]("proxy provider %s: %v", s.URL, err)
+	}
+	var entries []string
+	collectProxies(doc, &entries)
+	return result.Ok(entries)
+}
+
+// Notify implements Notifier.
+func (s *HTTPSource) Notify(stop <-chan struct{}, changed func()) {
+	if s.Interval <= 0 {
+		return
+	}
+	t := time.NewTicker(s.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			changed()
+		}
+	}
+}
+
+// Load implements Source.
+func (s StaticSource) Load() result.Result[[]string] {
+	return result.Ok([]string(s))
+}
+
+// collectProxies appends the proxies found in a decoded JSON document.
+func collectProxies(v interface{}, entries *[]string) {
+	switch v := v.(type) {
+	case string:
+		if isProxyAddr(v) {
+			*entries = append(*entries, v)
+		}
+	case []interface{}:
+		for _, e := range v {
+			collectProxies(e, entries)
+		}
+	case map[string]interface{}:
+		if ip, ok := v["ip"].(string); ok && v["port"] != nil {
+			scheme, _ := v["scheme"].(string)
+			if scheme == "" {
+				scheme, _ = v["protocol"].(string)
+			}
+			if scheme == "" {
+				scheme = "http"
+			}
+			*entries = append(*entries, fmt.Sprintf("%s://%s:%v", strings.ToLower(scheme), ip, v["port"]))
+			return
+		}
+		for _, e := range v {
+			collectProxies(e, entries)
+		}
+	}
+}
+
+// isProxyAddr reports whether s looks like host:port with an optional scheme.
+func isProxyAddr(s string) bool {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Hostname() != "" && u.Port() != ""
+}
+
+// splitList splits a comma separated config value.
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andeya/gust/result"
+)
+
+func TestHTTPSource_Load(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			io.WriteString(w, `{"code":0,"msg":"ok","data":[
+				{"ip":"10.0.0.1","port":8080},
+				{"ip":"10.0.0.2","port":"3128","protocol":"HTTPS"},
+				"10.0.0.3:80",
+				"http://u:p@10.0.0.4:8888"
+			]}`)
+		case "/text":
+			io.WriteString(w, "10.0.0.1:8080\n10.0.0.2:3128\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	got := (&HTTPSource{URL: srv.URL + "/json"}).Load().Unwrap()
+	sort.Strings(got)
+	want := []string{"10.0.0.3:80", "http://10.0.0.1:8080", "http://u:p@10.0.0.4:8888", "https://10.0.0.2:3128"}
+	if len(got) != len(want) {
+		t.Fatalf("JSON entries = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("JSON entries = %q, want %q", got, want)
+			break
+		}
+	}
+
+	p := newTestProxy()
+	proxys := p.parse((&HTTPSource{URL: srv.URL + "/text"}).Load().Unwrap()[0])
+	if proxys["http://10.0.0.1:8080"] != "10.0.0.1" || proxys["http://10.0.0.2:3128"] != "10.0.0.2" || len(proxys) != 2 {
+		t.Errorf("text proxies = %v", proxys)
+	}
+
+	if (&HTTPSource{URL: srv.URL + "/missing"}).Load().IsOk() {
+		t.Error("Load of a 404 endpoint want Err")
+	}
+}
+
+func TestFileSource_Notify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.lib")
+	if err := os.WriteFile(path, []byte("http://10.0.0.1:8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go (&FileSource{Path: path, Watch: true}).Notify(stop, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	time.Sleep(100 * time.Millisecond) // let the watcher start
+	if err := os.WriteFile(path, []byte("http://10.0.0.2:8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change notified after writing the file")
+	}
+}
+
+// listSource is a Source whose list can be changed, notifying the pool.
+type listSource struct {
+	sync.Mutex
+	list    []string
+	changed chan struct{}
+}
+
+func (s *listSource) Load() result.Result[[]string] {
+	s.Lock()
+	defer s.Unlock()
+	return result.Ok(append([]string(nil), s.list...))
+}
+
+func (s *listSource) Notify(stop <-chan struct{}, changed func()) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.changed:
+			changed()
+		}
+	}
+}
+
+func (s *listSource) set(list ...string) {
+	s.Lock()
+	s.list = list
+	s.Unlock()
+	s.changed <- struct{}{}
+}
+
+func TestProxy_SetSources(t *testing.T) {
+	p := newTestProxy()
+	src := &listSource{list: []string{"http://127.0.0.1:8080"}, changed: make(chan struct{})}
+	p.SetSources(StaticSource{"http://127.0.0.1:9090"}, src)
+	p.usable["example.com"] = &ProxyForHost{
+		proxys: []*Health{newHealth("http://127.0.0.1:8080", time.Millisecond), newHealth("http://127.0.0.1:9090", time.Millisecond)},
+	}
+
+	has := func(proxy string) bool {
+		p.Lock()
+		defer p.Unlock()
+		_, ok := p.all[proxy]
+		return ok
+	}
+	if !has("http://127.0.0.1:8080") || !has("http://127.0.0.1:9090") {
+		t.Fatalf("pool = %v, want proxies of both sources", p.all)
+	}
+
+	src.set("http://127.0.0.1:7070")
+	deadline := time.Now().Add(5 * time.Second)
+	for !has("http://127.0.0.1:7070") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !has("http://127.0.0.1:7070") || has("http://127.0.0.1:8080") || !has("http://127.0.0.1:9090") {
+		t.Errorf("pool after source change = %v", p.all)
+	}
+	p.Lock()
+	defer p.Unlock()
+	if ph := p.usable["example.com"]; ph.find("http://127.0.0.1:8080") != nil || ph.find("http://127.0.0.1:9090") == nil {
+		t.Error("dropped proxy still usable for host")
+	}
+}
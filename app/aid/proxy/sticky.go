@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/logs"
+)
+
+// Sticky selects which requests share a proxy.
+type Sticky int
+
+const (
+	Rotate        Sticky = iota // a proxy per request
+	StickySession               // one proxy per cookie session: the spider's or the request's own cookie jar
+	StickyHost                  // one proxy per host
+	StickyTime                  // one proxy for all requests
+)
+
+// Policy is how the requests of a spider get proxies. A sticky proxy is
+// only switched when it is banned or dead, or after TTL if set.
+type Policy struct {
+	Sticky Sticky
+	TTL    time.Duration // max time a sticky proxy is kept, 0 keeps it until banned or dead
+}
+
+// Sessions keeps the proxies of the sticky sessions of one spider.
+type Sessions struct {
+	policy   Policy
+	jar      http.CookieJar // the spider's cookie jar, the session of requests without their own
+	sessions map[interface{}]*session
+	sync.Mutex
+}
+
+type session struct {
+	proxy string
+	until time.Time
+}
+
+// NewSessions creates the sticky sessions of policy; jar is the cookie jar
+// shared by the requests of the spider, nil if not created yet.
+func NewSessions(policy Policy, jar http.CookieJar) *Sessions {
+	return &Sessions{policy: policy, jar: jar, sessions: make(map[interface{}]*session)}
+}
+
+// Get returns the proxy of req from p: that of its session if still
+// usable, otherwise a new one, which then becomes the session's proxy.
+func (s *Sessions) Get(p *Proxy, req *request.Request) string {
+	key, ok := s.key(req)
+	if !ok {
+		return p.GetOne(req.GetURL()).UnwrapOr("")
+	}
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	old := s.sessions[key]
+	reason := ""
+	if old != nil {
+		switch {
+		case s.policy.TTL > 0 && !now.Before(old.until):
+			reason = "expired"
+		case !p.Alive(old.proxy, req.GetURL()):
+			reason = "banned or dead"
+		default:
+			return old.proxy
+		}
+	}
+	proxy := p.GetOne(req.GetURL()).UnwrapOr("")
+	if proxy == "" {
+		return ""
+	}
+	if old != nil && old.proxy != proxy {
+		logs.Log().Informational(" *     [%v] Sticky proxy IP [%v] %s, switching to [%v]\n", req.GetURL(), old.proxy, reason, proxy)
+	}
+	s.sessions[key] = &session{proxy: proxy, until: now.Add(s.policy.TTL)}
+	return proxy
+}
+
+// key returns the session of req, or false if it gets a proxy of its own.
+func (s *Sessions) key(req *request.Request) (interface{}, bool) {
+	switch s.policy.Sticky {
+	case StickySession:
+		if !req.GetEnableCookie() {
+			return nil, false
+		}
+		if jar := req.GetCookieJar(); jar != nil && jar != s.jar {
+			return jar, true
+		}
+		return nil, true // the spider's cookie jar, set when downloading
+	case StickyHost:
+		u, _ := url.Parse(req.GetURL())
+		if u == nil || u.Host == "" {
+			return nil, false
+		}
+		return u.Host, true
+	case StickyTime:
+		return "", true
+	}
+	return nil, false
+}
+
+// Alive reports whether proxy may still be used for the host of u: it is
+// online, not evicted and not cooling down.
+func (p *Proxy) Alive(proxy, u string) bool {
+	u2, _ := url.Parse(u)
+	if u2 == nil || u2.Host == "" {
+		return false
+	}
+	key := hostKey(u2.Host)
+	p.Lock()
+	defer p.Unlock()
+	if !p.all[proxy] || p.evicted[key][proxy] {
+		return false
+	}
+	proxyForHost := p.usable[key]
+	if proxyForHost == nil {
+		return true
+	}
+	proxyForHost.Mutex.Lock()
+	defer proxyForHost.Mutex.Unlock()
+	h := proxyForHost.find(proxy)
+	return h == nil || h.healthy(time.Now())
+}
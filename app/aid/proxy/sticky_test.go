@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"net/http/cookiejar"
+	"testing"
+	"time"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+)
+
+func newStickyTestProxy() *Proxy {
+	return &Proxy{
+		online: 2,
+		all:    map[string]bool{"http://127.0.0.1:8080": true, "http://127.0.0.1:8081": true},
+		usable: map[string]*ProxyForHost{
+			"example.com": {
+				proxys: []*Health{
+					newHealth("http://127.0.0.1:8080", time.Millisecond),
+					newHealth("http://127.0.0.1:8081", time.Millisecond),
+				},
+				tested: time.Now(),
+			},
+		},
+	}
+}
+
+func stickyReq(u string, cookie bool) *request.Request {
+	return &request.Request{URL: u, EnableCookie: cookie}
+}
+
+func TestSessions_StickySession(t *testing.T) {
+	p := newStickyTestProxy()
+	spiderJar, _ := cookiejar.New(nil)
+	s := NewSessions(Policy{Sticky: StickySession}, spiderJar)
+
+	first := s.Get(p, stickyReq("http://www.example.com/login", true))
+	for i := 0; i < 20; i++ {
+		if got := s.Get(p, stickyReq("http://www.example.com/page", true)); got != first {
+			t.Fatalf("session proxy changed from %v to %v", first, got)
+		}
+	}
+
+	other := stickyReq("http://www.example.com/", true)
+	jar, _ := cookiejar.New(nil)
+	other.SetCookieJar(jar)
+	s.Get(p, other)
+	retried := stickyReq("http://www.example.com/page", true)
+	retried.SetCookieJar(spiderJar)
+	if got := s.Get(p, retried); got != first {
+		t.Errorf("request with the spider's jar got %v, want the session proxy %v", got, first)
+	}
+	if len(s.sessions) != 2 {
+		t.Errorf("sessions = %d, want one for the spider's jar and one for the request's own", len(s.sessions))
+	}
+
+	p.Penalize(first, "http://www.example.com/")
+	second := s.Get(p, stickyReq("http://www.example.com/page", true))
+	if second == first || second == "" {
+		t.Fatalf("proxy after ban = %q, want the other one than %v", second, first)
+	}
+	if got := s.Get(p, stickyReq("http://www.example.com/page", true)); got != second {
+		t.Errorf("session proxy changed again to %v", got)
+	}
+
+	s.Get(p, stickyReq("http://www.example.com/", false))
+	if len(s.sessions) != 2 {
+		t.Error("request without cookies joined a session")
+	}
+}
+
+func TestSessions_StickyHost(t *testing.T) {
+	p := newStickyTestProxy()
+	s := NewSessions(Policy{Sticky: StickyHost}, nil)
+	s.Get(p, stickyReq("http://www.example.com/", false))
+	s.Get(p, stickyReq("http://img.example.com/", false))
+	s.Get(p, stickyReq("http://www.example.com/a", false))
+	if len(s.sessions) != 2 {
+		t.Errorf("sessions = %d, want one per host", len(s.sessions))
+	}
+}
+
+func TestSessions_StickyTime(t *testing.T) {
+	p := newStickyTestProxy()
+	s := NewSessions(Policy{Sticky: StickyTime, TTL: time.Hour}, nil)
+	first := s.Get(p, stickyReq("http://www.example.com/", false))
+	if got := s.Get(p, stickyReq("http://www.example.com/b", true)); got != first {
+		t.Errorf("proxy changed from %v to %v within TTL", first, got)
+	}
+	s.sessions[""].until = time.Now().Add(-time.Second)
+	s.Get(p, stickyReq("http://www.example.com/c", false))
+	if !s.sessions[""].until.After(time.Now()) {
+		t.Error("expired session not renewed")
+	}
+}
+
+func TestProxy_Alive(t *testing.T) {
+	p := newStickyTestProxy()
+	if !p.Alive("http://127.0.0.1:8080", "http://www.example.com/") {
+		t.Error("healthy proxy not alive")
+	}
+	if !p.Alive("http://127.0.0.1:8080", "http://other.org/") {
+		t.Error("proxy not yet tested for a host not alive")
+	}
+	p.Penalize("http://127.0.0.1:8080", "http://www.example.com/")
+	if p.Alive("http://127.0.0.1:8080", "http://www.example.com/") {
+		t.Error("proxy cooling down alive")
+	}
+	p.all["http://127.0.0.1:8081"] = false
+	if p.Alive("http://127.0.0.1:8081", "http://www.example.com/") {
+		t.Error("offline proxy alive")
+	}
+}
@@ -5,6 +5,7 @@
 package app
 
 import (
+	"fmt"
 	"io"
 	"reflect"
 	"runtime/debug"
@@ -19,6 +20,7 @@ import (
 	"github.com/andeya/pholcus/app/distribute"
 	"github.com/andeya/pholcus/app/distribute/teleport"
 	"github.com/andeya/pholcus/app/downloader"
+	"github.com/andeya/pholcus/app/downloader/surfer"
 	"github.com/andeya/pholcus/app/pipeline"
 	"github.com/andeya/pholcus/app/scheduler"
 	"github.com/andeya/pholcus/app/spider"
@@ -495,6 +497,7 @@ func (l *Logic) exec() {
 
 // goRun executes the task.
 func (l *Logic) goRun(count int) {
+	dns := surfer.GetDNSStats()
 	var i int
 	for i = 0; i < count && l.Status() != status.STOP; i++ {
 		for l.IsPaused() {
@@ -512,8 +515,25 @@ func (l *Logic) goRun(count int) {
 			}(i, c)
 		}
 	}
+	var responses, banned, rejected, size uint64
 	for ii := 0; ii < i; ii++ {
 		s := <-cache.ReportChan
+		responses += s.Responses
+		banned += s.Banned
+		rejected += s.Rejected
+		size += s.DataSize
+		if s.DataSize > 0 {
+			logs.Log().App(" *     [Task subtotal: %s | KEYIN: %s]   Downloaded %s, average %s/s\n",
+				s.SpiderName, s.Keyin, byteSize(s.DataSize), byteSize(byteRate(s.DataSize, s.Time)))
+		}
+		if s.Banned > 0 {
+			logs.Log().App(" *     [Task subtotal: %s | KEYIN: %s]   Banned %v of %v responses (%.1f%%)\n",
+				s.SpiderName, s.Keyin, s.Banned, s.Responses, banRate(s.Banned, s.Responses))
+		}
+		if s.Rejected > 0 {
+			logs.Log().App(" *     [Task subtotal: %s | KEYIN: %s]   Rejected %v invalid data items\n",
+				s.SpiderName, s.Keyin, s.Rejected)
+		}
 		if (s.DataNum == 0) && (s.FileNum == 0) {
 			logs.Log().App(" *     [Task subtotal: %s | KEYIN: %s]   No results, duration %v\n", s.SpiderName, s.Keyin, s.Time)
 			continue
@@ -559,6 +579,21 @@ func (l *Logic) goRun(count int) {
 		logs.Log().App(" *                            -- %sTotal collected [%v data items + %v files], crawled [success %v URL + fail %v URL = total %v URL], duration [%v] --",
 			prefix, l.sum[0], l.sum[1], cache.GetPageCount(1), cache.GetPageCount(-1), cache.GetPageCount(0), l.takeTime)
 	}
+	if size > 0 {
+		logs.Log().App(" *                            -- Downloaded [%s, average %s/s] --",
+			byteSize(size), byteSize(byteRate(size, l.takeTime)))
+	}
+	if st := surfer.GetDNSStats(); st.Hits+st.Misses > dns.Hits+dns.Misses {
+		logs.Log().App(" *                            -- DNS lookups [cached %v + resolved %v, failed %v] --",
+			st.Hits-dns.Hits, st.Misses-dns.Misses, st.Errors-dns.Errors)
+	}
+	if banned > 0 {
+		logs.Log().App(" *                            -- Banned [%v of %v responses = %.1f%%] --",
+			banned, responses, banRate(banned, responses))
+	}
+	if rejected > 0 {
+		logs.Log().App(" *                            -- Rejected [%v invalid data items] --", rejected)
+	}
 	logs.Log().Informational(" * ")
 	logs.Log().Informational(` *********************************************************************************************************************************** `)
 
@@ -568,6 +603,36 @@ func (l *Logic) goRun(count int) {
 	}
 }
 
+// banRate returns banned as a percentage of responses.
+func banRate(banned, responses uint64) float64 {
+	if responses == 0 {
+		return 0
+	}
+	return float64(banned) * 100 / float64(responses)
+}
+
+// byteRate returns size per second of d.
+func byteRate(size uint64, d time.Duration) uint64 {
+	if d <= 0 {
+		return 0
+	}
+	return uint64(float64(size) / d.Seconds())
+}
+
+// byteSize formats n bytes with a binary unit, e.g. 1.5 MiB.
+func byteSize(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 // socketLog forwards client logs to the server.
 func (l *Logic) socketLog() {
 	for l.canSocketLog {
@@ -252,3 +252,18 @@ func TestLogic_Run_server_withSpiders(t *testing.T) {
 	}()
 	a.Run()
 }
+
+func TestByteSize(t *testing.T) {
+	for n, want := range map[uint64]string{
+		0:       "0 B",
+		1023:    "1023 B",
+		1024:    "1.0 KiB",
+		1536:    "1.5 KiB",
+		5 << 20: "5.0 MiB",
+		3 << 30: "3.0 GiB",
+	} {
+		if got := byteSize(n); got != want {
+			t.Errorf("byteSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -157,8 +157,12 @@ func (c *crawler) Process(req *request.Request) {
 	}
 
 	for _, f := range ctx.PullFiles() {
-		if c.Pipeline.CollectFile(f).IsErr() {
-			break
+		if r := c.Pipeline.CollectFile(f); r.IsErr() {
+			if sp.DoHistory(req, false) {
+				cache.PageFailCount()
+			}
+			logs.Log().Error(" *     Fail  [file][%v]: %v\n", downUrl, r.UnwrapErr())
+			return
 		}
 	}
 	for _, item := range ctx.PullItems() {
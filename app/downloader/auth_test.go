@@ -0,0 +1,202 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/app/downloader/surfer"
+	"github.com/andeya/pholcus/app/spider"
+)
+
+func authGet(t *testing.T, sp *spider.Spider, url string) (int, string) {
+	t.Helper()
+	req := &request.Request{URL: url, Rule: "r", TryTimes: 1}
+	req.Prepare()
+	ctx := SurferDownloader.Download(sp, req)
+	if ctx.Response == nil {
+		t.Fatalf("Download(%s) err: %v", url, ctx.GetError())
+	}
+	body, _ := io.ReadAll(ctx.Response.Body)
+	return ctx.Response.StatusCode, string(body)
+}
+
+func TestSurferDownloader_Download_BasicAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderBasicAuth")
+	sp.Auth = &spider.BasicAuth{Username: "alice", Password: "secret"}
+	if status, body := authGet(t, sp, ts.URL); status != http.StatusOK || body != "ok" {
+		t.Errorf("got %d %q, want 200 ok", status, body)
+	}
+}
+
+func TestSurferDownloader_Download_AuthNotPersisted(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderAuthNotPersisted")
+	sp.Auth = &spider.BasicAuth{Username: "alice", Password: "secret"}
+	req := &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}
+	req.Prepare()
+	ctx := SurferDownloader.Download(sp, req)
+	if ctx.Response == nil {
+		t.Fatalf("Download err: %v", ctx.GetError())
+	}
+	body, _ := io.ReadAll(ctx.Response.Body)
+	if !strings.HasPrefix(string(body), "Basic ") {
+		t.Errorf("sent Authorization = %q, want Basic credentials", body)
+	}
+	if got := req.GetHeader().Get("Authorization"); got != "" {
+		t.Errorf("persisted Authorization = %q, want empty", got)
+	}
+	if s := req.Serialize().Unwrap(); strings.Contains(s, string(body)) {
+		t.Errorf("serialized request contains the credentials: %s", s)
+	}
+}
+
+func TestSurferDownloader_Download_DigestAuth(t *testing.T) {
+	const realm, nonce = "test", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	field := func(auth, key string) string {
+		m := regexp.MustCompile(key + `="?([^",]*)`).FindStringSubmatch(auth)
+		if m == nil {
+			return ""
+		}
+		return m[1]
+	}
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		auth := r.Header.Get("Authorization")
+		ha1 := md5hex("alice:" + realm + ":secret")
+		ha2 := md5hex(r.Method + ":" + field(auth, "uri"))
+		want := md5hex(ha1 + ":" + nonce + ":" + field(auth, "nc") + ":" + field(auth, "cnonce") + ":auth:" + ha2)
+		if field(auth, "response") != want || field(auth, "uri") != r.URL.RequestURI() {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, qop="auth,auth-int", nonce=%q, opaque="5ccc"`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderDigestAuth")
+	sp.Auth = &spider.DigestAuth{Username: "alice", Password: "secret"}
+	if status, body := authGet(t, sp, ts.URL+"/dir/index.html?x=1"); status != http.StatusOK || body != "ok" {
+		t.Errorf("first request got %d %q, want 200 ok", status, body)
+	}
+	if status, _ := authGet(t, sp, ts.URL+"/other"); status != http.StatusOK {
+		t.Errorf("second request got %d, want 200", status)
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("server hits = %d, want 3 (one challenge)", n)
+	}
+
+	sp.Auth = &spider.DigestAuth{Username: "alice", Password: "wrong"}
+	if status, _ := authGet(t, sp, ts.URL); status != http.StatusUnauthorized {
+		t.Errorf("wrong password got %d, want 401", status)
+	}
+}
+
+func TestSurferDownloader_Download_OAuth2Refresh(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		issued  int
+		current string
+	)
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("client_id") != "app" {
+			t.Errorf("token request form = %v", r.Form)
+		}
+		mu.Lock()
+		issued++
+		current = fmt.Sprintf("token-%d", issued)
+		fmt.Fprintf(w, `{"access_token":%q,"refresh_token":"rt-%d","expires_in":3600}`, current, issued)
+		mu.Unlock()
+	}))
+	defer tokens.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ok := r.Header.Get("Authorization") == "Bearer "+current
+		mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer api.Close()
+
+	auth := &spider.OAuth2{TokenURL: tokens.URL, ClientID: "app", RefreshToken: "rt-0"}
+	sp := makeSpiderNotStopping("DownloaderTestSpiderOAuth2")
+	sp.Auth = auth
+	if status, _ := authGet(t, sp, api.URL); status != http.StatusOK {
+		t.Fatalf("first request got %d, want 200", status)
+	}
+
+	// The server revokes the token: in-flight requests renew it only once.
+	mu.Lock()
+	current = "revoked"
+	mu.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, _ := authGet(t, sp, api.URL); status != http.StatusOK {
+				t.Errorf("after revocation got %d, want 200", status)
+			}
+		}()
+	}
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if issued != 2 {
+		t.Errorf("tokens issued = %d, want 2", issued)
+	}
+	if auth.RefreshToken != "rt-2" {
+		t.Errorf("RefreshToken = %q, want rotated rt-2", auth.RefreshToken)
+	}
+}
+
+func TestSurferDownloader_Download_Signer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signature") == "" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderSigner")
+	sp.Signer = (&surfer.HMACSigner{Key: []byte("secret")}).Sign
+	// A request restored from history has lost its signer.
+	req := (&request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}).Copy().Unwrap()
+	req.Prepare()
+	ctx := SurferDownloader.Download(sp, req)
+	if ctx.GetError() != nil {
+		t.Fatalf("Download err: %v", ctx.GetError())
+	}
+}
@@ -0,0 +1,106 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/app/downloader/surfer"
+	"github.com/andeya/pholcus/app/spider"
+	"github.com/andeya/pholcus/config"
+)
+
+// globalBandwidth holds the limiter of config.ini [download] bandwidth,
+// rebuilt when the setting changes.
+var globalBandwidth struct {
+	sync.Mutex
+	limit   int64
+	limiter *rate.Limiter
+}
+
+// globalLimiter returns the limiter shared by all spiders, or nil if the
+// total bandwidth is unlimited.
+func globalLimiter() *rate.Limiter {
+	limit := config.Conf().Download.Bandwidth
+	globalBandwidth.Lock()
+	defer globalBandwidth.Unlock()
+	if limit != globalBandwidth.limit {
+		globalBandwidth.limit, globalBandwidth.limiter = limit, spider.NewBandwidthLimiter(limit)
+	}
+	return globalBandwidth.limiter
+}
+
+// metered wraps fetch so that response bodies are received within the
+// global and spider bandwidth limits and their bytes are counted on the
+// request and the spider, before content decoding.
+func metered(sp *spider.Spider, fetch func(*request.Request) (*http.Response, error)) func(*request.Request) (*http.Response, error) {
+	return func(cReq *request.Request) (*http.Response, error) {
+		var limiters []*rate.Limiter
+		for _, l := range []*rate.Limiter{globalLimiter(), sp.GetBandwidthLimiter()} {
+			if l != nil {
+				limiters = append(limiters, l)
+			}
+		}
+		stop := sp.StopContext()
+		cReq.SetBodyMeter(func(body io.ReadCloser) io.ReadCloser {
+			return &meteredBody{ReadCloser: body, sp: sp, req: cReq, limiters: limiters, stop: stop}
+		})
+		return fetch(cReq)
+	}
+}
+
+// meteredBody counts and throttles the bytes read from a response body.
+type meteredBody struct {
+	io.ReadCloser
+	sp       *spider.Spider
+	req      *request.Request
+	limiters []*rate.Limiter
+	stop     context.Context // cancelled when the spider stops
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	for _, l := range b.limiters {
+		if len(p) > l.Burst() {
+			p = p[:l.Burst()]
+		}
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.sp.AddDownloadSize(int64(n))
+		b.req.AddDownloadSize(int64(n))
+		for _, l := range b.limiters {
+			// n never exceeds the burst, so only a stop of the spider fails the wait.
+			if werr := l.WaitN(b.stop, n); werr != nil {
+				return n, werr
+			}
+		}
+	}
+	return n, err
+}
+
+// ResumeFrom implements surfer.Resumer when the original body does, so that
+// file downloads through the limits can be resumed.
+func (b *meteredBody) ResumeFrom(offset int64) error {
+	if rs, ok := b.ReadCloser.(surfer.Resumer); ok {
+		return rs.ResumeFrom(offset)
+	}
+	return errors.New("response cannot be resumed")
+}
+
+// Validator implements surfer.Resumer along with ResumeFrom.
+func (b *meteredBody) Validator() string {
+	if rs, ok := b.ReadCloser.(surfer.Resumer); ok {
+		return rs.Validator()
+	}
+	return ""
+}
+
+// Unwrap returns the original body, e.g. to reach a *surfer.ChromeBody.
+func (b *meteredBody) Unwrap() io.ReadCloser {
+	return b.ReadCloser
+}
@@ -0,0 +1,124 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/config"
+)
+
+func bandwidthServer(size int) *httptest.Server {
+	body := bytes.Repeat([]byte("x"), size)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+}
+
+func TestSurferDownloader_Download_CountBytes(t *testing.T) {
+	ts := bandwidthServer(5000)
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderCountBytes")
+	for i := 0; i < 2; i++ {
+		req := &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}
+		req.Prepare()
+		ctx := SurferDownloader.Download(sp, req)
+		if ctx.Response == nil {
+			t.Fatalf("Download err: %v", ctx.GetError())
+		}
+		io.Copy(io.Discard, ctx.Response.Body)
+		if got := req.GetDownloadSize(); got != 5000 {
+			t.Errorf("request download size = %d, want 5000", got)
+		}
+	}
+	if got := sp.GetDownloadSize(); got != 10000 {
+		t.Errorf("spider download size = %d, want 10000", got)
+	}
+}
+
+func TestSurferDownloader_Download_Bandwidth(t *testing.T) {
+	ts := bandwidthServer(40 << 10)
+	defer ts.Close()
+
+	download := func(name string, bandwidth int64) time.Duration {
+		sp := makeSpiderNotStopping(name)
+		sp.Bandwidth = bandwidth
+		req := &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}
+		req.Prepare()
+		start := time.Now()
+		ctx := SurferDownloader.Download(sp, req)
+		if ctx.Response == nil {
+			t.Fatalf("Download err: %v", ctx.GetError())
+		}
+		if n, _ := io.Copy(io.Discard, ctx.Response.Body); n != 40<<10 {
+			t.Fatalf("read %d bytes, want %d", n, 40<<10)
+		}
+		return time.Since(start)
+	}
+
+	// 40 KiB at 20 KiB/s: the first 20 KiB burst is free, the rest takes 1s.
+	if d := download("DownloaderTestSpiderBandwidth", 20<<10); d < 800*time.Millisecond {
+		t.Errorf("spider limit: downloaded in %v, want about 1s", d)
+	}
+
+	defer func(old int64) { config.Conf().Download.Bandwidth = old }(config.Conf().Download.Bandwidth)
+	config.Conf().Download.Bandwidth = 20 << 10
+	if d := download("DownloaderTestSpiderGlobalBandwidth", 0); d < 800*time.Millisecond {
+		t.Errorf("global limit: downloaded in %v, want about 1s", d)
+	}
+}
+
+func TestSurferDownloader_Download_CountRawBytes(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(bytes.Repeat([]byte("x"), 5000))
+	w.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gz.Bytes())
+	}))
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderCountRawBytes")
+	req := &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}
+	req.Prepare()
+	ctx := SurferDownloader.Download(sp, req)
+	if ctx.Response == nil {
+		t.Fatalf("Download err: %v", ctx.GetError())
+	}
+	if n, _ := io.Copy(io.Discard, ctx.Response.Body); n != 5000 {
+		t.Fatalf("read %d decoded bytes, want 5000", n)
+	}
+	if got := req.GetDownloadSize(); got != int64(gz.Len()) {
+		t.Errorf("request download size = %d, want the %d bytes received", got, gz.Len())
+	}
+}
+
+func TestSurferDownloader_Download_BandwidthStop(t *testing.T) {
+	ts := bandwidthServer(40 << 10)
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderBandwidthStop")
+	sp.Bandwidth = 1 << 10
+	req := &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}
+	req.Prepare()
+	ctx := SurferDownloader.Download(sp, req)
+	if ctx.Response == nil {
+		t.Fatalf("Download err: %v", ctx.GetError())
+	}
+	time.AfterFunc(100*time.Millisecond, sp.Stop)
+	start := time.Now()
+	if _, err := io.Copy(io.Discard, ctx.Response.Body); err == nil {
+		t.Error("read without error after the spider stopped")
+	}
+	// 40 KiB at 1 KiB/s would take 40s.
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("read for %v after the spider stopped", d)
+	}
+}
@@ -2,15 +2,25 @@ package downloader
 
 import (
 	"errors"
+	"fmt"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/andeya/gust/result"
 	"github.com/andeya/gust/syncutil"
+	"github.com/andeya/pholcus/app/aid/proxy"
 	"github.com/andeya/pholcus/app/downloader/request"
 	"github.com/andeya/pholcus/app/downloader/surfer"
+	"github.com/andeya/pholcus/app/downloader/surfer/agent"
+	"github.com/andeya/pholcus/app/scheduler"
 	"github.com/andeya/pholcus/app/spider"
 	"github.com/andeya/pholcus/config"
+	"github.com/andeya/pholcus/logs"
 )
 
 type Surfer struct {
@@ -29,46 +39,179 @@ var lazyPhantom = syncutil.NewLazyValueWithFunc(func() result.Result[surfer.Surf
 })
 
 var lazyChrome = syncutil.NewLazyValueWithFunc(func() result.Result[surfer.Surfer] {
-	return result.Ok[surfer.Surfer](surfer.NewChrome(cookieJar))
+	c := config.Conf().Chrome
+	return result.Ok[surfer.Surfer](surfer.NewChromeWithOptions(surfer.ChromeOptions{
+		MaxTabs:      c.MaxTabs,
+		Browsers:     c.Browsers,
+		RecycleAfter: c.RecycleAfter,
+		TabTimeout:   time.Duration(c.TabTimeout) * time.Second,
+		Isolate:      c.Isolate,
+	}, cookieJar))
 })
 
+var dnsOnce sync.Once
+
+// dnsOptions converts the [dns] section of config.ini.
+func dnsOptions(c config.DNSConfig) surfer.DNSOptions {
+	opts := surfer.DNSOptions{
+		MaxAge: time.Duration(c.MaxAge) * time.Second,
+		Hosts:  make(map[string][]string),
+	}
+	for _, s := range strings.Split(c.Servers, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			opts.Servers = append(opts.Servers, s)
+		}
+	}
+	for _, pair := range strings.Split(c.Hosts, ",") {
+		if host, ip, ok := strings.Cut(pair, "="); ok {
+			host = strings.TrimSpace(host)
+			opts.Hosts[host] = append(opts.Hosts[host], strings.TrimSpace(ip))
+		}
+	}
+	return opts
+}
+
+// Download downloads cReq through the middleware chain.
 func (s *Surfer) Download(sp *spider.Spider, cReq *request.Request) *spider.Context {
+	dnsOnce.Do(func() { surfer.SetDNS(dnsOptions(config.Conf().DNS)) })
 	ctx := spider.GetContext(sp, cReq)
+	if cReq.GetEnableCookie() && cReq.GetCookieJar() == nil {
+		cReq.SetCookieJar(sp.GetCookieJar())
+	}
+	if cReq.GetSession() == "" {
+		cReq.SetSession(sp.GetInstanceName())
+	}
+	if sp.Signer != nil && cReq.GetSigner() == nil {
+		cReq.SetSigner(sp.Signer)
+	}
 
-	var resp *http.Response
-	var err error
+	fetch := reportProxy(metered(sp, s.fetch))
+	if sp.Auth != nil {
+		fetch = authorized(sp.Auth, fetch)
+	}
+	resp, err := process(chain(sp), sp, cReq, fetch)
+	for retries := sp.GetBanRetries(); err == nil && resp != nil; retries-- {
+		reason := sp.DetectBan(resp)
+		sp.CountResponse(reason != "")
+		if reason == "" {
+			break
+		}
+		if retries <= 0 {
+			scheduler.ReportProxy(cReq.GetProxy(), cReq.GetURL(), proxy.Ban, 0)
+			err = fmt.Errorf("%w: %s", spider.ErrBanned, reason)
+			break
+		}
+		logs.Log().Informational(" *     [%s] banned (%s), retrying with a new identity\n", cReq.GetURL(), reason)
+		resp.Body.Close()
+		renewIdentity(cReq)
+		resp, err = process(chain(sp), sp, cReq, fetch)
+	}
+	if err == nil && resp != nil && resp.StatusCode >= 400 {
+		err = errors.New("response status " + resp.Status)
+	}
 
+	ctx.SetResponse(resp).SetError(err)
+
+	return ctx
+}
+
+// fetch downloads cReq with the engine selected by its DownloaderID.
+func (s *Surfer) fetch(cReq *request.Request) (*http.Response, error) {
+	var r result.Result[*http.Response]
 	switch cReq.GetDownloaderID() {
 	case request.SurfID:
-		r := s.surf.Download(cReq)
-		if r.IsErr() {
-			err = r.UnwrapErr()
-		} else {
-			resp = r.Unwrap()
-		}
+		r = s.surf.Download(cReq)
 
 	case request.PhantomID:
-		r := lazyPhantom.TryGetValue().Unwrap().Download(cReq)
-		if r.IsErr() {
-			err = r.UnwrapErr()
-		} else {
-			resp = r.Unwrap()
-		}
+		r = lazyPhantom.TryGetValue().Unwrap().Download(cReq)
 
 	case request.ChromeID:
-		r := lazyChrome.TryGetValue().Unwrap().Download(cReq)
-		if r.IsErr() {
-			err = r.UnwrapErr()
-		} else {
-			resp = r.Unwrap()
-		}
+		r = lazyChrome.TryGetValue().Unwrap().Download(cReq)
+
+	default:
+		return nil, nil
 	}
+	if r.IsErr() {
+		return nil, r.UnwrapErr()
+	}
+	return r.Unwrap(), nil
+}
 
-	if resp != nil && resp.StatusCode >= 400 {
-		err = errors.New("response status " + resp.Status)
+// authorized wraps fetch to add the credentials of auth, retrying once
+// with renewed credentials when the server answers 401.
+func authorized(auth spider.Authenticator, fetch func(*request.Request) (*http.Response, error)) func(*request.Request) (*http.Response, error) {
+	return func(cReq *request.Request) (*http.Response, error) {
+		if err := auth.Authorize(cReq); err != nil {
+			return nil, err
+		}
+		resp, err := fetch(cReq)
+		if err != nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		if retry, err := auth.Unauthorized(cReq, resp); err != nil || !retry {
+			return resp, err
+		}
+		resp.Body.Close()
+		if err := auth.Authorize(cReq); err != nil {
+			return nil, err
+		}
+		return fetch(cReq)
 	}
+}
 
-	ctx.SetResponse(resp).SetError(err)
+// reportProxy wraps fetch to report the outcome and response time of each
+// request sent through a proxy to the proxy pool.
+func reportProxy(fetch func(*request.Request) (*http.Response, error)) func(*request.Request) (*http.Response, error) {
+	return func(cReq *request.Request) (*http.Response, error) {
+		p := cReq.GetProxy()
+		if p == "" {
+			return fetch(cReq)
+		}
+		start := time.Now()
+		resp, err := fetch(cReq)
+		var netErr net.Error
+		switch {
+		case errors.As(err, &netErr) && netErr.Timeout():
+			scheduler.ReportProxy(p, cReq.GetURL(), proxy.Timeout, 0)
+		case err != nil:
+			scheduler.ReportProxy(p, cReq.GetURL(), proxy.Failure, 0)
+		case resp == nil:
+		case resp.StatusCode == http.StatusProxyAuthRequired,
+			resp.StatusCode == http.StatusBadGateway,
+			resp.StatusCode == http.StatusGatewayTimeout:
+			scheduler.ReportProxy(p, cReq.GetURL(), proxy.Failure, 0)
+		default:
+			scheduler.ReportProxy(p, cReq.GetURL(), proxy.Success, time.Since(start))
+		}
+		return resp, err
+	}
+}
 
-	return ctx
+// renewIdentity makes cReq look like it comes from another client: the
+// current proxy is penalized and replaced, the browser profile or
+// User-Agent changed and the cookie session restarted in a fresh jar, so
+// cookies set by the retry (e.g. after a login) are still kept and sent.
+func renewIdentity(cReq *request.Request) {
+	if proxy := cReq.GetProxy(); proxy != "" {
+		cReq.SetProxy(scheduler.ChangeProxy(proxy, cReq.GetURL()))
+	}
+	if cReq.GetEnableCookie() {
+		cReq.SetCookieJar(surfer.NewJar())
+	}
+	header := cReq.GetHeader()
+	header.Del("Cookie")
+	if old := agent.LookupProfile(cReq.GetProfile()); old != nil {
+		old.Remove(header)
+		if p := agent.PickProfile(old.Name); p != nil {
+			cReq.SetProfile(p.Name)
+		}
+		return
+	}
+	uas := agent.UserAgents["common"]
+	for ua := header.Get("User-Agent"); len(uas) > 1; {
+		if next := uas[rand.Intn(len(uas))]; next != ua {
+			header.Set("User-Agent", next)
+			break
+		}
+	}
 }
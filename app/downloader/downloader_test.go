@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -99,3 +100,36 @@ func TestSurferDownloader_Download_SurfID_badURL(t *testing.T) {
 		t.Error("GetError() = nil, want error for failed request")
 	}
 }
+
+func TestSurferDownloader_Download_SpiderCookieJars(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: r.URL.Query().Get("user")})
+		}
+		if c, err := r.Cookie("sid"); err == nil {
+			w.Write([]byte(c.Value))
+		}
+	}))
+	defer ts.Close()
+
+	get := func(sp *spider.Spider, path string) string {
+		req := &request.Request{URL: ts.URL + path, Rule: "r", EnableCookie: true}
+		req.Prepare()
+		ctx := SurferDownloader.Download(sp, req)
+		if err := ctx.GetError(); err != nil {
+			t.Fatalf("Download(%s) err: %v", path, err)
+		}
+		b, _ := io.ReadAll(ctx.Response.Body)
+		return string(b)
+	}
+	alice := makeSpiderNotStopping("DownloaderTestSpiderCookieA")
+	bob := makeSpiderNotStopping("DownloaderTestSpiderCookieB")
+	get(alice, "/login?user=alice")
+	get(bob, "/login?user=bob")
+	if got := get(alice, "/"); got != "alice" {
+		t.Errorf("alice session = %q, want alice", got)
+	}
+	if got := get(bob, "/"); got != "bob" {
+		t.Errorf("bob session = %q, want bob", got)
+	}
+}
@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/app/spider"
+)
+
+// Middleware hooks into every download; see spider.DownloaderMiddleware.
+type Middleware = spider.DownloaderMiddleware
+
+var (
+	middlewares   []Middleware
+	middlewaresMu sync.RWMutex
+)
+
+// Use registers global middlewares, which wrap the per-spider ones of
+// Spider.Middlewares. Middlewares run in registration order.
+func Use(mw ...Middleware) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+	middlewares = append(middlewares, mw...)
+}
+
+// chain returns the global middlewares followed by those of sp.
+func chain(sp *spider.Spider) []Middleware {
+	middlewaresMu.RLock()
+	defer middlewaresMu.RUnlock()
+	if len(sp.Middlewares) == 0 {
+		return middlewares
+	}
+	mws := make([]Middleware, 0, len(middlewares)+len(sp.Middlewares))
+	return append(append(mws, middlewares...), sp.Middlewares...)
+}
+
+// process downloads req through the middleware chain; fetch performs the
+// actual download.
+func process(mws []Middleware, sp *spider.Spider, req *request.Request, fetch func(*request.Request) (*http.Response, error)) (resp *http.Response, err error) {
+	n := 0
+	for n < len(mws) && resp == nil && err == nil {
+		resp, err = mws[n].ProcessRequest(sp, req)
+		n++
+	}
+	if resp == nil && err == nil {
+		resp, err = fetch(req)
+	}
+	for i := n - 1; i >= 0; i-- {
+		if err != nil {
+			switch r, e := mws[i].ProcessError(sp, req, err); {
+			case r != nil:
+				resp, err = r, nil
+			case e != nil:
+				err = e
+			}
+			continue
+		}
+		var r *http.Response
+		if r, err = mws[i].ProcessResponse(sp, req, resp); r != nil {
+			if r != resp && resp.Body != nil && r.Body != resp.Body {
+				// The replaced response is not read.
+				resp.Body.Close()
+			}
+			resp = r
+		}
+		if err != nil && resp != nil {
+			// The failed response is not read.
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			resp = nil
+		}
+	}
+	return resp, err
+}
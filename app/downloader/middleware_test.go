@@ -0,0 +1,282 @@
+package downloader
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/app/spider"
+)
+
+// traceMiddleware records its hook calls in *log.
+func traceMiddleware(name string, log *[]string) *spider.MiddlewareFuncs {
+	return &spider.MiddlewareFuncs{
+		Request: func(*spider.Spider, *request.Request) (*http.Response, error) {
+			*log = append(*log, name+".req")
+			return nil, nil
+		},
+		Response: func(*spider.Spider, *request.Request, *http.Response) (*http.Response, error) {
+			*log = append(*log, name+".resp")
+			return nil, nil
+		},
+		Error: func(_ *spider.Spider, _ *request.Request, err error) (*http.Response, error) {
+			*log = append(*log, name+".err")
+			return nil, err
+		},
+	}
+}
+
+// closeBody records whether it was closed.
+type closeBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func textResponse(s string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(s))}
+}
+
+func TestProcessOrder(t *testing.T) {
+	var log []string
+	mws := []Middleware{traceMiddleware("a", &log), traceMiddleware("b", &log)}
+	fetch := func(*request.Request) (*http.Response, error) {
+		log = append(log, "fetch")
+		return textResponse("ok"), nil
+	}
+	resp, err := process(mws, &spider.Spider{}, &request.Request{}, fetch)
+	if err != nil || resp == nil {
+		t.Fatalf("process() = %v, %v", resp, err)
+	}
+	if got, want := strings.Join(log, " "), "a.req b.req fetch b.resp a.resp"; got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestProcessShortCircuit(t *testing.T) {
+	var log []string
+	cache := &spider.MiddlewareFuncs{
+		Request: func(*spider.Spider, *request.Request) (*http.Response, error) {
+			return textResponse("cached"), nil
+		},
+	}
+	mws := []Middleware{traceMiddleware("a", &log), cache, traceMiddleware("c", &log)}
+	fetch := func(*request.Request) (*http.Response, error) {
+		t.Error("fetch called despite a cached response")
+		return nil, nil
+	}
+	resp, err := process(mws, &spider.Spider{}, &request.Request{}, fetch)
+	if err != nil {
+		t.Fatalf("process() err: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "cached" {
+		t.Errorf("body = %q, want cached", body)
+	}
+	if got, want := strings.Join(log, " "), "a.req a.resp"; got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestProcessReplacesResponse(t *testing.T) {
+	body := &closeBody{Reader: strings.NewReader("raw")}
+	fetch := func(*request.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: body}, nil
+	}
+	replace := &spider.MiddlewareFuncs{
+		Response: func(*spider.Spider, *request.Request, *http.Response) (*http.Response, error) {
+			return textResponse("replaced"), nil
+		},
+	}
+	resp, err := process([]Middleware{replace}, &spider.Spider{}, &request.Request{}, fetch)
+	if err != nil {
+		t.Fatalf("process() err: %v", err)
+	}
+	if b, _ := io.ReadAll(resp.Body); string(b) != "replaced" {
+		t.Errorf("body = %q, want replaced", b)
+	}
+	if !body.closed {
+		t.Error("body of the replaced response not closed")
+	}
+
+	// A response wrapping the body of the original one keeps it open.
+	body = &closeBody{Reader: strings.NewReader("raw")}
+	wrap := &spider.MiddlewareFuncs{
+		Response: func(_ *spider.Spider, _ *request.Request, resp *http.Response) (*http.Response, error) {
+			r := *resp
+			r.StatusCode = http.StatusAccepted
+			return &r, nil
+		},
+	}
+	if resp, err = process([]Middleware{wrap}, &spider.Spider{}, &request.Request{}, fetch); err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("process() = %v, %v", resp, err)
+	}
+	if body.closed {
+		t.Error("body shared with the new response closed")
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	var log []string
+	errBad := errors.New("bad response")
+	validate := &spider.MiddlewareFuncs{
+		Response: func(*spider.Spider, *request.Request, *http.Response) (*http.Response, error) {
+			return nil, errBad
+		},
+	}
+	mws := []Middleware{traceMiddleware("a", &log), validate}
+	body := &closeBody{Reader: strings.NewReader("ok")}
+	fetch := func(*request.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: body}, nil
+	}
+	if resp, err := process(mws, &spider.Spider{}, &request.Request{}, fetch); err != errBad || resp != nil {
+		t.Errorf("process() = %v, %v, want nil, %v", resp, err, errBad)
+	}
+	if got, want := strings.Join(log, " "), "a.req a.err"; got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+	if !body.closed {
+		t.Error("body of the failed response not closed")
+	}
+
+	// An Error hook returning (nil, nil) passes the error on.
+	ignore := &spider.MiddlewareFuncs{
+		Error: func(*spider.Spider, *request.Request, error) (*http.Response, error) {
+			return nil, nil
+		},
+	}
+	if resp, err := process([]Middleware{ignore, validate}, &spider.Spider{}, &request.Request{}, fetch); err != errBad || resp != nil {
+		t.Errorf("process() = %v, %v, want nil, %v", resp, err, errBad)
+	}
+
+	fallback := &spider.MiddlewareFuncs{
+		Error: func(*spider.Spider, *request.Request, error) (*http.Response, error) {
+			return textResponse("fallback"), nil
+		},
+	}
+	fail := func(*request.Request) (*http.Response, error) { return nil, errors.New("timeout") }
+	resp, err := process([]Middleware{fallback}, &spider.Spider{}, &request.Request{}, fail)
+	if err != nil || resp == nil {
+		t.Fatalf("process() = %v, %v, want recovered response", resp, err)
+	}
+}
+
+func TestSurferDownloader_Download_SpiderMiddlewares(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(r.Header.Get("X-Token")))
+	}))
+	defer ts.Close()
+
+	var status int
+	sp := makeSpiderNotStopping("DownloaderTestSpiderMiddleware")
+	sp.Middlewares = []spider.DownloaderMiddleware{&spider.MiddlewareFuncs{
+		Request: func(_ *spider.Spider, req *request.Request) (*http.Response, error) {
+			req.SetHeader("X-Token", "secret")
+			return nil, nil
+		},
+		Response: func(_ *spider.Spider, _ *request.Request, resp *http.Response) (*http.Response, error) {
+			status = resp.StatusCode
+			return nil, nil
+		},
+	}}
+	req := &request.Request{URL: ts.URL, Rule: "r"}
+	req.Prepare()
+
+	ctx := SurferDownloader.Download(sp, req)
+	if status != http.StatusForbidden {
+		t.Errorf("ProcessResponse saw status %d, want %d", status, http.StatusForbidden)
+	}
+	if ctx.GetError() == nil {
+		t.Error("GetError() = nil, want status error")
+	}
+	if body, _ := io.ReadAll(ctx.Response.Body); string(body) != "secret" {
+		t.Errorf("X-Token = %q, want secret", body)
+	}
+}
+
+func TestSurferDownloader_Download_BanRetry(t *testing.T) {
+	var hits int
+	var agents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		agents = append(agents, r.UserAgent())
+		if hits == 1 {
+			w.Write([]byte("please solve the captcha"))
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderBanRetry")
+	sp.BanDetectors = []*spider.BanDetector{{BodyRegexp: regexp.MustCompile(`captcha`)}}
+	req := &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}
+	req.Prepare()
+
+	ctx := SurferDownloader.Download(sp, req)
+	if err := ctx.GetError(); err != nil {
+		t.Fatalf("GetError() = %v", err)
+	}
+	if body, _ := io.ReadAll(ctx.Response.Body); string(body) != "content" {
+		t.Errorf("body = %q, want content", body)
+	}
+	if hits != 2 || agents[0] == agents[1] {
+		t.Errorf("hits = %d, agents = %q, want a retry with another User-Agent", hits, agents)
+	}
+	if responses, banned := sp.GetBanStats(); responses != 2 || banned != 1 {
+		t.Errorf("GetBanStats() = %d, %d, want 2, 1", responses, banned)
+	}
+
+	sp.BanRetries = -1
+	hits = 0
+	req = &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}
+	req.Prepare()
+	if err := SurferDownloader.Download(sp, req).GetError(); !errors.Is(err, spider.ErrBanned) {
+		t.Errorf("GetError() = %v, want ErrBanned", err)
+	}
+}
+
+func TestSurferDownloader_Download_BanRetryNewCookieSession(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		c, _ := r.Cookie("sid")
+		switch {
+		case c != nil && c.Value == "fresh":
+			w.Write([]byte("content"))
+		case c != nil:
+			t.Errorf("retry sent cookie sid=%s of the banned session", c.Value)
+			w.Write([]byte("please solve the captcha"))
+		case hits == 1:
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "banned"})
+			w.Write([]byte("please solve the captcha"))
+		default:
+			// The session needs a cookie set by a redirect.
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "fresh"})
+			http.Redirect(w, r, r.URL.Path, http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	sp := makeSpiderNotStopping("DownloaderTestSpiderBanRetryCookie")
+	sp.BanDetectors = []*spider.BanDetector{{BodyRegexp: regexp.MustCompile(`captcha`)}}
+	req := &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1, EnableCookie: true}
+	req.Prepare()
+
+	ctx := SurferDownloader.Download(sp, req)
+	if err := ctx.GetError(); err != nil {
+		t.Fatalf("GetError() = %v", err)
+	}
+	if body, _ := io.ReadAll(ctx.Response.Body); string(body) != "content" {
+		t.Errorf("body = %q, want content", body)
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/andeya/gust/option"
 	"github.com/andeya/gust/result"
+	"github.com/andeya/pholcus/app/downloader/surfer"
 	"github.com/andeya/pholcus/common/util"
 )
 
@@ -36,10 +38,23 @@ type Request struct {
 	Reloadable    bool            // whether the link can be re-downloaded
 	// DownloaderID: 0=Surf (high concurrency, full features), 1=PhantomJS (strong anti-block, slow, low concurrency)
 	DownloaderID int
-
-	proxy  string // proxy, auto-set when UI enables proxy
-	unique string // unique ID
-	lock   sync.RWMutex
+	Actions      []surfer.ChromeAction // scripted browser steps, Chrome downloader only
+	CaptureXHR   string                // regexp of XHR/fetch URLs to capture, Chrome downloader only
+	Screenshot   bool                  // capture a full-page PNG screenshot, Chrome downloader only
+	PDF          bool                  // print the page to PDF, Chrome downloader only
+	TLS          *surfer.TLSOptions    // HTTPS settings, Surf downloader only; nil inherits the spider's
+	Profile      string                // browser identity profile (see agent.LookupProfile); "" inherits the spider's
+	HTTP3        bool                  // try HTTP/3 first, Surf downloader only; falls back to HTTP/2 and HTTP/1.1
+
+	proxy      string           // proxy, auto-set when UI enables proxy
+	jar        http.CookieJar   // cookie jar of the spider instance, auto-set by the downloader
+	session    string           // spider instance (name + Keyin), auto-set by the downloader
+	signer     surfer.Signer    // signs each send attempt, auto-set by the downloader from Spider.Signer
+	auth       string           // Authorization header sent but not persisted, set by Spider.Auth
+	meter      surfer.BodyMeter // meters the response body, auto-set by the downloader
+	downloaded int64            // response body bytes read, counted by the downloader
+	unique     string           // unique ID
+	lock       sync.RWMutex
 }
 
 const (
@@ -349,6 +364,152 @@ func (r *Request) SetDownloaderID(id int) *Request {
 	return r
 }
 
+// GetActions returns the scripted browser steps for the Chrome downloader.
+func (r *Request) GetActions() []surfer.ChromeAction {
+	return r.Actions
+}
+
+// AddAction appends a scripted browser step for the Chrome downloader.
+func (r *Request) AddAction(action surfer.ChromeAction) *Request {
+	r.Actions = append(r.Actions, action)
+	return r
+}
+
+// GetCaptureXHR returns the regexp of XHR/fetch URLs captured by the Chrome downloader.
+func (r *Request) GetCaptureXHR() string {
+	return r.CaptureXHR
+}
+
+// SetCaptureXHR sets the regexp of XHR/fetch URLs captured by the Chrome downloader.
+func (r *Request) SetCaptureXHR(pattern string) *Request {
+	r.CaptureXHR = pattern
+	return r
+}
+
+// GetScreenshot reports whether the Chrome downloader captures a full-page screenshot.
+func (r *Request) GetScreenshot() bool {
+	return r.Screenshot
+}
+
+// SetScreenshot sets whether the Chrome downloader captures a full-page PNG screenshot.
+func (r *Request) SetScreenshot(enable bool) *Request {
+	r.Screenshot = enable
+	return r
+}
+
+// GetPDF reports whether the Chrome downloader prints the page to PDF.
+func (r *Request) GetPDF() bool {
+	return r.PDF
+}
+
+// SetPDF sets whether the Chrome downloader prints the page to PDF.
+func (r *Request) SetPDF(enable bool) *Request {
+	r.PDF = enable
+	return r
+}
+
+// GetTLS returns the HTTPS settings.
+func (r *Request) GetTLS() *surfer.TLSOptions {
+	return r.TLS
+}
+
+// SetTLS sets the HTTPS settings.
+func (r *Request) SetTLS(opts *surfer.TLSOptions) *Request {
+	r.TLS = opts
+	return r
+}
+
+// GetProfile returns the browser identity profile name.
+func (r *Request) GetProfile() string {
+	return r.Profile
+}
+
+// GetHTTP3 reports whether the Surf downloader tries HTTP/3 first.
+func (r *Request) GetHTTP3() bool {
+	return r.HTTP3
+}
+
+// SetHTTP3 sets whether the Surf downloader tries HTTP/3 first.
+func (r *Request) SetHTTP3(h3 bool) *Request {
+	r.HTTP3 = h3
+	return r
+}
+
+// SetProfile sets the browser identity profile name.
+func (r *Request) SetProfile(profile string) *Request {
+	r.Profile = profile
+	return r
+}
+
+// GetCookieJar returns the cookie jar used when cookies are enabled; nil uses the downloader's.
+func (r *Request) GetCookieJar() http.CookieJar {
+	return r.jar
+}
+
+// AddDownloadSize adds n to the downloaded bytes of the request.
+func (r *Request) AddDownloadSize(n int64) {
+	atomic.AddInt64(&r.downloaded, n)
+}
+
+// GetDownloadSize returns the response body bytes read so far, as received
+// before content decoding, including those of retries.
+func (r *Request) GetDownloadSize() int64 {
+	return atomic.LoadInt64(&r.downloaded)
+}
+
+// GetAuthorization returns the Authorization header sent with the request.
+func (r *Request) GetAuthorization() string {
+	return r.auth
+}
+
+// SetAuthorization sets the Authorization header sent with the request by
+// the Surf downloader. Unlike the Header, it is not persisted, e.g. in the
+// failure history.
+func (r *Request) SetAuthorization(auth string) *Request {
+	r.auth = auth
+	return r
+}
+
+// GetSigner returns the signer called before each send attempt, or nil.
+func (r *Request) GetSigner() surfer.Signer {
+	return r.signer
+}
+
+// SetSigner sets the signer called before each send attempt.
+func (r *Request) SetSigner(signer surfer.Signer) *Request {
+	r.signer = signer
+	return r
+}
+
+// GetBodyMeter returns the meter of the response body, or nil.
+func (r *Request) GetBodyMeter() surfer.BodyMeter {
+	return r.meter
+}
+
+// SetBodyMeter sets the meter of the response body.
+func (r *Request) SetBodyMeter(meter surfer.BodyMeter) *Request {
+	r.meter = meter
+	return r
+}
+
+// GetSession returns the spider instance (name + Keyin) sending the request.
+func (r *Request) GetSession() string {
+	return r.session
+}
+
+// SetSession sets the spider instance sending the request; the Chrome
+// downloader keeps a browser context per instance.
+func (r *Request) SetSession(session string) *Request {
+	r.session = session
+	return r
+}
+
+// SetCookieJar sets the cookie jar used when cookies are enabled.
+func (r *Request) SetCookieJar(jar http.CookieJar) *Request {
+	r.jar = jar
+	return r
+}
+
 func (r *Request) MarshalJSON() ([]byte, error) {
 	for k, v := range r.Temp {
 		if r.TempIsJSON[k] {
@@ -376,6 +537,13 @@ func (r *Request) MarshalJSON() ([]byte, error) {
 		Priority      int
 		Reloadable    bool
 		DownloaderID  int
+		Actions       []surfer.ChromeAction `json:",omitempty"`
+		CaptureXHR    string                `json:",omitempty"`
+		Screenshot    bool                  `json:",omitempty"`
+		PDF           bool                  `json:",omitempty"`
+		TLS           *surfer.TLSOptions    `json:",omitempty"`
+		Profile       string                `json:",omitempty"`
+		HTTP3         bool                  `json:",omitempty"`
 	}{
 		Spider:        r.Spider,
 		URL:           r.URL,
@@ -394,6 +562,13 @@ func (r *Request) MarshalJSON() ([]byte, error) {
 		Priority:      r.Priority,
 		Reloadable:    r.Reloadable,
 		DownloaderID:  r.DownloaderID,
+		Actions:       r.Actions,
+		CaptureXHR:    r.CaptureXHR,
+		Screenshot:    r.Screenshot,
+		PDF:           r.PDF,
+		TLS:           r.TLS,
+		Profile:       r.Profile,
+		HTTP3:         r.HTTP3,
 	}
 	return json.Marshal(j)
 }
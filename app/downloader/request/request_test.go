@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/andeya/pholcus/app/downloader/surfer"
 )
 
 func TestReqTemp(t *testing.T) {
@@ -163,6 +165,29 @@ func TestSerializeUnSerialize(t *testing.T) {
 	}
 }
 
+func TestSerializeActions(t *testing.T) {
+	r := &Request{URL: "http://example.com", Rule: "r", DownloaderID: ChromeID}
+	r.Prepare()
+	r.AddAction(surfer.ChromeAction{Type: surfer.ActionClick, Selector: ".more", Times: 3, Pause: time.Second}).
+		SetCaptureXHR(`/api/`).
+		SetScreenshot(true).
+		SetPDF(true)
+
+	req := UnSerialize(r.Serialize().Unwrap()).Unwrap()
+	if len(req.GetActions()) != 1 {
+		t.Fatalf("Actions = %+v, want 1 action", req.GetActions())
+	}
+	if a := req.GetActions()[0]; a.Type != surfer.ActionClick || a.Selector != ".more" || a.Times != 3 || a.Pause != time.Second {
+		t.Errorf("Action = %+v", a)
+	}
+	if req.GetCaptureXHR() != `/api/` {
+		t.Errorf("CaptureXHR = %q, want %q", req.GetCaptureXHR(), `/api/`)
+	}
+	if !req.GetScreenshot() || !req.GetPDF() {
+		t.Errorf("Screenshot = %v, PDF = %v, want both true", req.GetScreenshot(), req.GetPDF())
+	}
+}
+
 func TestUnSerializeInvalid(t *testing.T) {
 	res := UnSerialize("invalid json {{{")
 	if res.IsOk() {
@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RandomProfile is the profile name that picks a random registered profile.
+const RandomProfile = "random"
+
+type (
+	// Profile is a coherent browser identity: a User-Agent together with the
+	// other headers that browser sends, so that they do not contradict each
+	// other.
+	Profile struct {
+		Name      string     // registry key, e.g. "chrome-windows"
+		Browser   string     // chrome, edge, firefox or safari
+		UserAgent string     // User-Agent header
+		Language  string     // Accept-Language header
+		Platform  string     // client hint platform: Windows, macOS or Linux
+		Brands    []Brand    // client hint brands; Chromium-based browsers only
+		Headers   [][]string // further {name, value} headers in the order the browser sends them
+	}
+
+	// Brand is a User-Agent client hint brand.
+	Brand struct {
+		Brand   string
+		Version string // major version
+	}
+)
+
+var (
+	profiles    []*Profile
+	profilesMap = map[string]*Profile{}
+	profilesMu  sync.RWMutex
+)
+
+func init() {
+	const (
+		chromeVer = "124"
+		chromeUA  = "Mozilla/5.0 (%s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/" + chromeVer + ".0.0.0 Safari/537.36"
+		firefoxUA = "Mozilla/5.0 (%s; rv:125.0) Gecko/20100101 Firefox/125.0"
+		safariUA  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15"
+		winOS     = "Windows NT 10.0; Win64; x64"
+		macOS     = "Macintosh; Intel Mac OS X 10_15_7"
+		linuxOS   = "X11; Linux x86_64"
+	)
+	chromeBrands := []Brand{{"Chromium", chromeVer}, {"Google Chrome", chromeVer}, {"Not-A.Brand", "99"}}
+	edgeBrands := []Brand{{"Chromium", chromeVer}, {"Microsoft Edge", chromeVer}, {"Not-A.Brand", "99"}}
+	for _, p := range []*Profile{
+		chromium("chrome-windows", "chrome", fmt.Sprintf(chromeUA, winOS), "Windows", chromeBrands),
+		chromium("chrome-macos", "chrome", fmt.Sprintf(chromeUA, macOS), "macOS", chromeBrands),
+		chromium("chrome-linux", "chrome", fmt.Sprintf(chromeUA, linuxOS), "Linux", chromeBrands),
+		chromium("edge-windows", "edge", fmt.Sprintf(chromeUA, winOS)+" Edg/"+chromeVer+".0.0.0", "Windows", edgeBrands),
+		gecko("firefox-windows", fmt.Sprintf(firefoxUA, winOS), "Windows"),
+		gecko("firefox-linux", fmt.Sprintf(firefoxUA, linuxOS), "Linux"),
+		{
+			Name:      "safari-macos",
+			Browser:   "safari",
+			UserAgent: safariUA,
+			Language:  "en-US,en;q=0.9",
+			Platform:  "macOS",
+			Headers: [][]string{
+				{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+				{"Sec-Fetch-Site", "none"},
+				{"Accept-Encoding", "gzip, deflate, br"},
+				{"Sec-Fetch-Mode", "navigate"},
+				{"Sec-Fetch-Dest", "document"},
+			},
+		},
+	} {
+		RegisterProfile(p)
+	}
+}
+
+// chromium returns the profile of a Chromium-based browser.
+func chromium(name, browser, ua, platform string, brands []Brand) *Profile {
+	hints := make([]string, len(brands))
+	for i, b := range brands {
+		hints[i] = fmt.Sprintf("%q;v=%q", b.Brand, b.Version)
+	}
+	return &Profile{
+		Name:      name,
+		Browser:   browser,
+		UserAgent: ua,
+		Language:  "en-US,en;q=0.9",
+		Platform:  platform,
+		Brands:    brands,
+		Headers: [][]string{
+			{"Sec-Ch-Ua", strings.Join(hints, ", ")},
+			{"Sec-Ch-Ua-Mobile", "?0"},
+			{"Sec-Ch-Ua-Platform", fmt.Sprintf("%q", platform)},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"},
+			{"Sec-Fetch-Site", "none"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Sec-Fetch-User", "?1"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Accept-Encoding", "gzip, deflate, br, zstd"},
+		},
+	}
+}
+
+// gecko returns a Firefox profile.
+func gecko(name, ua, platform string) *Profile {
+	return &Profile{
+		Name:      name,
+		Browser:   "firefox",
+		UserAgent: ua,
+		Language:  "en-US,en;q=0.5",
+		Platform:  platform,
+		Headers: [][]string{
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"},
+			{"Accept-Encoding", "gzip, deflate, br"},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Sec-Fetch-Site", "none"},
+			{"Sec-Fetch-User", "?1"},
+		},
+	}
+}
+
+// RegisterProfile adds or replaces a profile, e.g. one with another language.
+func RegisterProfile(p *Profile) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	if old, ok := profilesMap[p.Name]; ok {
+		for i, v := range profiles {
+			if v == old {
+				profiles[i] = p
+			}
+		}
+	} else {
+		profiles = append(profiles, p)
+	}
+	profilesMap[p.Name] = p
+}
+
+// LookupProfile returns the registered profile with the given name, or nil.
+func LookupProfile(name string) *Profile {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	return profilesMap[name]
+}
+
+// ProfileNames returns the names of all registered profiles.
+func ProfileNames() []string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// PickProfile returns a random registered profile other than except, if
+// there is another one.
+func PickProfile(except string) *Profile {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	if len(profiles) == 0 {
+		return nil
+	}
+	p := profiles[rand.Intn(len(profiles))]
+	for p.Name == except && len(profiles) > 1 {
+		p = profiles[rand.Intn(len(profiles))]
+	}
+	return p
+}
+
+// Apply sets the profile's headers on h, keeping those h already has.
+// Header order is not kept by net/http, which sorts header names.
+func (p *Profile) Apply(h http.Header) {
+	setDefault := func(k, v string) {
+		if v != "" && h.Get(k) == "" {
+			h.Set(k, v)
+		}
+	}
+	setDefault("User-Agent", p.UserAgent)
+	setDefault("Accept-Language", p.Language)
+	for _, kv := range p.Headers {
+		setDefault(kv[0], kv[1])
+	}
+}
+
+// Remove deletes the headers Apply set on h, so that another profile can
+// be applied.
+func (p *Profile) Remove(h http.Header) {
+	del := func(k, v string) {
+		if h.Get(k) == v {
+			h.Del(k)
+		}
+	}
+	del("User-Agent", p.UserAgent)
+	del("Accept-Language", p.Language)
+	for _, kv := range p.Headers {
+		del(kv[0], kv[1])
+	}
+}
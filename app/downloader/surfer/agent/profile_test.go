@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProfilesCoherent(t *testing.T) {
+	names := ProfileNames()
+	if len(names) == 0 {
+		t.Fatal("no profiles registered")
+	}
+	for _, name := range names {
+		p := LookupProfile(name)
+		if p == nil || p.Name != name || p.UserAgent == "" || p.Language == "" {
+			t.Errorf("profile %q incomplete: %+v", name, p)
+			continue
+		}
+		h := make(http.Header)
+		p.Apply(h)
+		hints := h.Get("Sec-Ch-Ua")
+		if (len(p.Brands) > 0) != (hints != "") {
+			t.Errorf("%s: Sec-Ch-Ua = %q with %d brands", name, hints, len(p.Brands))
+		}
+		for _, b := range p.Brands {
+			if !strings.Contains(hints, b.Brand) {
+				t.Errorf("%s: Sec-Ch-Ua %q lacks brand %q", name, hints, b.Brand)
+			}
+			if b.Brand == "Chromium" && !strings.Contains(p.UserAgent, "Chrome/"+b.Version+".") {
+				t.Errorf("%s: User-Agent %q contradicts Chromium %s", name, p.UserAgent, b.Version)
+			}
+		}
+		if hints != "" && !strings.Contains(h.Get("Sec-Ch-Ua-Platform"), p.Platform) {
+			t.Errorf("%s: Sec-Ch-Ua-Platform = %q, want %q", name, h.Get("Sec-Ch-Ua-Platform"), p.Platform)
+		}
+	}
+}
+
+func TestProfileApplyRemove(t *testing.T) {
+	p := LookupProfile("firefox-windows")
+	h := http.Header{"Accept-Language": {"zh-CN"}}
+	p.Apply(h)
+	if h.Get("User-Agent") != p.UserAgent || h.Get("Accept-Language") != "zh-CN" {
+		t.Errorf("Apply() headers = %v, want profile User-Agent and kept Accept-Language", h)
+	}
+	p.Remove(h)
+	if len(h) != 1 || h.Get("Accept-Language") != "zh-CN" {
+		t.Errorf("Remove() left %v, want only Accept-Language", h)
+	}
+}
+
+func TestPickProfile(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		if p := PickProfile("chrome-windows"); p == nil || p.Name == "chrome-windows" {
+			t.Fatalf("PickProfile(chrome-windows) = %v", p)
+		}
+	}
+	if LookupProfile(RandomProfile) != nil {
+		t.Error("RandomProfile must not name a registered profile")
+	}
+}
@@ -7,34 +7,44 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/andeya/gust/result"
+	"github.com/andeya/pholcus/app/downloader/surfer/agent"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	cdppage "github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 )
 
-// Chrome is a Chromium-based headless browser downloader that keeps a
-// single long-lived browser process. Each request opens a new tab that
-// first navigates to the target site's homepage (establishing session
-// cookies and a valid Referer) before loading the actual URL. This
+// Chrome is a Chromium-based headless browser downloader backed by a pool
+// of long-lived browser processes (see ChromeOptions). Each request leases a
+// tab that first navigates to the target site's homepage (establishing
+// session cookies and a valid Referer) before loading the actual URL. This
 // two-step approach reliably bypasses JS-based security verification
 // pages (e.g. Baidu CAPTCHA) that block direct URL access.
 type Chrome struct {
-	mu            sync.Mutex
-	CookieJar     *cookiejar.Jar
-	allocCtx      context.Context
-	allocCancel   context.CancelFunc
-	browserCtx    context.Context    // root tab – keeps the browser alive
-	browserCancel context.CancelFunc // closing this shuts down the browser
-	started       bool
+	CookieJar *cookiejar.Jar
+	pool      *chromePool
 }
 
+// NewChrome returns a Chrome downloader with the default ChromeOptions.
 func NewChrome(jar ...*cookiejar.Jar) Surfer {
-	c := &Chrome{}
+	return NewChromeWithOptions(ChromeOptions{}, jar...)
+}
+
+// NewChromeWithOptions returns a Chrome downloader whose browser pool is
+// configured by opts.
+func NewChromeWithOptions(opts ChromeOptions, jar ...*cookiejar.Jar) Surfer {
+	c := &Chrome{pool: newChromePool(opts)}
 	if len(jar) != 0 {
 		c.CookieJar = jar[0]
 	} else {
@@ -43,18 +53,6 @@ func NewChrome(jar ...*cookiejar.Jar) Surfer {
 	return c
 }
 
-// ensureBrowser lazily starts the shared Chrome process. Must be called
-// while c.mu is held.
-func (c *Chrome) ensureBrowser(ua string) {
-	if c.started {
-		return
-	}
-	opts := chromeAllocatorOpts(ua)
-	c.allocCtx, c.allocCancel = chromedp.NewExecAllocator(context.Background(), opts...)
-	c.browserCtx, c.browserCancel = chromedp.NewContext(c.allocCtx)
-	c.started = true
-}
-
 // chromeAllocatorOpts returns chromedp allocator options with
 // anti-detection tweaks applied.
 func chromeAllocatorOpts(ua string) []chromedp.ExecAllocatorOption {
@@ -76,6 +74,24 @@ func chromeAllocatorOpts(ua string) []chromedp.ExecAllocatorOption {
 	return opts
 }
 
+// emulateProfile makes the tab present the browser identity of p: its
+// User-Agent, language, platform and, for Chromium-based profiles, client
+// hints. Without brands Chrome sends no client hints, like Firefox and Safari.
+func emulateProfile(p *agent.Profile) chromedp.Action {
+	platforms := map[string]string{"Windows": "Win32", "macOS": "MacIntel", "Linux": "Linux x86_64"}
+	override := emulation.SetUserAgentOverride(p.UserAgent).
+		WithAcceptLanguage(p.Language).
+		WithPlatform(platforms[p.Platform])
+	if len(p.Brands) > 0 {
+		meta := &emulation.UserAgentMetadata{Platform: p.Platform, Architecture: "x86", Bitness: "64"}
+		for _, b := range p.Brands {
+			meta.Brands = append(meta.Brands, &emulation.UserAgentBrandVersion{Brand: b.Brand, Version: b.Version})
+		}
+		override = override.WithUserAgentMetadata(meta)
+	}
+	return override
+}
+
 // hideWebdriver removes the navigator.webdriver flag so that anti-bot
 // scripts cannot detect headless automation.
 func hideWebdriver() chromedp.Action {
@@ -88,42 +104,78 @@ func (c *Chrome) Download(req Request) (r result.Result[*http.Response]) {
 
 	param := NewParam(req).Unwrap()
 
-	c.mu.Lock()
-	c.ensureBrowser(param.header.Get("User-Agent"))
-	c.mu.Unlock()
+	timeout := c.pool.opts.TabTimeout
+	if t := req.GetConnTimeout(); t > 0 && t < timeout {
+		timeout = t
+	}
 
-	timeout := req.GetConnTimeout()
-	if timeout <= 0 {
-		timeout = 60 * time.Second
+	// Lease a tab; cookies are shared across tabs within the same
+	// browser context, which is per spider instance when isolation is enabled.
+	var session string
+	if sr, ok := req.(SessionRequest); ok {
+		session = sr.GetSession()
 	}
+	tab := c.pool.acquire(param.header.Get("User-Agent"), session).Unwrap()
+	defer tab.release()
 
-	// Open a new tab inside the shared browser; cookies are shared
-	// across tabs within the same browser context.
-	tabCtx, tabCancel := chromedp.NewContext(c.browserCtx)
+	tabCtx, tabCancel := tab.open(timeout)
 	defer tabCancel()
 
-	tabCtx, timeoutCancel := context.WithTimeout(tabCtx, timeout)
-	defer timeoutCancel()
+	// Share cookies with the jar of the request (the spider's) so that a
+	// login done in the browser reaches later requests and cookie exports.
+	var jar http.CookieJar
+	if param.enableCookie {
+		jar = c.CookieJar
+		if param.jar != nil {
+			jar = param.jar
+		}
+		if err := chromedp.Run(tabCtx, loadJarCookies(jar, param.url)); err != nil {
+			log.Printf("[W] Chrome: loading cookies for %s: %v", param.url, err)
+		}
+	}
+	if param.profile != nil {
+		result.RetVoid(chromedp.Run(tabCtx, emulateProfile(param.profile))).Unwrap()
+	}
+
+	var opts pageOptions
+	var recorder *xhrRecorder
+	if cr, ok := req.(ChromeRequest); ok {
+		opts = pageOptions{
+			actions:    cr.GetActions(),
+			screenshot: cr.GetScreenshot(),
+			pdf:        cr.GetPDF(),
+		}
+		if pattern := cr.GetCaptureXHR(); pattern != "" {
+			recorder = newXHRRecorder(result.Ret(regexp.Compile(pattern)).Unwrap())
+			recorder.listen(tabCtx)
+		}
+	}
 
 	retries := req.GetTryTimes()
 	if retries <= 0 {
 		retries = 1
 	}
 
-	var body string
+	var page renderedPage
 	var err error
 	for i := 0; i < retries; i++ {
 		if i != 0 {
 			time.Sleep(req.GetRetryPause())
 		}
+		recorder.reset()
 
-		body, err = tryDownload(tabCtx, req.GetURL())
+		page, err = tryDownload(tabCtx, req.GetURL(), opts)
 		if err != nil {
 			log.Printf("[W] Chrome attempt %d/%d for %s: %v", i+1, retries, req.GetURL(), err)
 			continue
 		}
 		break
 	}
+	if jar != nil && err == nil {
+		if err := chromedp.Run(tabCtx, saveJarCookies(jar, param.url)); err != nil {
+			log.Printf("[W] Chrome: saving cookies for %s: %v", param.url, err)
+		}
+	}
 
 	resp := &http.Response{
 		Request: &http.Request{},
@@ -141,13 +193,81 @@ func (c *Chrome) Download(req Request) (r result.Result[*http.Response]) {
 	} else {
 		resp.StatusCode = http.StatusOK
 		resp.Status = http.StatusText(http.StatusOK)
-		resp.Body = io.NopCloser(strings.NewReader(body))
+		resp.Body = param.meter(NewChromeBody(page.html, recorder.collect(), page.screenshot, page.pdf))
 	}
 
 	return result.Ok(resp)
 }
 
-// tryDownload navigates to the target URL and returns the HTML.
+// loadJarCookies copies the cookies jar holds for u into the tab.
+func loadJarCookies(jar http.CookieJar, u *url.URL) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		cookies := jar.Cookies(u)
+		if len(cookies) == 0 {
+			return nil
+		}
+		params := make([]*network.CookieParam, len(cookies))
+		for i, c := range cookies {
+			params[i] = &network.CookieParam{Name: c.Name, Value: c.Value, URL: u.String()}
+		}
+		return network.SetCookies(params).Do(ctx)
+	})
+}
+
+// saveJarCookies stores the cookies the tab holds for u in jar.
+func saveJarCookies(jar http.CookieJar, u *url.URL) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		cookies, err := network.GetCookies().WithURLs([]string{u.String()}).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if len(cookies) > 0 {
+			jar.SetCookies(u, httpCookies(cookies))
+		}
+		return nil
+	})
+}
+
+// httpCookies converts browser cookies to http.Cookies. Host-only cookies,
+// whose domain has no leading dot, are left without a Domain so that the
+// jar keeps them host-only.
+func httpCookies(cookies []*network.Cookie) []*http.Cookie {
+	hc := make([]*http.Cookie, len(cookies))
+	for i, c := range cookies {
+		h := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+		if strings.HasPrefix(c.Domain, ".") {
+			h.Domain = c.Domain
+		}
+		if !c.Session {
+			sec, frac := math.Modf(c.Expires)
+			h.Expires = time.Unix(int64(sec), int64(frac*1e9))
+		}
+		hc[i] = h
+	}
+	return hc
+}
+
+// pageOptions controls what a single rendering attempt does besides loading the page.
+type pageOptions struct {
+	actions    []ChromeAction
+	screenshot bool
+	pdf        bool
+}
+
+// renderedPage is the outcome of a successful rendering attempt.
+type renderedPage struct {
+	html       string
+	screenshot []byte // full-page PNG
+	pdf        []byte
+}
+
+// tryDownload navigates to the target URL and returns the rendered page.
 //
 // Every request follows a "homepage-first" pattern within the same tab:
 //  1. Navigate to the site homepage — this establishes session cookies,
@@ -158,7 +278,9 @@ func (c *Chrome) Download(req Request) (r result.Result[*http.Response]) {
 //
 // If verification is still detected after this two-step flow, the
 // function returns an error so the framework can retry later.
-func tryDownload(ctx context.Context, targetURL string) (string, error) {
+// Otherwise the scripted actions are run before the DOM, and optionally a
+// full-page screenshot and a PDF, are captured.
+func tryDownload(ctx context.Context, targetURL string, opts pageOptions) (page renderedPage, err error) {
 	homepage := ExtractHomepage(targetURL)
 
 	// Step 1: visit the homepage first to look like a real user.
@@ -169,7 +291,7 @@ func tryDownload(ctx context.Context, targetURL string) (string, error) {
 			chromedp.WaitReady("body"),
 			chromedp.Sleep(1*time.Second),
 		); err != nil {
-			return "", err
+			return page, err
 		}
 	}
 
@@ -179,7 +301,7 @@ func tryDownload(ctx context.Context, targetURL string) (string, error) {
 		chromedp.WaitReady("body"),
 		chromedp.Sleep(3*time.Second),
 	); err != nil {
-		return "", err
+		return page, err
 	}
 
 	// Check if we hit a verification page.
@@ -189,15 +311,182 @@ func tryDownload(ctx context.Context, targetURL string) (string, error) {
 		waitUntilNotVerification(ctx, 10*time.Second)
 
 		if isVerificationPage(ctx) {
-			return "", fmt.Errorf("blocked by security verification at %s", targetURL)
+			return page, fmt.Errorf("blocked by security verification at %s", targetURL)
+		}
+	}
+
+	if err = runActions(ctx, opts.actions); err != nil {
+		return page, err
+	}
+
+	if err = chromedp.Run(ctx, chromedp.OuterHTML("html", &page.html)); err != nil {
+		return page, err
+	}
+	if opts.screenshot {
+		if err = chromedp.Run(ctx, chromedp.FullScreenshot(&page.screenshot, 100)); err != nil {
+			return page, fmt.Errorf("screenshot: %w", err)
+		}
+	}
+	if opts.pdf {
+		if err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var perr error
+			page.pdf, _, perr = cdppage.PrintToPDF().WithPrintBackground(true).Do(ctx)
+			return perr
+		})); err != nil {
+			return page, fmt.Errorf("pdf: %w", err)
+		}
+	}
+	return page, nil
+}
+
+// runActions executes the scripted steps in order, each repetition bounded
+// by its own timeout.
+func runActions(ctx context.Context, actions []ChromeAction) error {
+	for i, a := range actions {
+		task, err := chromeActionTask(a)
+		if err != nil {
+			return err
+		}
+		times := a.Times
+		if times <= 0 {
+			times = 1
+		}
+		timeout := a.Timeout
+		if timeout <= 0 {
+			timeout = DefaultActionTimeout
 		}
+		for n := 0; n < times; n++ {
+			stepCtx, cancel := context.WithTimeout(ctx, timeout)
+			err = chromedp.Run(stepCtx, task)
+			cancel()
+			if err != nil {
+				if a.Optional {
+					break
+				}
+				return fmt.Errorf("chrome action %d (%s %s): %v", i+1, a.Type, a.Selector, err)
+			}
+			if a.Pause > 0 {
+				if err = chromedp.Run(ctx, chromedp.Sleep(a.Pause)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// chromeActionTask translates a ChromeAction into a chromedp action.
+func chromeActionTask(a ChromeAction) (chromedp.Action, error) {
+	switch a.Type {
+	case ActionWait:
+		return chromedp.WaitVisible(a.Selector, chromedp.ByQuery), nil
+	case ActionClick:
+		return chromedp.Click(a.Selector, chromedp.ByQuery), nil
+	case ActionScroll:
+		if a.Selector != "" {
+			return chromedp.ScrollIntoView(a.Selector, chromedp.ByQuery), nil
+		}
+		return chromedp.Evaluate(`window.scrollTo(0, document.body.scrollHeight)`, nil), nil
+	case ActionInput:
+		return chromedp.SendKeys(a.Selector, a.Value, chromedp.ByQuery), nil
+	case ActionEval:
+		return chromedp.Evaluate(a.Value, nil), nil
+	case ActionSleep:
+		return chromedp.ActionFunc(func(context.Context) error { return nil }), nil
+	}
+	return nil, fmt.Errorf("unknown chrome action type %q", a.Type)
+}
+
+// xhrRecorder records XHR/fetch responses whose URL matches pattern.
+type xhrRecorder struct {
+	pattern  *regexp.Regexp
+	mu       sync.Mutex
+	methods  map[network.RequestID]string
+	pending  map[network.RequestID]*CapturedResponse
+	captured []CapturedResponse
+	wg       sync.WaitGroup
+}
+
+func newXHRRecorder(pattern *regexp.Regexp) *xhrRecorder {
+	return &xhrRecorder{
+		pattern: pattern,
+		methods: make(map[network.RequestID]string),
+		pending: make(map[network.RequestID]*CapturedResponse),
 	}
+}
+
+// listen subscribes to the network events of the tab.
+func (x *xhrRecorder) listen(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if x.pattern.MatchString(ev.Request.URL) {
+				x.mu.Lock()
+				x.methods[ev.RequestID] = ev.Request.Method
+				x.mu.Unlock()
+			}
+		case *network.EventResponseReceived:
+			if ev.Type != network.ResourceTypeXHR && ev.Type != network.ResourceTypeFetch {
+				return
+			}
+			if !x.pattern.MatchString(ev.Response.URL) {
+				return
+			}
+			x.mu.Lock()
+			x.pending[ev.RequestID] = &CapturedResponse{
+				URL:      ev.Response.URL,
+				Method:   x.methods[ev.RequestID],
+				Status:   int(ev.Response.Status),
+				MIMEType: ev.Response.MimeType,
+			}
+			x.mu.Unlock()
+		case *network.EventLoadingFinished:
+			x.mu.Lock()
+			c, ok := x.pending[ev.RequestID]
+			delete(x.pending, ev.RequestID)
+			delete(x.methods, ev.RequestID)
+			x.mu.Unlock()
+			if !ok {
+				return
+			}
+			// Event handlers must not block, so the body is fetched asynchronously.
+			x.wg.Add(1)
+			go func(id network.RequestID) {
+				defer x.wg.Done()
+				body, err := network.GetResponseBody(id).Do(cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target))
+				if err != nil {
+					return
+				}
+				c.Body = string(body)
+				x.mu.Lock()
+				x.captured = append(x.captured, *c)
+				x.mu.Unlock()
+			}(ev.RequestID)
+		}
+	})
+}
+
+// reset drops everything recorded by a previous attempt.
+func (x *xhrRecorder) reset() {
+	if x == nil {
+		return
+	}
+	x.wg.Wait()
+	x.mu.Lock()
+	x.pending = make(map[network.RequestID]*CapturedResponse)
+	x.captured = nil
+	x.mu.Unlock()
+}
 
-	var body string
-	if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &body)); err != nil {
-		return "", err
+// collect waits for pending body fetches and returns the captured responses.
+func (x *xhrRecorder) collect() []CapturedResponse {
+	if x == nil {
+		return nil
 	}
-	return body, nil
+	x.wg.Wait()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.captured
 }
 
 // waitUntilNotVerification polls the page title, returning as soon as
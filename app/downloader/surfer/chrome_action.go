@@ -0,0 +1,105 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// Chrome action types.
+const (
+	ActionWait   = "wait"   // wait until Selector is visible
+	ActionClick  = "click"  // click Selector
+	ActionScroll = "scroll" // scroll Selector into view, or to the page bottom when Selector is empty
+	ActionInput  = "input"  // type Value into Selector
+	ActionEval   = "eval"   // evaluate Value as JavaScript
+	ActionSleep  = "sleep"  // do nothing for Pause
+
+	DefaultActionTimeout = 30 * time.Second // default timeout of a single action step
+)
+
+type (
+	// ChromeAction is one scripted browser step executed by the Chrome
+	// downloader after the page has loaded and before the DOM is captured.
+	ChromeAction struct {
+		Type     string        // one of the Action* constants
+		Selector string        // CSS selector the step applies to
+		Value    string        // text for input, script for eval
+		Times    int           // repeat count, default 1 (e.g. click "load more" N times)
+		Pause    time.Duration // wait after each repetition
+		Timeout  time.Duration // per-repetition timeout, default DefaultActionTimeout
+		Optional bool          // when true, a failing step is skipped instead of failing the download
+	}
+
+	// CapturedResponse is an XHR/fetch response recorded by the Chrome downloader.
+	CapturedResponse struct {
+		URL      string
+		Method   string
+		Status   int
+		MIMEType string
+		Body     string
+	}
+
+	// ChromeRequest is optionally implemented by a Request to script the
+	// Chrome downloader.
+	ChromeRequest interface {
+		// steps executed in order on the loaded page
+		GetActions() []ChromeAction
+		// regexp of XHR/fetch URLs whose responses are captured; empty disables capturing
+		GetCaptureXHR() string
+		// capture a full-page PNG screenshot of the rendered page
+		GetScreenshot() bool
+		// print the rendered page to PDF
+		GetPDF() bool
+	}
+
+	// ChromeBody is the response body returned by the Chrome downloader.
+	// Besides the rendered HTML it carries the captured XHR responses and
+	// the requested screenshot and PDF.
+	ChromeBody struct {
+		io.ReadCloser
+		captures   []CapturedResponse
+		screenshot []byte
+		pdf        []byte
+	}
+)
+
+// NewChromeBody returns a body of the rendered html carrying the captured
+// responses, screenshot and PDF.
+func NewChromeBody(html string, captures []CapturedResponse, screenshot, pdf []byte) *ChromeBody {
+	return &ChromeBody{
+		ReadCloser: io.NopCloser(strings.NewReader(html)),
+		captures:   captures,
+		screenshot: screenshot,
+		pdf:        pdf,
+	}
+}
+
+// Captures returns the XHR/fetch responses recorded while rendering the page.
+func (b *ChromeBody) Captures() []CapturedResponse {
+	return b.captures
+}
+
+// Screenshot returns the full-page PNG screenshot, nil if not requested.
+func (b *ChromeBody) Screenshot() []byte {
+	return b.screenshot
+}
+
+// PDF returns the page printed to PDF, nil if not requested.
+func (b *ChromeBody) PDF() []byte {
+	return b.pdf
+}
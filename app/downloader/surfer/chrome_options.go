@@ -0,0 +1,53 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import "time"
+
+// Chrome pool defaults.
+const (
+	DefaultChromeMaxTabs    = 4               // default max tabs open at once
+	DefaultChromeBrowsers   = 1               // default number of browser processes
+	DefaultChromeTabTimeout = 2 * time.Minute // default lifetime limit of a tab
+)
+
+// ChromeOptions configures the browser pool of the Chrome downloader.
+type ChromeOptions struct {
+	MaxTabs      int           // max tabs open at once across all browsers, default DefaultChromeMaxTabs
+	Browsers     int           // browser processes tabs are spread over, default DefaultChromeBrowsers
+	RecycleAfter int           // restart a browser after it has served this many pages, 0 never
+	TabTimeout   time.Duration // upper bound of a tab's lifetime, default DefaultChromeTabTimeout
+	Isolate      bool          // give every spider instance its own browser context, keeping cookies and storage apart
+}
+
+// withDefaults returns a copy of o with zero values replaced by defaults.
+func (o ChromeOptions) withDefaults() ChromeOptions {
+	if o.MaxTabs <= 0 {
+		o.MaxTabs = DefaultChromeMaxTabs
+	}
+	if o.Browsers <= 0 {
+		o.Browsers = DefaultChromeBrowsers
+	}
+	if o.Browsers > o.MaxTabs {
+		o.Browsers = o.MaxTabs
+	}
+	if o.RecycleAfter < 0 {
+		o.RecycleAfter = 0
+	}
+	if o.TabTimeout <= 0 {
+		o.TabTimeout = DefaultChromeTabTimeout
+	}
+	return o
+}
@@ -0,0 +1,366 @@
+//go:build !cover
+
+package surfer
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/andeya/gust/result"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// chromeBrowser is one browser process of the pool.
+type chromeBrowser struct {
+	ctx      context.Context                 // root tab, keeps the browser alive
+	cancel   context.CancelFunc              // shuts the browser down
+	lost     <-chan struct{}                 // closed when the connection to the browser is lost
+	mu       sync.Mutex                      // guards profiles
+	profiles map[string]cdp.BrowserContextID // browser contexts by session
+	pages    int                             // pages served so far
+	inflight int                             // tabs currently open
+	retired  bool                            // takes no new tabs; closed once the last one is released
+	saving   bool                            // retired, its cookies are being saved for its successor
+}
+
+// startChromeBrowser launches a browser process with the given User-Agent.
+func startChromeBrowser(ua string) result.Result[*chromeBrowser] {
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), chromeAllocatorOpts(ua)...)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		allocCancel()
+		return result.TryErr[*chromeBrowser](err)
+	}
+	return result.Ok(&chromeBrowser{
+		ctx:      ctx,
+		cancel:   func() { cancel(); allocCancel() },
+		lost:     chromedp.FromContext(ctx).Browser.LostConnection,
+		profiles: make(map[string]cdp.BrowserContextID),
+	})
+}
+
+// alive reports whether the browser is still reachable.
+func (b *chromeBrowser) alive() bool {
+	select {
+	case <-b.lost:
+		return false
+	case <-b.ctx.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// executor returns a context that runs browser-level commands.
+func (b *chromeBrowser) executor() context.Context {
+	if c := chromedp.FromContext(b.ctx); c != nil && c.Browser != nil {
+		return cdp.WithExecutor(b.ctx, c.Browser)
+	}
+	return b.ctx
+}
+
+// profile returns the browser context of the session, creating it on first
+// use and seeding it with cookies.
+func (b *chromeBrowser) profile(session string, cookies func() []*network.Cookie) result.Result[cdp.BrowserContextID] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if id, ok := b.profiles[session]; ok {
+		return result.Ok(id)
+	}
+	id, err := target.CreateBrowserContext().Do(b.executor())
+	if err != nil {
+		return result.TryErr[cdp.BrowserContextID](err)
+	}
+	b.profiles[session] = id
+	b.setCookies(id, cookies())
+	return result.Ok(id)
+}
+
+// setCookies copies cookies into the browser context id, "" being the default one.
+func (b *chromeBrowser) setCookies(id cdp.BrowserContextID, cookies []*network.Cookie) {
+	if len(cookies) == 0 {
+		return
+	}
+	params := make([]*network.CookieParam, 0, len(cookies))
+	for _, c := range cookies {
+		p := &network.CookieParam{
+			Name:         c.Name,
+			Value:        c.Value,
+			Domain:       c.Domain,
+			Path:         c.Path,
+			Secure:       c.Secure,
+			HTTPOnly:     c.HTTPOnly,
+			SameSite:     c.SameSite,
+			Priority:     c.Priority,
+			SourceScheme: c.SourceScheme,
+			SourcePort:   c.SourcePort,
+			PartitionKey: c.PartitionKey,
+		}
+		if !c.Session {
+			sec, frac := math.Modf(c.Expires)
+			t := cdp.TimeSinceEpoch(time.Unix(int64(sec), int64(frac*1e9)))
+			p.Expires = &t
+		}
+		params = append(params, p)
+	}
+	set := storage.SetCookies(params)
+	if id != "" {
+		set = set.WithBrowserContextID(id)
+	}
+	if err := set.Do(b.executor()); err != nil {
+		log.Printf("[W] Chrome: restoring cookies: %v", err)
+	}
+}
+
+// snapshotCookies returns the cookies of the default browser context (key "")
+// and of every session profile.
+func (b *chromeBrowser) snapshotCookies() map[string][]*network.Cookie {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snap := make(map[string][]*network.Cookie, len(b.profiles)+1)
+	if cookies, err := storage.GetCookies().Do(b.executor()); err == nil {
+		snap[""] = cookies
+	}
+	for session, id := range b.profiles {
+		if cookies, err := storage.GetCookies().WithBrowserContextID(id).Do(b.executor()); err == nil {
+			snap[session] = cookies
+		}
+	}
+	return snap
+}
+
+// chromePool spreads tabs over a bounded set of browser processes and
+// replaces browsers that crashed or served RecycleAfter pages. Cookies of a
+// recycled browser are carried over to its successor. Browsers are started
+// and talked to without holding mu, so a slow browser blocks no other tab.
+type chromePool struct {
+	opts     ChromeOptions
+	start    func(ua string) result.Result[*chromeBrowser]
+	tabs     chan struct{} // one token per open tab
+	mu       sync.Mutex
+	changed  *sync.Cond // on mu, broadcast when a browser started or a save ended
+	slots    []*chromeBrowser
+	starting []bool                       // slots reserved for a browser being started
+	saving   int                          // retired browsers whose cookies are being saved
+	cookies  map[string][]*network.Cookie // taken from recycled browsers, keyed by session
+}
+
+func newChromePool(opts ChromeOptions) *chromePool {
+	opts = opts.withDefaults()
+	p := &chromePool{
+		opts:     opts,
+		start:    startChromeBrowser,
+		tabs:     make(chan struct{}, opts.MaxTabs),
+		slots:    make([]*chromeBrowser, opts.Browsers),
+		starting: make([]bool, opts.Browsers),
+		cookies:  make(map[string][]*network.Cookie),
+	}
+	p.changed = sync.NewCond(&p.mu)
+	return p
+}
+
+// chromeTab is a lease on one tab of a pooled browser.
+type chromeTab struct {
+	pool    *chromePool
+	browser *chromeBrowser
+	profile cdp.BrowserContextID // empty for the browser's default context
+}
+
+// acquire waits for a free tab and leases it on the least busy browser.
+// With Isolate set, the tab opens in the browser context of session, the
+// spider instance (name + Keyin) sending the request.
+func (p *chromePool) acquire(ua, session string) (r result.Result[*chromeTab]) {
+	p.tabs <- struct{}{}
+	defer func() {
+		if r.IsErr() {
+			<-p.tabs
+		}
+	}()
+
+	br := p.lease(ua)
+	if br.IsErr() {
+		return result.TryErr[*chromeTab](br.UnwrapErr())
+	}
+	b := br.Unwrap()
+	tab := &chromeTab{pool: p, browser: b}
+	if p.opts.Isolate && session != "" {
+		id := b.profile(session, func() []*network.Cookie {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return p.cookies[session]
+		})
+		if id.IsErr() {
+			p.done(b)
+			return result.TryErr[*chromeTab](id.UnwrapErr())
+		}
+		tab.profile = id.Unwrap()
+	}
+	return result.Ok(tab)
+}
+
+// lease picks the browser for the next tab and counts the tab on it. A new
+// browser is started in a slot reserved under p.mu but outside of it.
+func (p *chromePool) lease(ua string) result.Result[*chromeBrowser] {
+	p.mu.Lock()
+	best, free := p.pick()
+	for best == nil && free < 0 {
+		// Every slot has a browser starting; wait for one of them.
+		p.changed.Wait()
+		best, free = p.pick()
+	}
+	if best == nil || free >= 0 && best.inflight > 0 {
+		p.starting[free] = true
+		// The successor of a recycled browser starts with its cookies.
+		for p.saving > 0 {
+			p.changed.Wait()
+		}
+		cookies := p.cookies[""]
+		p.mu.Unlock()
+
+		r := p.start(ua)
+		if r.IsOk() && !p.opts.Isolate {
+			r.Unwrap().setCookies("", cookies)
+		}
+
+		p.mu.Lock()
+		p.starting[free] = false
+		p.changed.Broadcast()
+		if r.IsOk() {
+			best = r.Unwrap()
+			p.slots[free] = best
+		} else if best, _ = p.pick(); best != nil {
+			log.Printf("[W] Chrome: starting browser: %v", r.UnwrapErr())
+		} else {
+			p.mu.Unlock()
+			return r
+		}
+	}
+	save := p.use(best)
+	p.mu.Unlock()
+	if save {
+		p.saveCookies(best)
+	}
+	return result.Ok(best)
+}
+
+// pick returns the least busy live browser, nil if there is none, and a free
+// slot, -1 if there is none. A browser may be started in the slot if the
+// returned one is busy. Must be called with p.mu held.
+func (p *chromePool) pick() (best *chromeBrowser, free int) {
+	free = -1
+	for i, b := range p.slots {
+		if p.starting[i] {
+			continue
+		}
+		if b != nil && !b.retired && !b.alive() {
+			log.Printf("[W] Chrome: lost connection to browser, restarting")
+			p.retire(b)
+		}
+		if b == nil || b.retired {
+			p.slots[i] = nil
+			if free < 0 {
+				free = i
+			}
+			continue
+		}
+		if best == nil || b.inflight < best.inflight {
+			best = b
+		}
+	}
+	if best != nil && best.inflight == 0 {
+		free = -1
+	}
+	return best, free
+}
+
+// use counts a new tab on b and reports whether b was thereby retired for
+// recycling; the caller then saves its cookies with saveCookies after
+// releasing p.mu. Must be called with p.mu held.
+func (p *chromePool) use(b *chromeBrowser) (save bool) {
+	b.inflight++
+	b.pages++
+	if p.opts.RecycleAfter > 0 && b.pages >= p.opts.RecycleAfter {
+		log.Printf("[I] Chrome: recycling browser after %d pages", b.pages)
+		return p.retire(b)
+	}
+	return false
+}
+
+// retire stops b from taking new tabs and shuts it down once idle. It
+// reports whether b is alive, in which case its cookies are to be saved for
+// its successor with saveCookies. Must be called with p.mu held.
+func (p *chromePool) retire(b *chromeBrowser) (save bool) {
+	if b.retired {
+		return false
+	}
+	b.retired = true
+	if b.alive() {
+		b.saving = true
+		p.saving++
+		return true
+	}
+	if b.inflight == 0 {
+		b.cancel()
+	}
+	return false
+}
+
+// saveCookies keeps the cookies of the retired b for its successor and shuts
+// b down if idle. Must be called without p.mu held.
+func (p *chromePool) saveCookies(b *chromeBrowser) {
+	snap := b.snapshotCookies()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for session, cookies := range snap {
+		p.cookies[session] = cookies
+	}
+	b.saving = false
+	p.saving--
+	p.changed.Broadcast()
+	if b.inflight == 0 {
+		b.cancel()
+	}
+}
+
+// open creates the tab, bounded by timeout.
+func (t *chromeTab) open(timeout time.Duration) (context.Context, context.CancelFunc) {
+	var opts []chromedp.ContextOption
+	if t.profile != "" {
+		opts = append(opts, chromedp.WithExistingBrowserContext(t.profile))
+	}
+	ctx, cancel := chromedp.NewContext(t.browser.ctx, opts...)
+	ctx, timeoutCancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		timeoutCancel()
+		cancel()
+	}
+}
+
+// release returns the lease.
+func (t *chromeTab) release() {
+	t.pool.done(t.browser)
+	<-t.pool.tabs
+}
+
+// done uncounts a tab on b; a crashed browser is retired here so the next
+// acquire starts a replacement.
+func (p *chromePool) done(b *chromeBrowser) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b.inflight--
+	if !b.alive() && !b.retired {
+		log.Printf("[W] Chrome: lost connection to browser, restarting")
+		b.retired = true
+	}
+	if b.retired && b.inflight == 0 && !b.saving {
+		b.cancel()
+	}
+}
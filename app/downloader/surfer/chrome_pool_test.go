@@ -0,0 +1,162 @@
+//go:build !cover
+
+package surfer
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/andeya/gust/result"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+)
+
+// fakeBrowsers replaces browser start-up with in-memory browsers; closing
+// a browser's channel in lost simulates a crash.
+func fakeBrowsers(p *chromePool) (started *[]*chromeBrowser, lost map[*chromeBrowser]chan struct{}) {
+	started = new([]*chromeBrowser)
+	lost = make(map[*chromeBrowser]chan struct{})
+	p.start = func(string) result.Result[*chromeBrowser] {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := make(chan struct{})
+		b := &chromeBrowser{
+			ctx:      ctx,
+			cancel:   cancel,
+			lost:     ch,
+			profiles: make(map[string]cdp.BrowserContextID),
+		}
+		*started = append(*started, b)
+		lost[b] = ch
+		return result.Ok(b)
+	}
+	return started, lost
+}
+
+func TestChromePoolBoundsTabs(t *testing.T) {
+	p := newChromePool(ChromeOptions{MaxTabs: 2, Browsers: 2})
+	started, _ := fakeBrowsers(p)
+
+	t1 := p.acquire("", "").Unwrap()
+	t2 := p.acquire("", "").Unwrap()
+	if len(*started) != 2 || t1.browser == t2.browser {
+		t.Fatalf("busy browser reused: started %d browsers", len(*started))
+	}
+
+	acquired := make(chan *chromeTab)
+	go func() { acquired <- p.acquire("", "").Unwrap() }()
+	select {
+	case <-acquired:
+		t.Fatal("third tab acquired beyond MaxTabs")
+	case <-time.After(50 * time.Millisecond):
+	}
+	t1.release()
+	t3 := <-acquired
+	if t3.browser != t1.browser {
+		t.Error("idle browser not reused")
+	}
+	t2.release()
+	t3.release()
+	if len(*started) != 2 {
+		t.Errorf("started %d browsers, want 2", len(*started))
+	}
+}
+
+func TestChromePoolRecycle(t *testing.T) {
+	p := newChromePool(ChromeOptions{MaxTabs: 2, RecycleAfter: 2})
+	started, _ := fakeBrowsers(p)
+
+	t1 := p.acquire("", "").Unwrap()
+	t1.release()
+	t2 := p.acquire("", "").Unwrap()
+	first := (*started)[0]
+	if !first.retired {
+		t.Fatal("browser not retired after RecycleAfter pages")
+	}
+	if first.ctx.Err() != nil {
+		t.Fatal("retired browser closed while a tab is open")
+	}
+	t3 := p.acquire("", "").Unwrap()
+	if t3.browser == first || len(*started) != 2 {
+		t.Fatal("retired browser received a new tab")
+	}
+	t2.release()
+	if first.ctx.Err() == nil {
+		t.Error("retired browser not closed after its last tab")
+	}
+	t3.release()
+}
+
+func TestChromePoolReplacesCrashedBrowser(t *testing.T) {
+	p := newChromePool(ChromeOptions{})
+	started, lost := fakeBrowsers(p)
+
+	t1 := p.acquire("", "").Unwrap()
+	close(lost[t1.browser])
+	t1.release()
+	if t1.browser.ctx.Err() == nil {
+		t.Error("crashed browser not closed")
+	}
+	t2 := p.acquire("", "").Unwrap()
+	defer t2.release()
+	if t2.browser == t1.browser || len(*started) != 2 {
+		t.Error("crashed browser not replaced")
+	}
+}
+
+func TestChromePoolStartsBrowserUnlocked(t *testing.T) {
+	p := newChromePool(ChromeOptions{MaxTabs: 3, Browsers: 2})
+	fakeBrowsers(p)
+	t1 := p.acquire("", "").Unwrap()
+
+	// The second tab starts a browser that hangs.
+	start, unblock := p.start, make(chan struct{})
+	p.start = func(ua string) result.Result[*chromeBrowser] {
+		<-unblock
+		return start(ua)
+	}
+	acquired := make(chan *chromeTab)
+	go func() { acquired <- p.acquire("", "").Unwrap() }()
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan *chromeTab)
+	go func() {
+		t1.release()
+		done <- p.acquire("", "").Unwrap()
+	}()
+	select {
+	case t3 := <-done:
+		if t3.browser != t1.browser {
+			t.Error("idle browser not reused while another starts")
+		}
+		t3.release()
+	case <-time.After(time.Second):
+		t.Fatal("release and acquire blocked by a starting browser")
+	}
+	close(unblock)
+	t2 := <-acquired
+	if t2.browser == t1.browser {
+		t.Error("tab not opened in the started browser")
+	}
+	t2.release()
+}
+
+func TestHTTPCookiesInJar(t *testing.T) {
+	u, _ := url.Parse("https://www.example.com/login")
+	jar := NewJar()
+	jar.SetCookies(u, httpCookies([]*network.Cookie{
+		{Name: "sid", Value: "1", Domain: "www.example.com", Path: "/", Session: true},
+		{Name: "pref", Value: "2", Domain: ".example.com", Path: "/", Expires: float64(time.Now().Add(time.Hour).Unix())},
+	}))
+	got := make(map[string]StoredCookie)
+	for _, c := range jar.Export() {
+		got[c.Name] = c
+	}
+	if c := got["sid"]; c.Value != "1" || !c.HostOnly || !c.Expires.IsZero() {
+		t.Errorf("sid = %+v, want host-only session cookie", c)
+	}
+	if c := got["pref"]; c.Value != "2" || c.HostOnly || c.Domain != "example.com" || c.Expires.IsZero() {
+		t.Errorf("pref = %+v, want persistent cookie for example.com", c)
+	}
+}
@@ -15,6 +15,10 @@ type ChromeStub struct {
 }
 
 func NewChrome(jar ...*cookiejar.Jar) Surfer {
+	return NewChromeWithOptions(ChromeOptions{}, jar...)
+}
+
+func NewChromeWithOptions(opts ChromeOptions, jar ...*cookiejar.Jar) Surfer {
 	c := &ChromeStub{}
 	if len(jar) != 0 {
 		c.CookieJar = jar[0]
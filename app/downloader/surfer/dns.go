@@ -0,0 +1,405 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/andeya/gust/option"
+)
+
+// DefaultDNSMaxAge is the cache time of DNS answers if DNSOptions.MaxAge is not set.
+const DefaultDNSMaxAge = 5 * time.Minute
+
+// dnsTimeout bounds one query to a DNS server.
+const dnsTimeout = 5 * time.Second
+
+type (
+	// DNSOptions configures how Surf resolves host names. With a proxy, only
+	// the proxy's own host name is resolved here; target hosts are resolved
+	// by the proxy, so Hosts does not apply to them.
+	DNSOptions struct {
+		Servers []string            // DNS servers, ip or ip:port; empty uses the system resolver
+		MaxAge  time.Duration       // max cache time of an answer; answers of the system resolver carry no TTL and are cached this long
+		Hosts   map[string][]string // static host name -> IP overrides, like /etc/hosts
+	}
+
+	// DNSStats counts the lookups of the DNS cache.
+	DNSStats struct {
+		Hits    uint64 // answered from the cache or Hosts
+		Misses  uint64 // resolved
+		Errors  uint64 // failed to resolve
+		Entries int    // cached host names
+	}
+
+	// DnsCache resolves and caches host names for Surf, keeping answers no
+	// longer than their TTL.
+	DnsCache struct {
+		mu      sync.RWMutex
+		opts    DNSOptions
+		hosts   map[string][]net.IP
+		entries map[string]dnsEntry
+		hits    uint64
+		misses  uint64
+		errors  uint64
+	}
+
+	dnsEntry struct {
+		ips     []net.IP
+		expires time.Time
+	}
+)
+
+var dnsCache = &DnsCache{}
+
+// SetDNS replaces the DNS options of Surf and clears its DNS cache.
+func SetDNS(opts DNSOptions) {
+	dnsCache.SetOptions(opts)
+}
+
+// GetDNSStats returns the statistics of Surf's DNS cache.
+func GetDNSStats() DNSStats {
+	return dnsCache.Stats()
+}
+
+// SetOptions replaces the options and clears the cache.
+func (d *DnsCache) SetOptions(opts DNSOptions) {
+	hosts := make(map[string][]net.IP, len(opts.Hosts))
+	for host, addrs := range opts.Hosts {
+		for _, a := range addrs {
+			if ip := net.ParseIP(strings.TrimSpace(a)); ip != nil {
+				key := dnsKey(host)
+				hosts[key] = append(hosts[key], ip)
+			}
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opts, d.hosts, d.entries = opts, hosts, nil
+}
+
+// Stats returns the lookup statistics.
+func (d *DnsCache) Stats() DNSStats {
+	d.mu.RLock()
+	n := len(d.entries)
+	d.mu.RUnlock()
+	return DNSStats{
+		Hits:    atomic.LoadUint64(&d.hits),
+		Misses:  atomic.LoadUint64(&d.misses),
+		Errors:  atomic.LoadUint64(&d.errors),
+		Entries: n,
+	}
+}
+
+// Reg overrides the addresses of the host of addr with the IP of ipPort,
+// like an entry of DNSOptions.Hosts; connections use the port of addr.
+//
+// Deprecated: use DNSOptions.Hosts.
+func (d *DnsCache) Reg(addr, ipPort string) {
+	ip, _, err := net.SplitHostPort(ipPort)
+	if err != nil || net.ParseIP(ip) == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hosts == nil {
+		d.hosts = make(map[string][]net.IP)
+	}
+	d.hosts[dnsKey(hostOf(addr))] = []net.IP{net.ParseIP(ip)}
+}
+
+// Del removes the override and the cached addresses of the host of addr.
+//
+// Deprecated: use DNSOptions.Hosts.
+func (d *DnsCache) Del(addr string) {
+	key := dnsKey(hostOf(addr))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.hosts, key)
+	delete(d.entries, key)
+}
+
+// Query returns the first address of the host of addr, from the overrides
+// or the cache, joined with the port of addr.
+//
+// Deprecated: use Lookup.
+func (d *DnsCache) Query(addr string) option.Option[string] {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return option.None[string]()
+	}
+	ips := d.cached(host)
+	if len(ips) == 0 {
+		return option.None[string]()
+	}
+	return option.Some(net.JoinHostPort(ips[0].String(), port))
+}
+
+// Lookup returns the IP addresses of host, IPv4 first.
+func (d *DnsCache) Lookup(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if ips := d.cached(host); len(ips) > 0 {
+		atomic.AddUint64(&d.hits, 1)
+		return ips, nil
+	}
+	atomic.AddUint64(&d.misses, 1)
+	d.mu.RLock()
+	servers := d.opts.Servers
+	d.mu.RUnlock()
+	maxAge := d.maxAge()
+
+	var (
+		ips []net.IP
+		ttl = maxAge
+		err error
+	)
+	if len(servers) > 0 {
+		ips, ttl, err = queryServers(ctx, servers, host)
+	} else {
+		var addrs []net.IPAddr
+		if addrs, err = net.DefaultResolver.LookupIPAddr(ctx, host); err == nil {
+			for _, a := range addrs {
+				ips = append(ips, a.IP)
+			}
+		}
+	}
+	if err != nil {
+		atomic.AddUint64(&d.errors, 1)
+		return nil, err
+	}
+	sort.SliceStable(ips, func(i, j int) bool { return ips[i].To4() != nil && ips[j].To4() == nil })
+	d.store(host, ips, min(ttl, maxAge))
+	return ips, nil
+}
+
+// dial connects to addr, trying each address of its host in turn. The
+// host is dropped from the cache if none of them answers.
+func (d *DnsCache) dial(network, addr string, timeout time.Duration) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ips, err := d.Lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		var c net.Conn
+		if c, err = net.DialTimeout(network, net.JoinHostPort(ip.String(), port), timeout); err == nil {
+			return c, nil
+		}
+	}
+	d.forget(host)
+	return nil, err
+}
+
+// forget drops the cached addresses of host; its overrides are kept.
+func (d *DnsCache) forget(host string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.entries, dnsKey(host))
+}
+
+// cached returns the unexpired addresses of host from Hosts or the cache.
+func (d *DnsCache) cached(host string) []net.IP {
+	key := dnsKey(host)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if ips, ok := d.hosts[key]; ok {
+		return ips
+	}
+	if e, ok := d.entries[key]; ok && time.Now().Before(e.expires) {
+		return e.ips
+	}
+	return nil
+}
+
+func (d *DnsCache) store(host string, ips []net.IP, ttl time.Duration) {
+	if len(ips) == 0 || ttl <= 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.entries == nil {
+		d.entries = make(map[string]dnsEntry)
+	}
+	d.entries[dnsKey(host)] = dnsEntry{ips: ips, expires: time.Now().Add(ttl)}
+}
+
+func (d *DnsCache) maxAge() time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.opts.MaxAge > 0 {
+		return d.opts.MaxAge
+	}
+	return DefaultDNSMaxAge
+}
+
+// queryServers resolves the A and AAAA records of host with the first
+// server that answers, returning the smallest TTL of the records.
+func queryServers(ctx context.Context, servers []string, host string) (ips []net.IP, ttl time.Duration, err error) {
+	name, err := dnsmessage.NewName(dnsKey(host) + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host}
+	}
+	for _, server := range servers {
+		if _, _, e := net.SplitHostPort(server); e != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		ips, ttl, err = nil, 0, nil
+		var notFound bool
+		for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			var msg *dnsmessage.Message
+			if msg, err = exchange(ctx, server, name, qtype); err != nil {
+				break
+			}
+			if msg.RCode == dnsmessage.RCodeNameError {
+				notFound = true
+				break
+			}
+			for _, a := range msg.Answers {
+				var ip net.IP
+				switch r := a.Body.(type) {
+				case *dnsmessage.AResource:
+					ip = net.IP(r.A[:])
+				case *dnsmessage.AAAAResource:
+					ip = net.IP(r.AAAA[:])
+				default:
+					continue
+				}
+				if t := time.Duration(a.Header.TTL) * time.Second; len(ips) == 0 || t < ttl {
+					ttl = t
+				}
+				ips = append(ips, ip)
+			}
+		}
+		if err != nil {
+			continue // try the next server
+		}
+		if notFound || len(ips) == 0 {
+			return nil, 0, &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+		}
+		return ips, ttl, nil
+	}
+	return nil, 0, &net.DNSError{Err: err.Error(), Name: host, IsTemporary: true}
+}
+
+// exchange sends one query over UDP, repeating it over TCP if the answer
+// is truncated.
+func exchange(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	id := uint16(rand.Uint32())
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	b, err := q.Pack()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	for _, network := range []string{"udp", "tcp"} {
+		var d net.Dialer
+		c, err := d.DialContext(ctx, network, server)
+		if err != nil {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			c.SetDeadline(deadline)
+		}
+		msg, err := roundTrip(c, network, b, id)
+		c.Close()
+		if err != nil {
+			return nil, err
+		}
+		if !msg.Truncated {
+			return msg, nil
+		}
+	}
+	return nil, errors.New("truncated DNS answer")
+}
+
+func roundTrip(c net.Conn, network string, query []byte, id uint16) (*dnsmessage.Message, error) {
+	var buf []byte
+	if network == "tcp" {
+		l := make([]byte, 2, 2+len(query))
+		binary.BigEndian.PutUint16(l, uint16(len(query)))
+		if _, err := c.Write(append(l, query...)); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(c, l); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(l))
+		if _, err := io.ReadFull(c, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := c.Write(query); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, 4096)
+		for {
+			n, err := c.Read(buf)
+			if err != nil {
+				return nil, err
+			}
+			if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+				buf = buf[:n]
+				break
+			}
+		}
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if msg.ID != id || !msg.Response {
+		return nil, errors.New("mismatched DNS answer")
+	}
+	return &msg, nil
+}
+
+// hostOf returns the host of a host:port address, or addr itself.
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func dnsKey(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
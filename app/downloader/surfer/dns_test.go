@@ -0,0 +1,144 @@
+package surfer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS answers A queries for every name with ip and ttl over UDP.
+func fakeDNS(t *testing.T, ip net.IP, ttl uint32) (addr string, queries *int64) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	queries = new(int64)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var q dnsmessage.Message
+			if q.Unpack(buf[:n]) != nil || len(q.Questions) != 1 {
+				continue
+			}
+			atomic.AddInt64(queries, 1)
+			a := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: q.ID, Response: true},
+				Questions: q.Questions,
+			}
+			if strings.HasPrefix(q.Questions[0].Name.String(), "missing.") {
+				a.RCode = dnsmessage.RCodeNameError
+			} else if q.Questions[0].Type == dnsmessage.TypeA {
+				var r dnsmessage.AResource
+				copy(r.A[:], ip.To4())
+				a.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: ttl},
+					Body:   &r,
+				}}
+			}
+			b, _ := a.Pack()
+			pc.WriteTo(b, from)
+		}
+	}()
+	return pc.LocalAddr().String(), queries
+}
+
+func TestDnsCache(t *testing.T) {
+	dc := &DnsCache{}
+	dc.SetOptions(DNSOptions{MaxAge: time.Nanosecond})
+	dc.Reg("host:80", "127.0.0.1:80")
+	time.Sleep(time.Millisecond) // a registered address is an override, it does not expire
+	opt := dc.Query("host:80")
+	if !opt.IsSome() || opt.Unwrap() != "127.0.0.1:80" {
+		t.Errorf("Query = %v, want Some(127.0.0.1:80)", opt)
+	}
+	dc.Del("host:80")
+	opt2 := dc.Query("host:80")
+	if opt2.IsSome() {
+		t.Errorf("Query after Del = %v, want None", opt2)
+	}
+}
+
+func TestDnsCache_LookupTTL(t *testing.T) {
+	server, queries := fakeDNS(t, net.IPv4(10, 1, 2, 3), 1)
+	dc := &DnsCache{}
+	dc.SetOptions(DNSOptions{Servers: []string{server}})
+
+	for i := 0; i < 2; i++ {
+		ips, err := dc.Lookup(context.Background(), "Example.test")
+		if err != nil || len(ips) != 1 || !ips[0].Equal(net.IPv4(10, 1, 2, 3)) {
+			t.Fatalf("Lookup = %v, %v; want [10.1.2.3]", ips, err)
+		}
+	}
+	if n := atomic.LoadInt64(queries); n != 2 {
+		t.Errorf("queries = %d, want 2 (A and AAAA once, then cached)", n)
+	}
+	if st := dc.Stats(); st.Hits != 1 || st.Misses != 1 || st.Entries != 1 {
+		t.Errorf("Stats = %+v, want 1 hit, 1 miss, 1 entry", st)
+	}
+
+	time.Sleep(1100 * time.Millisecond) // TTL expired
+	if _, err := dc.Lookup(context.Background(), "example.test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(queries); n != 4 {
+		t.Errorf("queries = %d, want 4 after the TTL expired", n)
+	}
+
+	_, err := dc.Lookup(context.Background(), "missing.test")
+	if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+		t.Errorf("Lookup(missing) err = %v, want not found", err)
+	}
+	if st := dc.Stats(); st.Errors != 1 {
+		t.Errorf("Stats.Errors = %d, want 1", st.Errors)
+	}
+}
+
+func TestDnsCache_MaxAge(t *testing.T) {
+	server, queries := fakeDNS(t, net.IPv4(10, 1, 2, 3), 3600)
+	dc := &DnsCache{}
+	dc.SetOptions(DNSOptions{Servers: []string{server}, MaxAge: 50 * time.Millisecond})
+	dc.Lookup(context.Background(), "example.test")
+	time.Sleep(100 * time.Millisecond)
+	dc.Lookup(context.Background(), "example.test")
+	if n := atomic.LoadInt64(queries); n != 4 {
+		t.Errorf("queries = %d, want 4: MaxAge caps the TTL", n)
+	}
+}
+
+func TestSurfDownload_DNSHosts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	SetDNS(DNSOptions{Hosts: map[string][]string{"staging.example.test": {"127.0.0.1"}}})
+	defer SetDNS(DNSOptions{})
+
+	resp := New().Download(&DefaultRequest{
+		URL:         "http://staging.example.test:" + port + "/",
+		DialTimeout: time.Second,
+		ConnTimeout: time.Second,
+		TryTimes:    1,
+	}).Unwrap()
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if st := GetDNSStats(); st.Hits == 0 {
+		t.Errorf("Stats = %+v, want the override counted as a hit", st)
+	}
+}
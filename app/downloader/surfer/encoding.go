@@ -0,0 +1,126 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andeya/gust/result"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding lists the content codings Surf decodes; it is sent with
+// every request that does not set its own Accept-Encoding.
+//
+// Only Surf sends it. Chrome advertises the codings it decodes itself, and
+// PhantomJS cannot send it: its Qt network stack already sends
+// "gzip, deflate" and decodes the response only when it added the header
+// itself, so setting Accept-Encoding on the page would leave the body
+// encoded, and br or zstd could not be decoded at all.
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+// decodedBody is a decoded response body; closing it releases the decoders
+// and the underlying body.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer // innermost decoder first, raw body last
+}
+
+// Close implements io.Closer.
+func (b *decodedBody) Close() error {
+	var err error
+	for _, c := range b.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// decodeResponse replaces resp.Body with its decoded content. Codings listed
+// in Content-Encoding are undone in reverse order of application; decoding
+// stops at the first unknown coding, which is left in the header.
+func decodeResponse(resp *http.Response) (r result.VoidResult) {
+	defer r.Catch()
+	var codings []string
+	for _, v := range resp.Header.Values("Content-Encoding") {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" && c != "identity" {
+				codings = append(codings, c)
+			}
+		}
+	}
+	if len(codings) == 0 || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified ||
+		(resp.Request != nil && resp.Request.Method == "HEAD") {
+		return result.OkVoid()
+	}
+
+	body := &decodedBody{Reader: resp.Body, closers: []io.Closer{resp.Body}}
+	n := len(codings)
+	for ; n > 0; n-- {
+		var dec io.ReadCloser
+		switch codings[n-1] {
+		case "gzip", "x-gzip":
+			dec = result.Ret(gzip.NewReader(body.Reader)).Unwrap()
+		case "deflate":
+			// Servers disagree on whether "deflate" is zlib-wrapped (RFC 9110) or raw.
+			dec = newDeflateReader(body.Reader)
+		case "zlib":
+			dec = result.Ret(zlib.NewReader(body.Reader)).Unwrap()
+		case "br":
+			dec = io.NopCloser(brotli.NewReader(body.Reader))
+		case "zstd":
+			dec = result.Ret(zstd.NewReader(body.Reader)).Unwrap().IOReadCloser()
+		}
+		if dec == nil {
+			break
+		}
+		body.Reader = dec
+		body.closers = append([]io.Closer{dec}, body.closers...)
+	}
+	if n == len(codings) {
+		return result.OkVoid()
+	}
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	if n > 0 {
+		resp.Header.Set("Content-Encoding", strings.Join(codings[:n], ", "))
+	}
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return result.OkVoid()
+}
+
+// newDeflateReader decodes "deflate" content, accepting both the zlib format
+// required by the spec and the raw DEFLATE stream some servers send.
+func newDeflateReader(r io.Reader) io.ReadCloser {
+	br := bufio.NewReader(r)
+	// A zlib header has compression method 8, no preset dictionary and a valid check sum.
+	if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && h[1]&0x20 == 0 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(br)
+}
@@ -0,0 +1,111 @@
+package surfer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encode applies the content codings in order.
+func encode(t *testing.T, data []byte, codings ...string) []byte {
+	t.Helper()
+	for _, c := range codings {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch c {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		case "zstd":
+			zw, err := zstd.NewWriter(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w = zw
+		default:
+			t.Fatalf("unknown coding %q", c)
+		}
+		w.Write(data)
+		w.Close()
+		data = buf.Bytes()
+	}
+	return data
+}
+
+func TestSurfDownloadContentEncodings(t *testing.T) {
+	const content = "encoded body"
+	tests := []struct {
+		name     string
+		header   []string // Content-Encoding header lines
+		codings  []string // codings actually applied, in order
+		want     string
+		wantLeft string // Content-Encoding left after decoding
+	}{
+		{"br", []string{"br"}, []string{"br"}, content, ""},
+		{"zstd", []string{"zstd"}, []string{"zstd"}, content, ""},
+		{"zlib deflate", []string{"deflate"}, []string{"deflate"}, content, ""},
+		{"stacked", []string{"gzip, br"}, []string{"gzip", "br"}, content, ""},
+		{"stacked lines", []string{"zstd", "gzip"}, []string{"zstd", "gzip"}, content, ""},
+		{"identity", []string{"identity"}, nil, content, "identity"},
+		{"unknown inner", []string{"x-custom, gzip"}, []string{"gzip"}, content, "x-custom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := encode(t, []byte(tt.want), tt.codings...)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept-Encoding") != AcceptEncoding {
+					t.Errorf("Accept-Encoding = %q, want %q", r.Header.Get("Accept-Encoding"), AcceptEncoding)
+				}
+				for _, h := range tt.header {
+					w.Header().Add("Content-Encoding", h)
+				}
+				w.Write(payload)
+			}))
+			defer srv.Close()
+
+			r := New().Download(&DefaultRequest{URL: srv.URL, TryTimes: 1})
+			if r.IsErr() {
+				t.Fatalf("Download() err: %v", r.UnwrapErr())
+			}
+			resp := r.Unwrap()
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("ReadAll err: %v", err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("body = %q, want %q", body, tt.want)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantLeft {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestSurfDownloadKeepsRequestAcceptEncoding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Accept-Encoding")))
+	}))
+	defer srv.Close()
+
+	r := New().Download(&DefaultRequest{URL: srv.URL, Header: http.Header{"Accept-Encoding": {"identity"}}})
+	if r.IsErr() {
+		t.Fatalf("Download() err: %v", r.UnwrapErr())
+	}
+	resp := r.Unwrap()
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "identity" {
+		t.Errorf("Accept-Encoding = %q, want %q", body, "identity")
+	}
+}
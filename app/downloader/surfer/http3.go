@@ -0,0 +1,179 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/andeya/gust/syncutil"
+)
+
+// HTTP3Request is optionally implemented by a Request to be sent over
+// HTTP/3 (QUIC) by Surf, falling back to HTTP/2 or HTTP/1.1 if the QUIC
+// connection cannot be set up, or for idempotent requests if the exchange
+// fails. Requests through a proxy never use HTTP/3.
+type HTTP3Request interface {
+	GetHTTP3() bool
+}
+
+// h3Cooldown is how long Surf sends to a host over TCP after its HTTP/3
+// connection failed.
+const h3Cooldown = 10 * time.Minute
+
+var (
+	// h3Transports shares QUIC connections between requests with the same TLS settings.
+	h3Transports syncutil.SyncMap[*tls.Config, *http3.Transport]
+	// h3Failed holds when HTTP/3 last failed, by host:port.
+	h3Failed syncutil.SyncMap[string, time.Time]
+)
+
+// h3Transport returns the shared HTTP/3 transport of tlsConfig.
+func h3Transport(tlsConfig *tls.Config) *http3.Transport {
+	if t := h3Transports.Load(tlsConfig); t.IsSome() {
+		return t.Unwrap()
+	}
+	t := &http3.Transport{
+		TLSClientConfig: tlsConfig,
+		// Content codings are negotiated and decoded by Surf itself (see AcceptEncoding).
+		DisableCompression: true,
+		Dial:               dialQUIC,
+	}
+	if old := h3Transports.LoadOrStore(tlsConfig, t); old.IsSome() {
+		return old.Unwrap()
+	}
+	return t
+}
+
+// dialQUIC dials a QUIC connection and completes its handshake, resolving
+// the host with Surf's DNS cache. Its errors are h3DialErrors.
+func dialQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, &h3DialError{err}
+	}
+	ips, err := dnsCache.Lookup(ctx, host)
+	if err != nil {
+		return nil, &h3DialError{err}
+	}
+	var conn *quic.Conn
+	for _, ip := range ips {
+		if conn, err = quic.DialAddr(ctx, net.JoinHostPort(ip.String(), port), tlsCfg, cfg); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, &h3DialError{err}
+}
+
+// h3DialError is a failure to set up a QUIC connection, before any request
+// was sent on it.
+type h3DialError struct {
+	err error
+}
+
+func (e *h3DialError) Error() string { return e.err.Error() }
+
+func (e *h3DialError) Unwrap() error { return e.err }
+
+// h3RoundTripper sends HTTPS requests over HTTP/3 and falls back to another
+// transport, usually HTTP/2 with HTTP/1.1, if the QUIC connection cannot be
+// set up. Requests that may have reached the server are only sent again if
+// they are idempotent, so that e.g. a POST is not submitted twice.
+type h3RoundTripper struct {
+	h3       http.RoundTripper
+	fallback http.RoundTripper
+	timeout  time.Duration // limit of a whole HTTP/3 exchange, 0 for none
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *h3RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := canonicalAddr(req)
+	if req.URL.Scheme != "https" || time.Since(h3Failed.Load(host).UnwrapOr(time.Time{})) < h3Cooldown {
+		return rt.fallback.RoundTrip(req)
+	}
+	r, cancel := req, context.CancelFunc(nil)
+	if rt.timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), rt.timeout)
+		r = req.WithContext(ctx)
+	}
+	resp, err := rt.h3.RoundTrip(r)
+	if err == nil {
+		if cancel != nil {
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		}
+		return resp, nil
+	}
+	if cancel != nil {
+		cancel()
+	}
+	h3Failed.Store(host, time.Now())
+	var dialErr *h3DialError
+	if !errors.As(err, &dialErr) && !idempotent(req) {
+		return nil, err
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, err // the body was consumed and cannot be sent again
+		}
+		body, e := req.GetBody()
+		if e != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return rt.fallback.RoundTrip(req)
+}
+
+// idempotent reports whether req may be sent again after it possibly
+// reached the server, as net/http does when retrying.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return req.Header["Idempotency-Key"] != nil || req.Header["X-Idempotency-Key"] != nil
+}
+
+// cancelBody releases the context of a response when its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// canonicalAddr returns the host:port of req's URL.
+func canonicalAddr(req *http.Request) string {
+	if port := req.URL.Port(); port != "" {
+		return req.URL.Host
+	}
+	if req.URL.Scheme == "https" {
+		return net.JoinHostPort(req.URL.Hostname(), "443")
+	}
+	return net.JoinHostPort(req.URL.Hostname(), "80")
+}
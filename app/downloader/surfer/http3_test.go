@@ -0,0 +1,110 @@
+package surfer
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// protoServer starts an HTTPS server answering with the protocol of the
+// request, over HTTP/2 and HTTP/1.1 and, if h3 is set, over HTTP/3 on the
+// same port.
+func protoServer(t *testing.T, h3 bool) *httptest.Server {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})
+	srv := httptest.NewUnstartedServer(handler)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	if h3 {
+		conn, err := net.ListenPacket("udp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Skipf("udp port of the test server taken: %v", err)
+		}
+		h3srv := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(srv.TLS)}
+		go h3srv.Serve(conn)
+		t.Cleanup(func() { h3srv.Close(); conn.Close() })
+	}
+	return srv
+}
+
+func protoGet(t *testing.T, url string, h3 bool) string {
+	t.Helper()
+	resp := New().Download(&DefaultRequest{
+		URL:      url,
+		TLS:      &TLSOptions{InsecureSkipVerify: true},
+		HTTP3:    h3,
+		TryTimes: 1,
+	}).Unwrap()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSurfHTTP3(t *testing.T) {
+	srv := protoServer(t, true)
+	if got := protoGet(t, srv.URL, true); got != "HTTP/3.0" {
+		t.Errorf("HTTP3 request sent over %s, want HTTP/3.0", got)
+	}
+	if got := protoGet(t, srv.URL, false); got != "HTTP/1.1" {
+		t.Errorf("plain request sent over %s, want HTTP/1.1", got)
+	}
+}
+
+func TestSurfHTTP3Fallback(t *testing.T) {
+	srv := protoServer(t, false)
+	if got := protoGet(t, srv.URL, true); got != "HTTP/2.0" {
+		t.Errorf("HTTP3 request without QUIC server sent over %s, want HTTP/2.0", got)
+	}
+	if h3Failed.Load(srv.Listener.Addr().String()).IsNone() {
+		t.Error("failed HTTP/3 host not remembered")
+	}
+	if got := protoGet(t, srv.URL, true); got != "HTTP/2.0" {
+		t.Errorf("second request sent over %s, want HTTP/2.0", got)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestH3RoundTripperFallback(t *testing.T) {
+	for _, tt := range []struct {
+		name, method string
+		h3Err        error
+		fallback     bool
+	}{
+		{"dial GET", http.MethodGet, &h3DialError{errors.New("no QUIC")}, true},
+		{"dial POST", http.MethodPost, &h3DialError{errors.New("no QUIC")}, true},
+		{"stream GET", http.MethodGet, errors.New("stream reset"), true},
+		{"stream POST", http.MethodPost, errors.New("stream reset"), false},
+	} {
+		var sent int
+		rt := &h3RoundTripper{
+			h3: roundTripFunc(func(*http.Request) (*http.Response, error) { return nil, tt.h3Err }),
+			fallback: roundTripFunc(func(*http.Request) (*http.Response, error) {
+				sent++
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			}),
+		}
+		req, _ := http.NewRequest(tt.method, "https://h3-"+strings.ReplaceAll(tt.name, " ", "-")+".test/", strings.NewReader("a=1"))
+		_, err := rt.RoundTrip(req)
+		if tt.fallback && (err != nil || sent != 1) {
+			t.Errorf("%s: err = %v, fallback sends = %d, want nil, 1", tt.name, err, sent)
+		}
+		if !tt.fallback && (err != tt.h3Err || sent != 0) {
+			t.Errorf("%s: err = %v, fallback sends = %d, want %v, 0", tt.name, err, sent, tt.h3Err)
+		}
+	}
+}
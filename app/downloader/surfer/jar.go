@@ -0,0 +1,312 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andeya/gust/result"
+)
+
+type (
+	// Jar is a cookie jar whose cookies can be listed, imported and exported,
+	// e.g. to persist a login between runs or reuse one from a browser.
+	Jar struct {
+		jar     *cookiejar.Jar
+		cookies map[string]StoredCookie // by domain;path;name
+		mu      sync.Mutex
+	}
+
+	// StoredCookie is a cookie held by a Jar. Its JSON form matches the cookie
+	// exports of common browser extensions.
+	StoredCookie struct {
+		Name     string    `json:"name"`
+		Value    string    `json:"value"`
+		Domain   string    `json:"domain"`   // without leading dot
+		HostOnly bool      `json:"hostOnly"` // sent to Domain only, not to its subdomains
+		Path     string    `json:"path"`
+		Secure   bool      `json:"secure"`
+		HttpOnly bool      `json:"httpOnly"`
+		Expires  time.Time `json:"-"` // zero for session cookies
+	}
+)
+
+// CookieJarRequest is implemented by requests that carry their own cookie
+// jar; Surf, PhantomJS and Chrome then use it instead of the downloader's jar.
+type CookieJarRequest interface {
+	GetCookieJar() http.CookieJar
+}
+
+// SessionRequest is implemented by requests that name the spider instance
+// sending them, whose cookie jar they carry; with ChromeOptions.Isolate,
+// Chrome opens them in a browser context of that instance.
+type SessionRequest interface {
+	GetSession() string
+}
+
+var _ http.CookieJar = (*Jar)(nil)
+
+// NewJar creates an empty Jar.
+func NewJar() *Jar {
+	jar, _ := cookiejar.New(nil) // nil options never returns error
+	return &Jar{jar: jar, cookies: make(map[string]StoredCookie)}
+}
+
+// Cookies implements http.CookieJar.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// SetCookies implements http.CookieJar.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		sc := StoredCookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly}
+		if sc.Domain = strings.TrimPrefix(strings.ToLower(c.Domain), "."); sc.Domain == "" || sc.Domain == host {
+			sc.Domain, sc.HostOnly = host, c.Domain == ""
+		} else if !strings.HasSuffix(host, "."+sc.Domain) {
+			continue // rejected by the underlying jar
+		}
+		if !strings.HasPrefix(sc.Path, "/") {
+			sc.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			sc.Expires = now.Add(-time.Second)
+		case c.MaxAge > 0:
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		default:
+			sc.Expires = c.Expires
+		}
+		if sc.expired(now) {
+			delete(j.cookies, sc.key())
+		} else {
+			j.cookies[sc.key()] = sc
+		}
+	}
+}
+
+// Export returns all unexpired cookies, sorted by domain, path and name.
+func (j *Jar) Export() []StoredCookie {
+	now := time.Now()
+	j.mu.Lock()
+	cookies := make([]StoredCookie, 0, len(j.cookies))
+	for k, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, k)
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+	j.mu.Unlock()
+	sort.Slice(cookies, func(a, b int) bool { return cookies[a].key() < cookies[b].key() })
+	return cookies
+}
+
+// Import adds cookies as if their domains had set them.
+func (j *Jar) Import(cookies ...StoredCookie) {
+	for _, c := range cookies {
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if domain == "" || c.Name == "" {
+			continue
+		}
+		u := &url.URL{Scheme: "http", Host: domain, Path: c.Path}
+		if c.Secure {
+			u.Scheme = "https"
+		}
+		hc := &http.Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly, Expires: c.Expires}
+		if !c.HostOnly {
+			hc.Domain = domain
+		}
+		j.SetCookies(u, []*http.Cookie{hc})
+	}
+}
+
+// Load imports cookies in JSON (as written by Save or browser extensions)
+// or Netscape cookies.txt format.
+func (j *Jar) Load(r io.Reader) (res result.VoidResult) {
+	defer res.Catch()
+	b := result.Ret(io.ReadAll(r)).Unwrap()
+	if b = bytes.TrimSpace(b); len(b) == 0 {
+		return result.OkVoid()
+	}
+	if b[0] == '[' {
+		var cookies []StoredCookie
+		result.RetVoid(json.Unmarshal(b, &cookies)).Unwrap()
+		j.Import(cookies...)
+		return result.OkVoid()
+	}
+	j.Import(parseNetscape(b).Unwrap()...)
+	return result.OkVoid()
+}
+
+// Save writes all cookies as JSON.
+func (j *Jar) Save(w io.Writer) result.VoidResult {
+	return result.RetVoid(json.NewEncoder(w).Encode(j.Export()))
+}
+
+// SaveNetscape writes all cookies in Netscape cookies.txt format, as read by
+// curl, wget and most browser extensions.
+func (j *Jar) SaveNetscape(w io.Writer) result.VoidResult {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Netscape HTTP Cookie File\n")
+	for _, c := range j.Export() {
+		domain, prefix, expires := c.Domain, "", int64(0)
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HttpOnly {
+			prefix = "#HttpOnly_"
+		}
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s%s\t%s\t%s\t%s\t%d\t%s\t%s\n", prefix, domain, netscapeBool(!c.HostOnly),
+			c.Path, netscapeBool(c.Secure), expires, c.Name, c.Value)
+	}
+	return result.RetVoid(bw.Flush())
+}
+
+// ImportFile loads cookies from a JSON or cookies.txt file.
+func (j *Jar) ImportFile(name string) (r result.VoidResult) {
+	defer r.Catch()
+	f := result.Ret(os.Open(name)).Unwrap()
+	defer f.Close()
+	return j.Load(f)
+}
+
+// ExportFile writes all cookies to a file: in cookies.txt format if its
+// extension is .txt, otherwise as JSON.
+func (j *Jar) ExportFile(name string) (r result.VoidResult) {
+	defer r.Catch()
+	f := result.Ret(os.Create(name)).Unwrap()
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(name), ".txt") {
+		return j.SaveNetscape(f)
+	}
+	return j.Save(f)
+}
+
+// MarshalJSON implements json.Marshaler, adding the expirationDate (Unix
+// seconds) and session fields of browser exports.
+func (c StoredCookie) MarshalJSON() ([]byte, error) {
+	type plain StoredCookie
+	v := struct {
+		plain
+		ExpirationDate float64 `json:"expirationDate,omitempty"`
+		Session        bool    `json:"session"`
+	}{plain: plain(c), Session: c.Expires.IsZero()}
+	if !v.Session {
+		v.ExpirationDate = float64(c.Expires.UnixMilli()) / 1e3
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *StoredCookie) UnmarshalJSON(b []byte) error {
+	type plain StoredCookie
+	v := struct {
+		*plain
+		ExpirationDate float64 `json:"expirationDate"`
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.ExpirationDate > 0 {
+		sec, frac := math.Modf(v.ExpirationDate)
+		c.Expires = time.Unix(int64(sec), int64(frac*1e9))
+	}
+	return nil
+}
+
+func (c StoredCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c StoredCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// defaultCookiePath returns the default cookie path of a request path (RFC 6265 5.1.4).
+func defaultCookiePath(p string) string {
+	i := strings.LastIndex(p, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// parseNetscape parses a Netscape cookies.txt file.
+func parseNetscape(b []byte) result.Result[[]StoredCookie] {
+	var cookies []StoredCookie
+	for n, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimRight(line, "\r")
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		if httpOnly {
+			line = line[len("#HttpOnly_"):]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		if len(f) != 7 {
+			return result.FmtErr[[]StoredCookie]("cookies.txt line %d: want 7 tab-separated fields, got %d", n+1, len(f))
+		}
+		expires, err := strconv.ParseInt(f[4], 10, 64)
+		if err != nil {
+			return result.FmtErr[[]StoredCookie]("cookies.txt line %d: invalid expiry %q", n+1, f[4])
+		}
+		c := StoredCookie{
+			Name:     f[5],
+			Value:    f[6],
+			Domain:   strings.TrimPrefix(f[0], "."),
+			HostOnly: !strings.EqualFold(f[1], "TRUE"),
+			Path:     f[2],
+			Secure:   strings.EqualFold(f[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return result.Ok(cookies)
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
@@ -0,0 +1,109 @@
+package surfer
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJarExportImport(t *testing.T) {
+	u, _ := url.Parse("http://www.example.com/account/login")
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	jar := NewJar()
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "sid", Value: "1", HttpOnly: true},
+		{Name: "lang", Value: "en", Domain: ".example.com", Path: "/", Expires: expires},
+		{Name: "gone", Value: "x", MaxAge: -1},
+		{Name: "foreign", Value: "x", Domain: "other.com"},
+	})
+	want := []StoredCookie{
+		{Name: "lang", Value: "en", Domain: "example.com", Path: "/", Expires: expires},
+		{Name: "sid", Value: "1", Domain: "www.example.com", HostOnly: true, Path: "/account", HttpOnly: true},
+	}
+	if got := jar.Export(); !equalCookies(got, want) {
+		t.Fatalf("Export() = %+v, want %+v", got, want)
+	}
+
+	for _, format := range []string{"json", "netscape"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if format == "json" {
+				jar.Save(&buf).Unwrap()
+			} else {
+				jar.SaveNetscape(&buf).Unwrap()
+			}
+			jar2 := NewJar()
+			if r := jar2.Load(&buf); r.IsErr() {
+				t.Fatalf("Load() err: %v", r.UnwrapErr())
+			}
+			if got := jar2.Export(); !equalCookies(got, want) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+			sub, _ := url.Parse("http://img.example.com/account/x")
+			if got := jar2.Cookies(sub); len(got) != 1 || got[0].Name != "lang" {
+				t.Errorf("Cookies(subdomain) = %v, want only lang", got)
+			}
+		})
+	}
+}
+
+func TestJarLoadBrowserExport(t *testing.T) {
+	const export = `[{"domain":".example.com","expirationDate":4102444800.5,"hostOnly":false,"httpOnly":true,
+		"name":"token","path":"/","secure":true,"session":false,"storeId":"0","value":"abc"}]`
+	jar := NewJar()
+	if r := jar.Load(strings.NewReader(export)); r.IsErr() {
+		t.Fatalf("Load() err: %v", r.UnwrapErr())
+	}
+	u, _ := url.Parse("https://www.example.com/")
+	if got := jar.Cookies(u); len(got) != 1 || got[0].Value != "abc" {
+		t.Errorf("Cookies() = %v, want token=abc", got)
+	}
+	if r := jar.Load(strings.NewReader("example.com\tTRUE\t/\n")); r.IsOk() {
+		t.Error("Load(malformed cookies.txt) = ok, want error")
+	}
+}
+
+func TestSurfDownloadRequestJar(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "42"})
+	}))
+	defer srv.Close()
+
+	jar := NewJar()
+	s := New()
+	s.Download(&jarRequest{DefaultRequest{URL: srv.URL, EnableCookie: true}, jar}).Unwrap().Body.Close()
+	if got := jar.Export(); len(got) != 1 || got[0].Value != "42" {
+		t.Errorf("request jar = %+v, want sid=42", got)
+	}
+	if u, _ := url.Parse(srv.URL); len(s.(*Surf).CookieJar.Cookies(u)) != 0 {
+		t.Error("cookie leaked into the surfer's own jar")
+	}
+}
+
+type jarRequest struct {
+	DefaultRequest
+	jar http.CookieJar
+}
+
+func (r *jarRequest) GetCookieJar() http.CookieJar { return r.jar }
+
+func equalCookies(a, b []StoredCookie) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if !x.Expires.Equal(y.Expires) {
+			return false
+		}
+		x.Expires, y.Expires = time.Time{}, time.Time{}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
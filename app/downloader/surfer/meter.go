@@ -0,0 +1,39 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import "io"
+
+type (
+	// BodyMeter wraps a response body as it is received, e.g. to count and
+	// throttle the bytes, returning the body to read from.
+	BodyMeter func(body io.ReadCloser) io.ReadCloser
+
+	// MeterRequest is optionally implemented by a Request whose response
+	// body is metered. Surf meters the body before content decoding; the
+	// browsers of PhantomJS and Chrome decode it themselves, so their
+	// rendered body is metered.
+	MeterRequest interface {
+		GetBodyMeter() BodyMeter
+	}
+)
+
+// meter returns body wrapped by the meter of p, if any.
+func (p *Param) meter(body io.ReadCloser) io.ReadCloser {
+	if p.bodyMeter == nil || body == nil {
+		return body
+	}
+	return p.bodyMeter(body)
+}
@@ -16,6 +16,7 @@ package surfer
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"math/rand"
@@ -36,11 +37,17 @@ type Param struct {
 	body          io.Reader
 	header        http.Header
 	enableCookie  bool
+	jar           http.CookieJar // per-request jar, nil for the surfer's own
 	dialTimeout   time.Duration
 	connTimeout   time.Duration
 	tryTimes      int
 	retryPause    time.Duration
 	redirectTimes int
+	tlsConfig     *tls.Config
+	profile       *agent.Profile // browser identity, nil for random User-Agents
+	http3         bool           // try HTTP/3 first
+	signer        Signer         // signs each attempt, nil for none
+	bodyMeter     BodyMeter      // meters the response body, nil for none
 	client        *http.Client
 }
 
@@ -85,6 +92,11 @@ func NewParam(req Request) (r result.Result[*Param]) {
 
 	param.enableCookie = req.GetEnableCookie()
 
+	if pr, ok := req.(ProfileRequest); ok {
+		if param.profile = agent.LookupProfile(pr.GetProfile()); param.profile != nil {
+			param.profile.Apply(param.header)
+		}
+	}
 	if len(param.header.Get("User-Agent")) == 0 {
 		if param.enableCookie {
 			param.header.Add("User-Agent", agent.UserAgents["common"][0])
@@ -104,6 +116,27 @@ func NewParam(req Request) (r result.Result[*Param]) {
 	param.tryTimes = req.GetTryTimes()
 	param.retryPause = req.GetRetryPause()
 	param.redirectTimes = req.GetRedirectTimes()
+	if jr, ok := req.(CookieJarRequest); ok {
+		param.jar = jr.GetCookieJar()
+	}
+	if tr, ok := req.(TLSRequest); ok {
+		param.tlsConfig = tr.GetTLS().Config().Unwrap()
+	}
+	if hr, ok := req.(HTTP3Request); ok {
+		param.http3 = hr.GetHTTP3()
+	}
+	if sr, ok := req.(SignerRequest); ok {
+		param.signer = sr.GetSigner()
+	}
+	if mr, ok := req.(MeterRequest); ok {
+		param.bodyMeter = mr.GetBodyMeter()
+	}
+	if ar, ok := req.(AuthorizationRequest); ok {
+		if auth := ar.GetAuthorization(); auth != "" {
+			param.header = param.header.Clone()
+			param.header.Set("Authorization", auth)
+		}
+	}
 	return result.Ok(param)
 }
 
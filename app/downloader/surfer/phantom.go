@@ -111,9 +111,13 @@ func (p *Phantom) Download(req Request) (r result.Result[*http.Response]) {
 
 	param := NewParam(req).Unwrap()
 
+	var jar http.CookieJar = p.CookieJar
+	if param.jar != nil {
+		jar = param.jar
+	}
 	cookie := ""
 	if req.GetEnableCookie() {
-		httpCookies := p.CookieJar.Cookies(param.url)
+		httpCookies := jar.Cookies(param.url)
 		if len(httpCookies) > 0 {
 			surferCookies := make([]*Cookie, len(httpCookies))
 
@@ -180,16 +184,20 @@ func (p *Phantom) Download(req Request) (r result.Result[*http.Response]) {
 		for _, h := range retResp.Header {
 			resp.Header.Add(h.Name, h.Value)
 		}
+		// PhantomJS negotiates and decodes content codings itself, which is
+		// why it is not given Accept-Encoding (see AcceptEncoding).
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
 
 		for _, c := range retResp.Cookies {
 			resp.Header.Add("Set-Cookie", c)
 		}
 		if req.GetEnableCookie() {
 			if rc := resp.Cookies(); len(rc) > 0 {
-				p.CookieJar.SetCookies(param.url, rc)
+				jar.SetCookies(param.url, rc)
 			}
 		}
-		resp.Body = io.NopCloser(strings.NewReader(retResp.Body))
+		resp.Body = param.meter(io.NopCloser(strings.NewReader(retResp.Body)))
 		err = nil
 		break
 	}
@@ -49,6 +49,12 @@ type (
 		GetDownloaderID() int
 	}
 
+	// ProfileRequest is implemented by requests that carry a browser identity
+	// profile, whose headers Surf sends and whose identity Chrome emulates.
+	ProfileRequest interface {
+		GetProfile() string
+	}
+
 	// DefaultRequest is the default Request implementation.
 	DefaultRequest struct {
 		URL          string      // required
@@ -75,6 +81,23 @@ type (
 		// DownloaderID: 0=Surf (high concurrency), 1=PhantomJS (strong anti-block, slow)
 		DownloaderID int
 
+		// scripted steps for the Chrome downloader
+		Actions []ChromeAction
+		// regexp of XHR/fetch URLs captured by the Chrome downloader
+		CaptureXHR string
+		// capture a full-page PNG screenshot with the Chrome downloader
+		Screenshot bool
+		// print the page to PDF with the Chrome downloader
+		PDF bool
+		// HTTPS settings; nil verifies against the system roots
+		TLS *TLSOptions
+		// browser identity profile name, see agent.LookupProfile
+		Profile string
+		// try HTTP/3 first with the Surf downloader, see HTTP3Request
+		HTTP3 bool
+		// signs each send attempt of the Surf downloader
+		Signer Signer
+
 		once sync.Once // ensures prepare is called only once
 	}
 )
@@ -198,3 +221,51 @@ func (dr *DefaultRequest) GetDownloaderID() int {
 	dr.once.Do(dr.prepare)
 	return dr.DownloaderID
 }
+
+// GetActions returns the scripted Chrome steps.
+func (dr *DefaultRequest) GetActions() []ChromeAction {
+	dr.once.Do(dr.prepare)
+	return dr.Actions
+}
+
+// GetCaptureXHR returns the XHR capture URL pattern.
+func (dr *DefaultRequest) GetCaptureXHR() string {
+	dr.once.Do(dr.prepare)
+	return dr.CaptureXHR
+}
+
+// GetScreenshot reports whether a full-page screenshot is captured.
+func (dr *DefaultRequest) GetScreenshot() bool {
+	dr.once.Do(dr.prepare)
+	return dr.Screenshot
+}
+
+// GetPDF reports whether the page is printed to PDF.
+func (dr *DefaultRequest) GetPDF() bool {
+	dr.once.Do(dr.prepare)
+	return dr.PDF
+}
+
+// GetTLS returns the HTTPS settings.
+func (dr *DefaultRequest) GetTLS() *TLSOptions {
+	dr.once.Do(dr.prepare)
+	return dr.TLS
+}
+
+// GetProfile returns the browser identity profile name.
+func (dr *DefaultRequest) GetProfile() string {
+	dr.once.Do(dr.prepare)
+	return dr.Profile
+}
+
+// GetHTTP3 reports whether Surf tries HTTP/3 first.
+func (dr *DefaultRequest) GetHTTP3() bool {
+	dr.once.Do(dr.prepare)
+	return dr.HTTP3
+}
+
+// GetSigner returns the signer called before each send attempt.
+func (dr *DefaultRequest) GetSigner() Signer {
+	dr.once.Do(dr.prepare)
+	return dr.Signer
+}
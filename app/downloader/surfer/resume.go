@@ -0,0 +1,167 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Resumer is implemented by response bodies that can restart the transfer
+// at a byte offset using an HTTP Range request.
+type Resumer interface {
+	// ResumeFrom discards the current transfer and continues from offset.
+	// It must be called before the first Read.
+	ResumeFrom(offset int64) error
+	// Validator returns the ETag or Last-Modified of the content, telling
+	// whether a partial copy is of the same version; "" if unknown.
+	Validator() string
+}
+
+// RangeBody wraps an identity-encoded response body from a server that
+// advertises "Accept-Ranges: bytes". When the transfer breaks off, it
+// re-requests the remainder with a Range header instead of failing, so large
+// downloads survive dropped connections and connection deadlines.
+type RangeBody struct {
+	client    *http.Client
+	req       *http.Request
+	body      io.ReadCloser
+	validator string // ETag or Last-Modified, sent as If-Range
+	total     int64  // full content length, -1 if unknown
+	offset    int64  // absolute offset of the next byte to read
+	tries     int
+	pause     time.Duration
+	err       error
+}
+
+var _ Resumer = (*RangeBody)(nil)
+
+// newRangeBody returns resp.Body wrapped in a RangeBody when the response
+// can be resumed, otherwise resp.Body unchanged.
+func newRangeBody(client *http.Client, resp *http.Response, tryTimes int, pause time.Duration) io.ReadCloser {
+	if resp.StatusCode != http.StatusOK ||
+		resp.Request == nil ||
+		resp.Request.Method != "GET" ||
+		resp.Uncompressed ||
+		resp.Header.Get("Content-Encoding") != "" ||
+		!strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") {
+		return resp.Body
+	}
+	if tryTimes <= 0 {
+		tryTimes = DefaultTryTimes
+	}
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	return &RangeBody{
+		client:    client,
+		req:       resp.Request.Clone(context.Background()),
+		body:      resp.Body,
+		validator: validator,
+		total:     resp.ContentLength,
+		tries:     tryTimes,
+		pause:     pause,
+	}
+}
+
+// Read implements io.Reader, resuming the transfer on unexpected errors.
+func (b *RangeBody) Read(p []byte) (int, error) {
+	for {
+		if b.err != nil {
+			return 0, b.err
+		}
+		n, err := b.body.Read(p)
+		b.offset += int64(n)
+		if err == io.EOF && (b.total < 0 || b.offset >= b.total) {
+			return n, io.EOF
+		}
+		if err == nil || n > 0 {
+			return n, nil
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if b.tries <= 0 {
+			b.err = err
+			return 0, err
+		}
+		b.tries--
+		time.Sleep(b.pause)
+		if rerr := b.reopen(b.offset); rerr != nil {
+			b.err = fmt.Errorf("%v (resume failed: %v)", err, rerr)
+		}
+	}
+}
+
+// Close implements io.Closer.
+func (b *RangeBody) Close() error {
+	return b.body.Close()
+}
+
+// ResumeFrom implements Resumer.
+func (b *RangeBody) ResumeFrom(offset int64) error {
+	if offset == b.offset {
+		return nil
+	}
+	if b.total >= 0 && offset > b.total {
+		return fmt.Errorf("resume offset %d beyond content length %d", offset, b.total)
+	}
+	if offset == b.total {
+		// Nothing is left to transfer; a range request would get 416.
+		b.body.Close()
+		b.body = http.NoBody
+		b.offset = offset
+		return nil
+	}
+	if err := b.reopen(offset); err != nil {
+		if rerr := b.reopen(b.offset); rerr != nil {
+			b.err = rerr
+		}
+		return err
+	}
+	return nil
+}
+
+// Validator implements Resumer.
+func (b *RangeBody) Validator() string {
+	return b.validator
+}
+
+// reopen closes the current transfer and requests the bytes from offset on.
+func (b *RangeBody) reopen(offset int64) error {
+	b.body.Close()
+	b.body = http.NoBody
+	req := b.req.Clone(context.Background())
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	if b.validator != "" {
+		req.Header.Set("If-Range", b.validator)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return fmt.Errorf("range request answered with %s", resp.Status)
+	}
+	b.body = resp.Body
+	b.offset = offset
+	return nil
+}
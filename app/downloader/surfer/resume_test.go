@@ -0,0 +1,136 @@
+package surfer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyRangeServer serves content with Range support; the first full (non-range)
+// response is cut off after half of the body.
+func flakyRangeServer(t *testing.T, content string) (*httptest.Server, *int32) {
+	var ranges int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+			if r.Header.Get("If-Range") != `"v1"` {
+				t.Errorf("If-Range = %q, want %q", r.Header.Get("If-Range"), `"v1"`)
+			}
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+			return
+		}
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", "10")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(content[:len(content)/2]))
+		// Hijack and close so the client sees a truncated body.
+		if hj, ok := w.(http.Hijacker); ok {
+			w.(http.Flusher).Flush()
+			conn, _, _ := hj.Hijack()
+			conn.Close()
+		}
+	}))
+	return srv, &ranges
+}
+
+func TestSurfDownloadResumesTruncatedBody(t *testing.T) {
+	const content = "0123456789"
+	srv, ranges := flakyRangeServer(t, content)
+	defer srv.Close()
+
+	r := New().Download(&DefaultRequest{URL: srv.URL, RetryPause: time.Millisecond})
+	if r.IsErr() {
+		t.Fatalf("Download() err: %v", r.UnwrapErr())
+	}
+	resp := r.Unwrap()
+	if _, ok := resp.Body.(*RangeBody); !ok {
+		t.Fatalf("Body = %T, want *RangeBody", resp.Body)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("ReadAll err: %v", err)
+	}
+	if string(body) != content {
+		t.Errorf("body = %q, want %q", body, content)
+	}
+	if atomic.LoadInt32(ranges) != 1 {
+		t.Errorf("range requests = %d, want 1", *ranges)
+	}
+}
+
+func TestRangeBodyResumeFrom(t *testing.T) {
+	const content = "0123456789"
+	srv, _ := flakyRangeServer(t, content)
+	defer srv.Close()
+
+	r := New().Download(&DefaultRequest{URL: srv.URL, RetryPause: time.Millisecond})
+	if r.IsErr() {
+		t.Fatalf("Download() err: %v", r.UnwrapErr())
+	}
+	resp := r.Unwrap()
+	defer resp.Body.Close()
+	rs, ok := resp.Body.(Resumer)
+	if !ok {
+		t.Fatalf("Body = %T, want Resumer", resp.Body)
+	}
+	if err := rs.ResumeFrom(7); err != nil {
+		t.Fatalf("ResumeFrom err: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "789" {
+		t.Errorf("body = %q, want %q", body, "789")
+	}
+	if err := rs.ResumeFrom(11); err == nil {
+		t.Error("ResumeFrom beyond length: want error")
+	}
+}
+
+func TestRangeBodyResumeFromEnd(t *testing.T) {
+	const content = "0123456789"
+	srv, ranges := flakyRangeServer(t, content)
+	defer srv.Close()
+
+	r := New().Download(&DefaultRequest{URL: srv.URL, RetryPause: time.Millisecond})
+	if r.IsErr() {
+		t.Fatalf("Download() err: %v", r.UnwrapErr())
+	}
+	resp := r.Unwrap()
+	defer resp.Body.Close()
+	if err := resp.Body.(Resumer).ResumeFrom(int64(len(content))); err != nil {
+		t.Fatalf("ResumeFrom(end) err: %v", err)
+	}
+	if body, err := io.ReadAll(resp.Body); err != nil || len(body) != 0 {
+		t.Errorf("ReadAll = %q, %v; want empty, nil", body, err)
+	}
+	if atomic.LoadInt32(ranges) != 0 {
+		t.Errorf("range requests = %d, want 0", *ranges)
+	}
+}
+
+func TestNewRangeBodySkipsUnsupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+	defer srv.Close()
+
+	r := New().Download(&DefaultRequest{URL: srv.URL})
+	if r.IsErr() {
+		t.Fatalf("Download() err: %v", r.UnwrapErr())
+	}
+	resp := r.Unwrap()
+	defer resp.Body.Close()
+	if _, ok := resp.Body.(*RangeBody); ok {
+		t.Error("Body should not be wrapped without Accept-Ranges")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(body, []byte("plain")) {
+		t.Errorf("body = %q, want %q", body, "plain")
+	}
+}
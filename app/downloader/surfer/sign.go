@@ -0,0 +1,112 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Signer signs a request right before Surf sends it, e.g. by setting a
+	// timestamp, nonce and signature header. It is called again for every
+	// attempt, resumed transfer and redirect, so signatures are always
+	// fresh; req is a copy the signer may modify.
+	Signer func(req *http.Request) error
+
+	// SignerRequest is optionally implemented by a Request to be signed by Surf.
+	SignerRequest interface {
+		GetSigner() Signer
+	}
+
+	// AuthorizationRequest is optionally implemented by a Request whose
+	// credentials Surf sends in the Authorization header without adding
+	// them to the headers of the Request.
+	AuthorizationRequest interface {
+		GetAuthorization() string
+	}
+
+	// HMACSigner signs requests with an HMAC over the method, path, sorted
+	// query, timestamp and nonce, each on its own line. Use its Sign method
+	// as a Signer.
+	HMACSigner struct {
+		Key             []byte
+		Hash            func() hash.Hash // nil means SHA-256
+		TimestampHeader string           // default X-Timestamp, Unix seconds
+		NonceHeader     string           // default X-Nonce
+		SignatureHeader string           // default X-Signature, hex encoded
+	}
+)
+
+// Sign implements Signer.
+func (s *HMACSigner) Sign(req *http.Request) error {
+	h := s.Hash
+	if h == nil {
+		h = sha256.New
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := randomNonce()
+	mac := hmac.New(h, s.Key)
+	mac.Write([]byte(strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(), // sorted by key
+		timestamp,
+		nonce,
+	}, "\n")))
+	req.Header.Set(headerOr(s.TimestampHeader, "X-Timestamp"), timestamp)
+	req.Header.Set(headerOr(s.NonceHeader, "X-Nonce"), nonce)
+	req.Header.Set(headerOr(s.SignatureHeader, "X-Signature"), hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// signingTransport signs each request before passing it to next.
+type signingTransport struct {
+	sign Signer
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := t.sign(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+func headerOr(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
+
+// randomNonce returns 16 random bytes, hex encoded.
+func randomNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,57 @@
+package surfer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHMACSigner(t *testing.T) {
+	key := []byte("secret")
+	var (
+		mu     sync.Mutex
+		nonces []string
+	)
+	first := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		nonces = append(nonces, r.Header.Get("X-Nonce"))
+		fail := first
+		first = false
+		mu.Unlock()
+		if fail { // drop the first attempt so that Surf retries
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(strings.Join([]string{
+			r.Method, r.URL.EscapedPath(), r.URL.Query().Encode(),
+			r.Header.Get("X-Timestamp"), r.Header.Get("X-Nonce"),
+		}, "\n")))
+		if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("X-Signature"))) {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	resp := New().Download(&DefaultRequest{
+		URL:        srv.URL + "/api/items?page=2&cat=shoes",
+		TryTimes:   2,
+		RetryPause: time.Millisecond,
+		Signer:     (&HMACSigner{Key: key}).Sign,
+	}).Unwrap()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200 for a valid signature", resp.StatusCode)
+	}
+	if len(nonces) != 2 || nonces[0] == "" || nonces[0] == nonces[1] {
+		t.Errorf("nonces = %q, want a fresh one for each of 2 attempts", nonces)
+	}
+}
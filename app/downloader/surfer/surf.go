@@ -15,20 +15,13 @@
 package surfer
 
 import (
-	"compress/flate"
-	"compress/gzip"
-	"compress/zlib"
-	"crypto/tls"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
-	"strings"
 	"time"
 
-	"github.com/andeya/gust/option"
 	"github.com/andeya/gust/result"
-	"github.com/andeya/gust/syncutil"
 	"github.com/andeya/pholcus/app/downloader/surfer/agent"
 )
 
@@ -53,52 +46,22 @@ func (s *Surf) Download(req Request) (r result.Result[*http.Response]) {
 	defer r.Catch()
 	param := NewParam(req).Unwrap()
 	param.header.Set("Connection", "close")
+	if param.header.Get("Accept-Encoding") == "" {
+		param.header.Set("Accept-Encoding", AcceptEncoding)
+	}
 	param.client = s.buildClient(param)
 	resp, err := s.httpRequest(param)
 	result.RetVoid(err).Unwrap()
 
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		gzipReader, err := gzip.NewReader(resp.Body)
-		result.RetVoid(err).Unwrap()
-		resp.Body = gzipReader
-
-	case "deflate":
-		resp.Body = flate.NewReader(resp.Body)
+	resp.Body = param.meter(newRangeBody(param.client, resp, param.tryTimes, param.retryPause))
 
-	case "zlib":
-		readCloser, err := zlib.NewReader(resp.Body)
-		result.RetVoid(err).Unwrap()
-		resp.Body = readCloser
-	}
+	decodeResponse(resp).Unwrap()
 
 	resp = param.writeback(resp)
 
 	return result.Ok(resp)
 }
 
-var dnsCache = &DnsCache{}
-
-// DnsCache DNS cache
-type DnsCache struct {
-	ipPortLib syncutil.SyncMap[string, string]
-}
-
-// Reg registers ipPort to DNS cache.
-func (d *DnsCache) Reg(addr, ipPort string) {
-	d.ipPortLib.Store(addr, ipPort)
-}
-
-// Del deletes ipPort from DNS cache.
-func (d *DnsCache) Del(addr string) {
-	d.ipPortLib.Delete(addr)
-}
-
-// Query queries ipPort from DNS cache.
-func (d *DnsCache) Query(addr string) option.Option[string] {
-	return d.ipPortLib.Load(addr)
-}
-
 // buildClient creates, configures, and returns a *http.Client type.
 func (s *Surf) buildClient(param *Param) *http.Client {
 	client := &http.Client{
@@ -107,31 +70,14 @@ func (s *Surf) buildClient(param *Param) *http.Client {
 
 	if param.enableCookie {
 		client.Jar = s.CookieJar
+		if param.jar != nil {
+			client.Jar = param.jar
+		}
 	}
 
 	transport := &http.Transport{
 		Dial: func(network, addr string) (net.Conn, error) {
-			var (
-				c     net.Conn
-				err   error
-				ipOpt = dnsCache.Query(addr)
-			)
-			ipPort := addr
-			if ipOpt.IsSome() {
-				ipPort = ipOpt.Unwrap()
-				defer func() {
-					if err != nil {
-						dnsCache.Del(addr)
-					}
-				}()
-			} else {
-				defer func() {
-					if err == nil {
-						dnsCache.Reg(addr, c.RemoteAddr().String())
-					}
-				}()
-			}
-			c, err = net.DialTimeout(network, ipPort, param.dialTimeout)
+			c, err := dnsCache.dial(network, addr, param.dialTimeout)
 			if err != nil {
 				return nil, err
 			}
@@ -146,11 +92,25 @@ func (s *Surf) buildClient(param *Param) *http.Client {
 		transport.Proxy = http.ProxyURL(param.proxy)
 	}
 
-	if strings.ToLower(param.url.Scheme) == "https" {
-		transport.TLSClientConfig = &tls.Config{RootCAs: nil, InsecureSkipVerify: true}
-		transport.DisableCompression = true
-	}
+	transport.TLSClientConfig = param.tlsConfig
+	// Content codings are negotiated and decoded by Surf itself (see AcceptEncoding).
+	transport.DisableCompression = true
 	client.Transport = transport
+
+	if param.http3 && param.proxy == nil {
+		// HTTP/2 is only offered as the fallback of HTTP/3; it modifies the
+		// TLS config, which is shared.
+		transport.TLSClientConfig = param.tlsConfig.Clone()
+		transport.ForceAttemptHTTP2 = true
+		client.Transport = &h3RoundTripper{
+			h3:       h3Transport(param.tlsConfig),
+			fallback: transport,
+			timeout:  param.connTimeout,
+		}
+	}
+	if param.signer != nil {
+		client.Transport = &signingTransport{sign: param.signer, next: client.Transport}
+	}
 	return client
 }
 
@@ -167,7 +127,7 @@ func (s *Surf) httpRequest(param *Param) (resp *http.Response, err error) {
 		for {
 			resp, err = param.client.Do(req)
 			if err != nil {
-				if !param.enableCookie {
+				if !param.enableCookie && param.profile == nil {
 					l := len(agent.UserAgents["common"])
 					r := rand.New(rand.NewSource(time.Now().UnixNano()))
 					req.Header.Set("User-Agent", agent.UserAgents["common"][r.Intn(l)])
@@ -181,7 +141,7 @@ func (s *Surf) httpRequest(param *Param) (resp *http.Response, err error) {
 		for i := 0; i < param.tryTimes; i++ {
 			resp, err = param.client.Do(req)
 			if err != nil {
-				if !param.enableCookie {
+				if !param.enableCookie && param.profile == nil {
 					l := len(agent.UserAgents["common"])
 					r := rand.New(rand.NewSource(time.Now().UnixNano()))
 					req.Header.Set("User-Agent", agent.UserAgents["common"][r.Intn(l)])
@@ -12,6 +12,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/andeya/pholcus/app/downloader/surfer/agent"
 )
 
 func TestNew(t *testing.T) {
@@ -30,6 +32,24 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestSurfDownloadProfile(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer srv.Close()
+
+	p := agent.LookupProfile("chrome-macos")
+	req := &DefaultRequest{URL: srv.URL, Profile: p.Name, Header: http.Header{"Accept-Language": {"zh-CN,zh;q=0.9"}}}
+	New().Download(req).Unwrap().Body.Close()
+	if got.Get("User-Agent") != p.UserAgent || got.Get("Sec-Ch-Ua-Platform") != `"macOS"` {
+		t.Errorf("headers = %v, want those of %s", got, p.Name)
+	}
+	if got.Get("Accept-Language") != "zh-CN,zh;q=0.9" {
+		t.Errorf("Accept-Language = %q, want the request's own", got.Get("Accept-Language"))
+	}
+}
+
 func TestSurfDownload(t *testing.T) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -257,6 +277,8 @@ func TestSurfDownloadHTTPS(t *testing.T) {
 		RetryPause:  time.Millisecond,
 		DialTimeout: time.Second,
 		ConnTimeout: time.Second,
+		// httptest certificates are self-signed
+		TLS: &TLSOptions{InsecureSkipVerify: true},
 	}
 	r := s.Download(req)
 	if r.IsErr() {
@@ -394,17 +416,3 @@ func (m *mockRequest) GetRetryPause() time.Duration  { return time.Millisecond }
 func (m *mockRequest) GetProxy() string              { return "" }
 func (m *mockRequest) GetRedirectTimes() int         { return 0 }
 func (m *mockRequest) GetDownloaderID() int          { return m.downloaderID }
-
-func TestDnsCache(t *testing.T) {
-	dc := &DnsCache{}
-	dc.Reg("host:80", "127.0.0.1:80")
-	opt := dc.Query("host:80")
-	if !opt.IsSome() || opt.Unwrap() != "127.0.0.1:80" {
-		t.Errorf("Query = %v, want Some(127.0.0.1:80)", opt)
-	}
-	dc.Del("host:80")
-	opt2 := dc.Query("host:80")
-	if opt2.IsSome() {
-		t.Errorf("Query after Del = %v, want None", opt2)
-	}
-}
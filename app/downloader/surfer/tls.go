@@ -0,0 +1,85 @@
+// Copyright 2015 andeya Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package surfer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/andeya/gust/result"
+	"github.com/andeya/gust/syncutil"
+)
+
+type (
+	// TLSOptions configures HTTPS connections of the Surf downloader.
+	// Certificates are verified against the system roots unless
+	// InsecureSkipVerify is set; file paths point to PEM files.
+	TLSOptions struct {
+		InsecureSkipVerify bool     // skip server certificate verification
+		RootCAs            []string // CA files trusted in addition to the system roots
+		ClientCert         string   // client certificate file for mutual TLS
+		ClientKey          string   // private key file of ClientCert
+	}
+
+	// TLSRequest is optionally implemented by a Request to customize TLS.
+	TLSRequest interface {
+		// nil means default verification against the system roots
+		GetTLS() *TLSOptions
+	}
+)
+
+// tlsConfigs caches built configs so certificate files are read once.
+var tlsConfigs syncutil.SyncMap[string, *tls.Config]
+
+// IsZero reports whether o is nil or equivalent to the default settings.
+func (o *TLSOptions) IsZero() bool {
+	return o == nil || (!o.InsecureSkipVerify && len(o.RootCAs) == 0 && o.ClientCert == "" && o.ClientKey == "")
+}
+
+// Config builds the *tls.Config described by o; nil for default settings.
+func (o *TLSOptions) Config() (r result.Result[*tls.Config]) {
+	defer r.Catch()
+	if o.IsZero() {
+		return result.Ok[*tls.Config](nil)
+	}
+	key := fmt.Sprintf("%t|%s|%s|%s", o.InsecureSkipVerify, strings.Join(o.RootCAs, ","), o.ClientCert, o.ClientKey)
+	if c := tlsConfigs.Load(key); c.IsSome() {
+		return result.Ok(c.Unwrap())
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	if len(o.RootCAs) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range o.RootCAs {
+			pem := result.Ret(os.ReadFile(file)).Unwrap()
+			if !pool.AppendCertsFromPEM(pem) {
+				return result.TryErr[*tls.Config](fmt.Errorf("tls: no certificate found in %s", file))
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	if o.ClientCert != "" || o.ClientKey != "" {
+		cert := result.Ret(tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)).Unwrap()
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	tlsConfigs.Store(key, cfg)
+	return result.Ok(cfg)
+}
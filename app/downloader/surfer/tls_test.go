@@ -0,0 +1,113 @@
+package surfer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes a PEM block to dir/name and returns the path.
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// clientCertFiles generates a self-signed client certificate.
+func clientCertFiles(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pholcus"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDER)
+}
+
+func TestSurfTLSVerification(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	ca := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+
+	tests := []struct {
+		name    string
+		tls     *TLSOptions
+		wantErr bool
+	}{
+		{"default verifies", nil, true},
+		{"insecure", &TLSOptions{InsecureSkipVerify: true}, false},
+		{"custom root CA", &TLSOptions{RootCAs: []string{ca}}, false},
+		{"missing CA file", &TLSOptions{RootCAs: []string{ca + ".missing"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New().Download(&DefaultRequest{URL: srv.URL, TLS: tt.tls, TryTimes: 1})
+			if r.IsErr() != tt.wantErr {
+				t.Fatalf("Download() IsErr = %v, want %v", r.IsErr(), tt.wantErr)
+			}
+			if r.IsOk() {
+				r.Unwrap().Body.Close()
+			}
+		})
+	}
+}
+
+func TestSurfTLSClientCertificate(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ca := writePEM(t, dir, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+	cert, key := clientCertFiles(t, dir)
+
+	if r := New().Download(&DefaultRequest{URL: srv.URL, TLS: &TLSOptions{RootCAs: []string{ca}}, TryTimes: 1}); r.IsOk() {
+		r.Unwrap().Body.Close()
+		t.Fatal("Download() without client certificate should fail")
+	}
+
+	r := New().Download(&DefaultRequest{URL: srv.URL, TLS: &TLSOptions{RootCAs: []string{ca}, ClientCert: cert, ClientKey: key}, TryTimes: 1})
+	if r.IsErr() {
+		t.Fatalf("Download() err: %v", r.UnwrapErr())
+	}
+	resp := r.Unwrap()
+	defer resp.Body.Close()
+	buf := make([]byte, 16)
+	n, _ := resp.Body.Read(buf)
+	if string(buf[:n]) != "pholcus" {
+		t.Errorf("server saw client %q, want %q", buf[:n], "pholcus")
+	}
+}
@@ -2,12 +2,14 @@
 package collector
 
 import (
+	"io"
 	"runtime/debug"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/andeya/gust/result"
+	"github.com/andeya/pholcus/app/aid/history"
 	"github.com/andeya/pholcus/app/pipeline/collector/data"
 	"github.com/andeya/pholcus/app/spider"
 	"github.com/andeya/pholcus/logs"
@@ -28,6 +30,14 @@ type Collector struct {
 	sum         [4]uint64
 	dataSumLock sync.RWMutex
 	fileSumLock sync.RWMutex
+	stages      []*StageStats // counters of the item pipeline stages
+	stagesLock  sync.Mutex
+	addedFields map[string][]string      // fields added by the item pipeline, by rule
+	itemRecords *history.Items           // item keys of this run, see spider.Dedup
+	itemHist    *history.Items           // item keys persisted across runs
+	held        map[string]data.DataCell // items held by spider.DedupLatest
+	heldKeys    []string                 // keys of held in order
+	duplicates  uint64                   // items dropped by spider.Dedup
 }
 
 // NewCollector creates a new Collector for the given spider.
@@ -42,6 +52,10 @@ func NewCollector(sp *spider.Spider, outType string, batchCap int) *Collector {
 		DataChan: make(chan data.DataCell, batchCap),
 		FileChan: make(chan data.FileCell, batchCap),
 		dataBuf:  make([]data.DataCell, 0, batchCap),
+
+		itemRecords: history.NewItems(sp.GetName(), ""),
+		held:        make(map[string]data.DataCell),
+		addedFields: make(map[string][]string),
 	}
 }
 
@@ -57,8 +71,15 @@ func (c *Collector) CollectData(dataCell data.DataCell) (r result.VoidResult) {
 	return result.OkVoid()
 }
 
-// CollectFile sends a file cell to the collector.
+// CollectFile sends a file cell to the collector. A streamed file is written
+// by the caller, so that it counts against the thread limit of the crawler,
+// and the error of writing it is returned.
 func (c *Collector) CollectFile(fileCell data.FileCell) (r result.VoidResult) {
+	if _, ok := fileCell["Reader"].(io.ReadCloser); ok {
+		atomic.AddUint64(&c.fileBatch, 1)
+		c.wait.Add(1)
+		return result.RetVoid(c.outputFile(fileCell))
+	}
 	defer func() {
 		if p := recover(); p != nil {
 			logs.Log().Error("panic recovered: %v\n%s", p, debug.Stack())
@@ -101,15 +122,17 @@ func (c *Collector) Start() {
 					logs.Log().Error("panic recovered: %v\n%s", p, debug.Stack())
 				}
 			}()
-			for data := range c.DataChan {
-				c.dataBuf = append(c.dataBuf, data)
-
-				if len(c.dataBuf) < c.batchCap {
+			for cell := range c.DataChan {
+				if !c.process(cell) {
+					data.PutDataCell(cell)
 					continue
 				}
-
-				c.dataBatch++
-				c.outputData()
+				if c.dedup(cell) {
+					c.addData(cell)
+				}
+			}
+			for _, cell := range c.pullHeld() {
+				c.addData(cell)
 			}
 			c.dataBatch++
 			c.outputData()
@@ -122,10 +145,16 @@ func (c *Collector) Start() {
 					logs.Log().Error("panic recovered: %v\n%s", p, debug.Stack())
 				}
 			}()
+			// Files are written by at most as many goroutines as crawlers.
+			writers := make(chan struct{}, max(cache.Task.ThreadNum, 1))
 			for file := range c.FileChan {
 				atomic.AddUint64(&c.fileBatch, 1)
 				c.wait.Add(1)
-				go c.outputFile(file)
+				writers <- struct{}{}
+				go func(file data.FileCell) {
+					defer func() { <-writers }()
+					c.outputFile(file)
+				}(file)
 			}
 			close(fileStop)
 		}()
@@ -139,6 +168,16 @@ func (c *Collector) Start() {
 	}()
 }
 
+// addData buffers cell, outputting the buffer when it is full.
+func (c *Collector) addData(cell data.DataCell) {
+	c.dataBuf = append(c.dataBuf, cell)
+	if len(c.dataBuf) < c.batchCap {
+		return
+	}
+	c.dataBatch++
+	c.outputData()
+}
+
 func (c *Collector) resetDataBuf() {
 	for _, cell := range c.dataBuf {
 		data.PutDataCell(cell)
@@ -178,11 +217,23 @@ func (c *Collector) addFileSum(add uint64) {
 
 // Report sends the collection report to the report channel.
 func (c *Collector) Report() {
+	for _, st := range c.StageStats() {
+		logs.Log().Informational(" *     [Item pipeline: %v | %v]: in %v, dropped %v, failed %v",
+			c.Spider.GetName(), st.Name, st.In, st.Dropped, st.Failed)
+	}
+	if n := c.Duplicates(); n > 0 {
+		logs.Log().Informational(" *     [Item dedup: %v]: %v duplicates dropped", c.Spider.GetName(), n)
+	}
+	responses, banned := c.Spider.GetBanStats()
 	cache.ReportChan <- &cache.Report{
 		SpiderName: c.Spider.GetName(),
 		Keyin:      c.GetKeyin(),
 		DataNum:    c.dataSum(),
 		FileNum:    c.fileSum(),
+		DataSize:   c.Spider.GetDownloadSize(),
+		Responses:  responses,
+		Banned:     banned,
+		Rejected:   c.Spider.GetRejected(),
 		Time:       time.Since(cache.StartTime),
 	}
 }
@@ -1,12 +1,19 @@
 package collector
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/andeya/gust/result"
+	"github.com/andeya/pholcus/app/downloader"
+	"github.com/andeya/pholcus/app/downloader/request"
 	"github.com/andeya/pholcus/app/pipeline/collector/data"
 	"github.com/andeya/pholcus/app/spider"
 	"github.com/andeya/pholcus/config"
@@ -351,6 +358,203 @@ func TestCollector_OutputFile(t *testing.T) {
 	}
 }
 
+type resumableReader struct {
+	io.Reader
+	content string
+	offset  int64
+}
+
+func (r *resumableReader) Close() error { return nil }
+
+func (r *resumableReader) Validator() string { return `"v1"` }
+
+func (r *resumableReader) ResumeFrom(offset int64) error {
+	r.offset = offset
+	r.Reader = strings.NewReader(r.content[offset:])
+	return nil
+}
+
+func TestWriteStream(t *testing.T) {
+	tmp := t.TempDir()
+	fileName := filepath.Join(tmp, "big.bin")
+
+	size, err := writeStream(fileName, io.NopCloser(strings.NewReader("0123456789")), 0)
+	if err != nil || size != 10 {
+		t.Fatalf("writeStream = %d, %v; want 10, nil", size, err)
+	}
+	if content, _ := readFile(fileName); content != "0123456789" {
+		t.Errorf("content = %q", content)
+	}
+	if _, err := os.Stat(fileName + ".part"); !os.IsNotExist(err) {
+		t.Error(".part file should be renamed on success")
+	}
+
+	// A leftover .part file of another version is restarted.
+	if err := os.WriteFile(fileName+".part", []byte("ABCD"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fileName+".part.validator", []byte(`"v0"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &resumableReader{Reader: strings.NewReader("abcdefgh"), content: "abcdefgh"}
+	size, err = writeStream(fileName, r, 0)
+	if err != nil || size != 8 || r.offset != 0 {
+		t.Fatalf("writeStream restart = %d, %v, offset %d; want 8, nil, 0", size, err, r.offset)
+	}
+	if content, _ := readFile(fileName); content != "abcdefgh" {
+		t.Errorf("restarted content = %q, want %q", content, "abcdefgh")
+	}
+
+	// A leftover .part file of the same version is continued from its size.
+	if err := os.WriteFile(fileName+".part", []byte("abcd"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fileName+".part.validator", []byte(`"v1"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r = &resumableReader{Reader: strings.NewReader("abcdefgh"), content: "abcdefgh"}
+	size, err = writeStream(fileName, r, 0)
+	if err != nil || size != 8 {
+		t.Fatalf("writeStream resume = %d, %v; want 8, nil", size, err)
+	}
+	if r.offset != 4 {
+		t.Errorf("ResumeFrom offset = %d, want 4", r.offset)
+	}
+	if content, _ := readFile(fileName); content != "abcdefgh" {
+		t.Errorf("resumed content = %q, want %q", content, "abcdefgh")
+	}
+	if _, err := os.Stat(fileName + ".part.validator"); !os.IsNotExist(err) {
+		t.Error(".part.validator file should be removed on success")
+	}
+
+	// Exceeding maxSize fails and leaves nothing behind.
+	other := filepath.Join(tmp, "limited.bin")
+	if _, err := writeStream(other, io.NopCloser(strings.NewReader("0123456789")), 5); err == nil {
+		t.Error("writeStream over limit: want error")
+	}
+	for _, name := range []string{other, other + ".part"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist", name)
+		}
+	}
+}
+
+func TestWriteStream_ResumeDownload(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	var ranges int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+	defer ts.Close()
+
+	sp := &spider.Spider{
+		Name:      "ResumeSpider",
+		Bandwidth: 1 << 20, // the body goes through the bandwidth meter
+		RuleTree:  &spider.RuleTree{Trunk: map[string]*spider.Rule{}},
+	}
+	sp.Register()
+	req := &request.Request{URL: ts.URL, Rule: "r", TryTimes: 1}
+	req.Prepare()
+	ctx := downloader.SurferDownloader.Download(sp, req)
+	if ctx.GetError() != nil {
+		t.Fatalf("Download err: %v", ctx.GetError())
+	}
+
+	fileName := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(fileName+".part", []byte(content[:8]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fileName+".part.validator", []byte(`"v1"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	size, err := writeStream(fileName, ctx.Response.Body, 0)
+	if err != nil || size != int64(len(content)) {
+		t.Fatalf("writeStream = %d, %v; want %d, nil", size, err, len(content))
+	}
+	if got, _ := readFile(fileName); got != content {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+	if atomic.LoadInt32(&ranges) != 1 {
+		t.Errorf("range requests = %d, want 1", ranges)
+	}
+}
+
+func TestCollector_OutputFile_Stream(t *testing.T) {
+	tmp := t.TempDir()
+	conf := config.Conf()
+	oldFileDir := conf.FileDir
+	conf.FileDir = tmp
+	defer func() { conf.FileDir = oldFileDir }()
+
+	sp := &spider.Spider{
+		Name:     "StreamSpider",
+		RuleTree: &spider.RuleTree{Trunk: map[string]*spider.Rule{}},
+	}
+	c := NewCollector(sp, "csv", 1)
+	c.wait.Add(1)
+	fc := data.GetFileStreamCell("r1", "stream.txt", io.NopCloser(strings.NewReader("streamed")))
+	c.outputFile(fc)
+
+	matches, _ := filepath.Glob(filepath.Join(tmp, "*", "stream.txt"))
+	if len(matches) != 1 {
+		t.Fatalf("stream.txt files = %v, want 1", matches)
+	}
+	content, err := readFile(matches[0])
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if content != "streamed" {
+		t.Errorf("content = %q, want %q", content, "streamed")
+	}
+	if c.fileSum() != 1 {
+		t.Errorf("fileSum = %d, want 1", c.fileSum())
+	}
+}
+
+// failingReader fails after returning some content, like a reset connection.
+type failingReader struct{ read bool }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.read = true
+	return copy(p, "partial"), nil
+}
+
+func (r *failingReader) Close() error { return nil }
+
+func TestCollector_CollectFile_StreamError(t *testing.T) {
+	tmp := t.TempDir()
+	conf := config.Conf()
+	oldFileDir := conf.FileDir
+	conf.FileDir = tmp
+	defer func() { conf.FileDir = oldFileDir }()
+
+	sp := &spider.Spider{
+		Name:     "StreamErrSpider",
+		RuleTree: &spider.RuleTree{Trunk: map[string]*spider.Rule{}},
+	}
+	c := NewCollector(sp, "csv", 1)
+	c.Start()
+	defer c.Stop()
+
+	r := c.CollectFile(data.GetFileStreamCell("r1", "broken.txt", &failingReader{}))
+	if !r.IsErr() {
+		t.Fatal("CollectFile of a failing stream: want error")
+	}
+	if c.fileSum() != 0 {
+		t.Errorf("fileSum = %d, want 0", c.fileSum())
+	}
+	if matches, _ := filepath.Glob(filepath.Join(tmp, "*", "broken.txt")); len(matches) != 0 {
+		t.Errorf("broken.txt written: %v", matches)
+	}
+}
+
 func readFile(path string) (string, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
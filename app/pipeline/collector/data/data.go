@@ -2,6 +2,7 @@
 package data
 
 import (
+	"io"
 	"sync"
 )
 
@@ -17,6 +18,9 @@ type (
 	DataCell map[string]interface{}
 	// FileCell is a storage unit for file data.
 	// Stored path format: file/"Dir"/"RuleName"/"time"/"Name"
+	// The content is held either in memory ("Bytes") or as a stream ("Reader")
+	// that the file pipeline copies straight to disk while collecting it, and
+	// then closes.
 	FileCell map[string]interface{}
 )
 
@@ -53,6 +57,15 @@ func GetFileCell(ruleName, name string, bytes []byte) FileCell {
 	return cell
 }
 
+// GetFileStreamCell returns a FileCell from the pool whose content is streamed from r.
+func GetFileStreamCell(ruleName, name string, r io.ReadCloser) FileCell {
+	cell := fileCellPool.Get().(FileCell)
+	cell[FieldRuleName] = ruleName
+	cell["Name"] = name
+	cell["Reader"] = r
+	return cell
+}
+
 // PutDataCell returns a DataCell to the pool.
 func PutDataCell(cell DataCell) {
 	cell[FieldRuleName] = nil
@@ -68,5 +81,6 @@ func PutFileCell(cell FileCell) {
 	cell[FieldRuleName] = nil
 	cell["Name"] = nil
 	cell["Bytes"] = nil
+	cell["Reader"] = nil
 	fileCellPool.Put(cell)
 }
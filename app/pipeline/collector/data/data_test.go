@@ -1,6 +1,8 @@
 package data
 
 import (
+	"io"
+	"strings"
 	"testing"
 )
 
@@ -52,6 +54,21 @@ func TestPutDataCell(t *testing.T) {
 	}
 }
 
+func TestGetFileStreamCell(t *testing.T) {
+	r := io.NopCloser(strings.NewReader("stream"))
+	cell := GetFileStreamCell("rule3", "big.bin", r)
+	if cell[FieldRuleName] != "rule3" || cell["Name"] != "big.bin" {
+		t.Errorf("cell = %v", cell)
+	}
+	if cell["Reader"] != r {
+		t.Error("Reader not set")
+	}
+	PutFileCell(cell)
+	if cell["Reader"] != nil {
+		t.Error("Reader should be nil after Put")
+	}
+}
+
 func TestPutFileCell(t *testing.T) {
 	cell := GetFileCell("r", "f", []byte{1})
 	PutFileCell(cell)
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"sync/atomic"
+
+	"github.com/andeya/pholcus/app/aid/history"
+	"github.com/andeya/pholcus/app/pipeline/collector/data"
+	"github.com/andeya/pholcus/app/spider"
+	"github.com/andeya/pholcus/logs"
+)
+
+// dedup applies the Dedup of the item's rule to cell, reporting whether it
+// is output now. Duplicates are released; DedupLatest holds the items for
+// pullHeld.
+func (c *Collector) dedup(cell data.DataCell) bool {
+	ruleName, _ := cell["RuleName"].(string)
+	rule := c.Spider.GetRule(ruleName)
+	if rule == nil || rule.Dedup == nil {
+		return true
+	}
+	item := cell["Data"].(map[string]interface{})
+	key, ok := rule.Dedup.ItemKey(item)
+	if !ok {
+		return true
+	}
+	key = ruleName + "__" + key
+
+	if rule.Dedup.Policy == spider.DedupLatest {
+		if held, ok := c.held[key]; ok {
+			data.PutDataCell(held)
+			atomic.AddUint64(&c.duplicates, 1)
+		} else {
+			c.heldKeys = append(c.heldKeys, key)
+		}
+		c.held[key] = cell
+		return false
+	}
+
+	store := c.itemRecords
+	if rule.Dedup.Persist {
+		store = c.itemHistory()
+	}
+	digest := spider.ItemDigest(item)
+	if last, seen := store.Get(key); seen && (rule.Dedup.Policy != spider.DedupChanged || last == digest) {
+		data.PutDataCell(cell)
+		atomic.AddUint64(&c.duplicates, 1)
+		return false
+	}
+	store.Set(key, digest)
+	return true
+}
+
+// pullHeld returns the items held by DedupLatest in the order of their keys.
+func (c *Collector) pullHeld() []data.DataCell {
+	cells := make([]data.DataCell, len(c.heldKeys))
+	for i, key := range c.heldKeys {
+		cells[i] = c.held[key]
+	}
+	c.held = make(map[string]data.DataCell)
+	c.heldKeys = nil
+	return cells
+}
+
+// itemHistory returns the item records persisted across runs, read on first use.
+func (c *Collector) itemHistory() *history.Items {
+	if c.itemHist == nil {
+		c.itemHist = history.NewItems(c.Spider.GetName(), c.Spider.GetSubName())
+		c.itemHist.Read(c.outType)
+	}
+	return c.itemHist
+}
+
+// flushItemHistory writes the new persisted item records after an output.
+func (c *Collector) flushItemHistory() {
+	if c.itemHist == nil {
+		return
+	}
+	n, err := c.itemHist.Flush(c.outType).Split()
+	if err != nil {
+		logs.Log().Error("%v", err)
+	} else if n > 0 {
+		logs.Log().Informational(" *     [add item record]: %v\n", n)
+	}
+}
+
+// Duplicates returns the number of items dropped as duplicates by Dedup.
+func (c *Collector) Duplicates() uint64 {
+	return atomic.LoadUint64(&c.duplicates)
+}
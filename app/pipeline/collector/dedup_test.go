@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andeya/pholcus/app/pipeline/collector/data"
+	"github.com/andeya/pholcus/app/spider"
+	"github.com/andeya/pholcus/config"
+)
+
+func dedupSpider(d *spider.Dedup) *spider.Spider {
+	return &spider.Spider{
+		Name:     "dedup",
+		RuleTree: &spider.RuleTree{Trunk: map[string]*spider.Rule{"r": {Dedup: d}, "other": {}}},
+	}
+}
+
+func dedupItems(c *Collector, items ...map[string]interface{}) (out []interface{}) {
+	for _, item := range items {
+		cell := data.GetDataCell("r", item, "u", "", "")
+		if c.dedup(cell) {
+			out = append(out, item["v"])
+		}
+	}
+	for _, cell := range c.pullHeld() {
+		out = append(out, cell["Data"].(map[string]interface{})["v"])
+	}
+	return out
+}
+
+func TestCollector_Dedup(t *testing.T) {
+	items := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"id": "1", "shop": "a", "v": 1},
+			{"id": "1", "shop": "b", "v": 2},
+			{"id": "1", "shop": "a", "v": 1},
+			{"id": "1", "shop": "a", "v": 3},
+			{"id": "2", "shop": "a", "v": 4},
+			{"id": " ", "v": 5},
+			{"v": 6},
+		}
+	}
+	tests := []struct {
+		name  string
+		dedup *spider.Dedup
+		want  []interface{}
+		dupes uint64
+	}{
+		{"drop", &spider.Dedup{Key: []string{"id"}}, []interface{}{1, 4, 5, 6}, 3},
+		{"drop compound key", &spider.Dedup{Key: []string{"id", "shop"}}, []interface{}{1, 2, 4, 5, 6}, 2},
+		{"changed", &spider.Dedup{Key: []string{"id"}, Policy: spider.DedupChanged}, []interface{}{1, 2, 1, 3, 4, 5, 6}, 0},
+		{"latest", &spider.Dedup{Key: []string{"id"}, Policy: spider.DedupLatest}, []interface{}{5, 6, 3, 4}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(dedupSpider(tt.dedup), "csv", 10)
+			got := dedupItems(c, items()...)
+			if len(got) != len(tt.want) {
+				t.Fatalf("output = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("output = %v, want %v", got, tt.want)
+				}
+			}
+			if c.Duplicates() != tt.dupes {
+				t.Errorf("Duplicates() = %d, want %d", c.Duplicates(), tt.dupes)
+			}
+		})
+	}
+
+	c := NewCollector(dedupSpider(&spider.Dedup{Key: []string{"id"}}), "csv", 10)
+	if !c.dedup(data.GetDataCell("other", map[string]interface{}{"id": "1"}, "u", "", "")) ||
+		!c.dedup(data.GetDataCell("other", map[string]interface{}{"id": "1"}, "u", "", "")) {
+		t.Error("item of a rule without Dedup dropped")
+	}
+}
+
+func TestCollector_DedupPersist(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, config.HistoryDir), 0777); err != nil {
+		t.Fatal(err)
+	}
+	orig, _ := os.Getwd()
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(orig)
+
+	run := func(policy spider.DedupPolicy, items ...map[string]interface{}) []interface{} {
+		c := NewCollector(dedupSpider(&spider.Dedup{Key: []string{"id"}, Policy: policy, Persist: true}), "csv", 10)
+		out := dedupItems(c, items...)
+		c.flushItemHistory()
+		return out
+	}
+	if got := run(spider.DedupChanged, map[string]interface{}{"id": "1", "v": 1}, map[string]interface{}{"id": "2", "v": 2}); len(got) != 2 {
+		t.Fatalf("first run output = %v", got)
+	}
+	got := run(spider.DedupChanged, map[string]interface{}{"id": "1", "v": 1}, map[string]interface{}{"id": "2", "v": 3}, map[string]interface{}{"id": "3", "v": 4})
+	if len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("second run output = %v, want [3 4]", got)
+	}
+	if got := run(spider.DedupDrop, map[string]interface{}{"id": "2", "v": 5}, map[string]interface{}{"id": "4", "v": 6}); len(got) != 1 || got[0] != 6 {
+		t.Errorf("third run output = %v, want [6]", got)
+	}
+}
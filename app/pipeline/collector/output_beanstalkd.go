@@ -28,13 +28,9 @@ func init() {
 			var subNamespace = util.FileNameReplace(col.subNamespace(datacell))
 
 			tmp := make(map[string]interface{})
-			for _, title := range col.MustGetRule(datacell["RuleName"].(string)).ItemFields {
+			for _, title := range col.itemFields(datacell["RuleName"].(string)) {
 				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					tmp[title] = v
-				} else {
-					tmp[title] = util.JSONString(vd[title])
-				}
+				tmp[title] = cellValue(vd[title])
 			}
 			if col.Spider.OutDefaultField() {
 				tmp["Url"] = datacell["Url"].(string)
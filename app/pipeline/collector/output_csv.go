@@ -43,7 +43,7 @@ func init() {
 				file.WriteString("\xEF\xBB\xBF") // UTF-8 BOM
 
 				sheets[subNamespace] = csv.NewWriter(file)
-				th := col.MustGetRule(datacell["RuleName"].(string)).ItemFields
+				th := col.itemFields(datacell["RuleName"].(string))
 				if col.Spider.OutDefaultField() {
 					th = append(th, "Url", "ParentUrl", "DownloadTime")
 				}
@@ -51,13 +51,9 @@ func init() {
 			}
 
 			row := []string{}
-			for _, title := range col.MustGetRule(datacell["RuleName"].(string)).ItemFields {
+			for _, title := range col.itemFields(datacell["RuleName"].(string)) {
 				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					row = append(row, v)
-				} else {
-					row = append(row, util.JSONString(vd[title]))
-				}
+				row = append(row, cellText(vd[title]))
 			}
 			if col.Spider.OutDefaultField() {
 				row = append(row, datacell["Url"].(string))
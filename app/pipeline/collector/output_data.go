@@ -53,6 +53,7 @@ func (c *Collector) outputData() {
 		logs.Log().App(" *     [Data output: %v | KEYIN: %v | Batch: %v]  %v records!\n",
 			c.Spider.GetName(), c.Spider.GetKeyin(), c.dataBatch, dataLen)
 		c.Spider.TryFlushSuccess()
+		c.flushItemHistory()
 	}
 }
 
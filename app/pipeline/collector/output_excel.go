@@ -3,6 +3,7 @@ package collector
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/andeya/gust/result"
 	"github.com/andeya/pholcus/common/util"
@@ -37,7 +38,7 @@ func init() {
 				sheet := r.Unwrap()
 				sheets[subNamespace] = sheet
 				row = sheets[subNamespace].AddRow()
-				for _, title := range col.MustGetRule(datacell["RuleName"].(string)).ItemFields {
+				for _, title := range col.itemFields(datacell["RuleName"].(string)) {
 					row.AddCell().Value = title
 				}
 				if col.Spider.OutDefaultField() {
@@ -48,13 +49,20 @@ func init() {
 			}
 
 			row = sheets[subNamespace].AddRow()
-			for _, title := range col.MustGetRule(datacell["RuleName"].(string)).ItemFields {
+			for _, title := range col.itemFields(datacell["RuleName"].(string)) {
 				cell = row.AddCell()
 				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					cell.Value = v
-				} else {
-					cell.Value = util.JSONString(vd[title])
+				switch v := vd[title].(type) {
+				case int64:
+					cell.SetInt64(v)
+				case float64:
+					cell.SetFloat(v)
+				case bool:
+					cell.SetBool(v)
+				case time.Time:
+					cell.SetDateTime(v)
+				default:
+					cell.Value = cellText(v)
 				}
 			}
 			if col.Spider.OutDefaultField() {
@@ -2,6 +2,7 @@ package collector
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -17,7 +18,7 @@ import (
 )
 
 // outputFile writes a file cell to disk.
-func (c *Collector) outputFile(file data.FileCell) {
+func (c *Collector) outputFile(file data.FileCell) error {
 	defer func() {
 		data.PutFileCell(file)
 		c.wait.Done()
@@ -36,28 +37,22 @@ func (c *Collector) outputFile(file data.FileCell) {
 				" *     Fail  [File download: %v | KEYIN: %v | Batch: %v]   %v [ERROR]  %v\n",
 				c.Spider.GetName(), c.Spider.GetKeyin(), atomic.LoadUint64(&c.fileBatch), fileName, r.UnwrapErr(),
 			)
-			return
+			return r.UnwrapErr()
 		}
 	}
 
-	// Create file with 0777 if not exists, truncate if exists
-	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
-	if err != nil {
-		logs.Log().Error(
-			" *     Fail  [File download: %v | KEYIN: %v | Batch: %v]   %v [ERROR]  %v\n",
-			c.Spider.GetName(), c.Spider.GetKeyin(), atomic.LoadUint64(&c.fileBatch), fileName, err,
-		)
-		return
+	var size int64
+	if r, ok := file["Reader"].(io.ReadCloser); ok {
+		size, err = writeStream(fileName, r, config.Conf().Download.MaxFileSize)
+	} else {
+		size, err = writeBytes(fileName, file["Bytes"].([]byte))
 	}
-	defer closer.LogClose(f, logs.Log().Error)
-
-	size, err := io.Copy(f, bytes.NewReader(file["Bytes"].([]byte)))
 	if err != nil {
 		logs.Log().Error(
 			" *     Fail  [File download: %v | KEYIN: %v | Batch: %v]   %v (%s) [ERROR]  %v\n",
 			c.Spider.GetName(), c.Spider.GetKeyin(), atomic.LoadUint64(&c.fileBatch), fileName, bytesSize.Format(uint64(size)), err,
 		)
-		return
+		return err
 	}
 
 	c.addFileSum(1)
@@ -68,4 +63,88 @@ func (c *Collector) outputFile(file data.FileCell) {
 		c.Spider.GetName(), c.Spider.GetKeyin(), atomic.LoadUint64(&c.fileBatch), fileName, bytesSize.Format(uint64(size)),
 	)
 	logs.Log().Informational(" * ")
+	return nil
+}
+
+// writeBytes creates or truncates fileName and writes b to it.
+func writeBytes(fileName string, b []byte) (int64, error) {
+	// Create file with 0777 if not exists, truncate if exists
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return 0, err
+	}
+	defer closer.LogClose(f, logs.Log().Error)
+	return io.Copy(f, bytes.NewReader(b))
+}
+
+// writeStream copies r into fileName through a ".part" file that is renamed
+// on success. A leftover ".part" file from an interrupted download is
+// continued when r supports resuming at an offset and its content has the
+// validator saved next to the ".part" file; maxSize > 0 caps the total file
+// size.
+func writeStream(fileName string, r io.ReadCloser, maxSize int64) (size int64, err error) {
+	defer closer.LogClose(r, logs.Log().Error)
+	partName := fileName + ".part"
+	validatorName := partName + ".validator"
+
+	rs, _ := r.(interface {
+		ResumeFrom(int64) error
+		Validator() string
+	})
+	var validator string
+	if rs != nil {
+		validator = rs.Validator()
+	}
+	flag := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if fi, err := os.Stat(partName); err == nil && fi.Size() > 0 && rs != nil {
+		saved, _ := os.ReadFile(validatorName)
+		switch {
+		case validator == "" || string(saved) != validator:
+			logs.Log().Warning(" *     [File download]: cannot resume %v, restarting: content changed or unknown\n", fileName)
+		case rs.ResumeFrom(fi.Size()) == nil:
+			flag = os.O_RDWR | os.O_APPEND
+			size = fi.Size()
+			logs.Log().Informational(" *     [File download]: resuming %v from %s\n", fileName, bytesSize.Format(uint64(size)))
+		default:
+			logs.Log().Warning(" *     [File download]: cannot resume %v, restarting: range request failed\n", fileName)
+		}
+	}
+	if flag&os.O_TRUNC != 0 {
+		// Save the version of the new content for resuming later.
+		if validator != "" {
+			err = os.WriteFile(validatorName, []byte(validator), 0666)
+		} else if err = os.Remove(validatorName); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	f, err := os.OpenFile(partName, flag, 0777)
+	if err != nil {
+		return 0, err
+	}
+
+	var src io.Reader = r
+	if maxSize > 0 {
+		src = io.LimitReader(r, maxSize-size+1)
+	}
+	n, err := io.Copy(f, src)
+	size += n
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && maxSize > 0 && size > maxSize {
+		os.Remove(partName)
+		os.Remove(validatorName)
+		return size, fmt.Errorf("file exceeds the size limit of %s", bytesSize.Format(uint64(maxSize)))
+	}
+	if err != nil {
+		return size, err
+	}
+	if err = os.Rename(partName, fileName); err == nil {
+		os.Remove(validatorName)
+	}
+	return size, err
 }
@@ -62,13 +62,9 @@ func init() {
 				}
 			}
 			data := make(map[string]interface{})
-			for _, title := range col.MustGetRule(datacell["RuleName"].(string)).ItemFields {
+			for _, title := range col.itemFields(datacell["RuleName"].(string)) {
 				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
-					data[title] = v
-				} else {
-					data[title] = util.JSONString(vd[title])
-				}
+				data[title] = cellValue(vd[title])
 			}
 			if col.Spider.OutDefaultField() {
 				data["url"] = datacell["Url"].(string)
@@ -4,8 +4,10 @@ package collector
 
 import (
 	"sync"
+	"time"
 
 	"github.com/andeya/gust/result"
+	"github.com/andeya/pholcus/app/spider"
 	"github.com/andeya/pholcus/common/mysql"
 	"github.com/andeya/pholcus/common/util"
 )
@@ -53,8 +55,9 @@ func init() {
 				} else {
 					table = mysql.New().Unwrap()
 					table.SetTableName(tName)
-					for _, title := range col.MustGetRule(datacell["RuleName"].(string)).ItemFields {
-						table.AddColumn(title + ` MEDIUMTEXT`)
+					ruleName := datacell["RuleName"].(string)
+					for _, title := range col.itemFields(ruleName) {
+						table.AddColumn(title + ` ` + mysqlType(col.schemaField(ruleName, title)))
 					}
 					if col.Spider.OutDefaultField() {
 						table.AddColumn(`Url VARCHAR(255)`, `ParentUrl VARCHAR(255)`, `DownloadTime VARCHAR(50)`)
@@ -64,19 +67,27 @@ func init() {
 					mysqls[tName] = table
 				}
 			}
-			data := []string{}
-			for _, title := range col.MustGetRule(datacell["RuleName"].(string)).ItemFields {
+			data := []interface{}{}
+			ruleName := datacell["RuleName"].(string)
+			for _, title := range col.itemFields(ruleName) {
 				vd := datacell["Data"].(map[string]interface{})
-				if v, ok := vd[title].(string); ok || vd[title] == nil {
+				switch v := vd[title].(type) {
+				case nil:
+					if mysqlType(col.schemaField(ruleName, title)) == `MEDIUMTEXT` {
+						data = append(data, "")
+					} else {
+						data = append(data, nil)
+					}
+				case int64, float64, bool, time.Time:
 					data = append(data, v)
-				} else {
-					data = append(data, util.JSONString(vd[title]))
+				default:
+					data = append(data, cellText(v))
 				}
 			}
 			if col.Spider.OutDefaultField() {
 				data = append(data, datacell["Url"].(string), datacell["ParentUrl"].(string), datacell["DownloadTime"].(string))
 			}
-			table.AutoInsert(data)
+			table.AutoInsertValues(data)
 		}
 		for _, tab := range mysqls {
 			tab.FlushInsert().Unwrap()
@@ -85,3 +96,21 @@ func init() {
 		return result.OkVoid()
 	}
 }
+
+// mysqlType returns the column type of a field, typed by its schema.
+func mysqlType(f *spider.Field) string {
+	if f == nil {
+		return `MEDIUMTEXT`
+	}
+	switch f.Type {
+	case spider.TypeInt:
+		return `BIGINT`
+	case spider.TypeFloat:
+		return `DOUBLE`
+	case spider.TypeBool:
+		return `TINYINT(1)`
+	case spider.TypeTime:
+		return `DATETIME`
+	}
+	return `MEDIUMTEXT`
+}
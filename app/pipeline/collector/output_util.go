@@ -1,6 +1,9 @@
 package collector
 
 import (
+	"time"
+
+	"github.com/andeya/pholcus/common/util"
 	"github.com/andeya/pholcus/logs"
 )
 
@@ -37,3 +40,27 @@ func joinNamespaces(namespace, subNamespace string) string {
 	}
 	return namespace
 }
+
+// cellText returns a field value as text: strings as they are, times
+// formatted and other values as JSON.
+func cellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	}
+	return util.JSONString(v)
+}
+
+// cellValue returns a field value for outputs keeping types, like the
+// numbers and bools of a spider.Schema, and cellText for others.
+func cellValue(v interface{}) interface{} {
+	switch v.(type) {
+	case int64, float64, bool:
+		return v
+	}
+	return cellText(v)
+}
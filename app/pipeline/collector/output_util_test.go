@@ -3,6 +3,7 @@ package collector
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/andeya/pholcus/app/spider"
 )
@@ -96,3 +97,28 @@ func TestCollector_SubNamespace(t *testing.T) {
 		})
 	}
 }
+
+func TestCellText(t *testing.T) {
+	tm := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	tests := []struct {
+		v     interface{}
+		text  string
+		typed interface{}
+	}{
+		{nil, "", ""},
+		{"a<b>", "a<b>", "a<b>"},
+		{int64(3), "3", int64(3)},
+		{1.5, "1.5", 1.5},
+		{true, "true", true},
+		{tm, "2024-05-06 07:08:09", "2024-05-06 07:08:09"},
+		{[]interface{}{"x", int64(1)}, `["x",1]`, `["x",1]`},
+	}
+	for _, tt := range tests {
+		if got := cellText(tt.v); got != tt.text {
+			t.Errorf("cellText(%v) = %q, want %q", tt.v, got, tt.text)
+		}
+		if got := cellValue(tt.v); got != tt.typed {
+			t.Errorf("cellValue(%v) = %v, want %v", tt.v, got, tt.typed)
+		}
+	}
+}
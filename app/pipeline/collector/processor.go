@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/andeya/pholcus/app/pipeline/collector/data"
+	"github.com/andeya/pholcus/app/spider"
+	"github.com/andeya/pholcus/logs"
+)
+
+// StageStats counts the items through a stage of the item pipeline.
+type StageStats struct {
+	Name    string
+	In      uint64 // items the stage processed
+	Dropped uint64 // items dropped with spider.ErrDropItem
+	Failed  uint64 // items dropped by other errors
+}
+
+// itemFields returns the output columns of the rule ruleName: its
+// ItemFields as named after the FieldMapper stages, then the fields the
+// stages added. The ItemFields stay those of Context.Output.
+func (c *Collector) itemFields(ruleName string) []string {
+	rule := c.Spider.GetRule(ruleName)
+	if rule == nil {
+		return nil
+	}
+	fields := c.Spider.MapItemFields(ruleName, rule.ItemFields)
+	if added := c.addedFields[ruleName]; len(added) > 0 {
+		fields = append(fields[:len(fields):len(fields)], added...)
+	}
+	return fields
+}
+
+// schemaField returns the schema field output as the column name of the
+// rule ruleName, i.e. named after the FieldMapper stages, nil if untyped.
+func (c *Collector) schemaField(ruleName, name string) *spider.Field {
+	rule := c.Spider.GetRule(ruleName)
+	if rule == nil {
+		return nil
+	}
+	for i := range rule.Schema {
+		if mapped := c.Spider.MapItemFields(ruleName, []string{rule.Schema[i].Name}); len(mapped) == 1 && mapped[0] == name {
+			return &rule.Schema[i]
+		}
+	}
+	return nil
+}
+
+// process runs the item pipeline on cell and reports whether it is kept.
+func (c *Collector) process(cell data.DataCell) bool {
+	ruleName, _ := cell["RuleName"].(string)
+	processors := c.Spider.GetProcessors(ruleName)
+	if len(processors) == 0 {
+		return true
+	}
+	for _, p := range processors {
+		st := c.stage(p.Name())
+		atomic.AddUint64(&st.In, 1)
+		err := runStage(p, cell)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, spider.ErrDropItem) {
+			atomic.AddUint64(&st.Dropped, 1)
+		} else {
+			atomic.AddUint64(&st.Failed, 1)
+			logs.Log().Error(" *     [Item pipeline: %v | %v | %v]: %v", c.Spider.GetName(), ruleName, p.Name(), err)
+		}
+		return false
+	}
+	// Stages may add fields, e.g. when enriching items.
+	fields := make(map[string]bool)
+	for _, k := range c.itemFields(ruleName) {
+		fields[k] = true
+	}
+	for k := range cell["Data"].(map[string]interface{}) {
+		if !fields[k] {
+			c.addedFields[ruleName] = append(c.addedFields[ruleName], k)
+		}
+	}
+	return true
+}
+
+// runStage runs p on cell, turning a panic into an error.
+func runStage(p spider.ItemProcessor, cell data.DataCell) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return p.Process(cell)
+}
+
+// stage returns the counters of the stage name.
+func (c *Collector) stage(name string) *StageStats {
+	c.stagesLock.Lock()
+	defer c.stagesLock.Unlock()
+	for _, st := range c.stages {
+		if st.Name == name {
+			return st
+		}
+	}
+	st := &StageStats{Name: name}
+	c.stages = append(c.stages, st)
+	return st
+}
+
+// StageStats returns the counters of the item pipeline stages.
+func (c *Collector) StageStats() []StageStats {
+	c.stagesLock.Lock()
+	defer c.stagesLock.Unlock()
+	stats := make([]StageStats, len(c.stages))
+	for i, st := range c.stages {
+		stats[i] = StageStats{
+			Name:    st.Name,
+			In:      atomic.LoadUint64(&st.In),
+			Dropped: atomic.LoadUint64(&st.Dropped),
+			Failed:  atomic.LoadUint64(&st.Failed),
+		}
+	}
+	return stats
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/andeya/pholcus/app/pipeline/collector/data"
+	"github.com/andeya/pholcus/app/spider"
+	spidercommon "github.com/andeya/pholcus/app/spider/common"
+)
+
+func TestCollector_Process(t *testing.T) {
+	sp := &spider.Spider{
+		Name:       "S",
+		Processors: []spider.ItemProcessor{spidercommon.NormalizeSpace()},
+		RuleTree: &spider.RuleTree{Trunk: map[string]*spider.Rule{
+			"r": {
+				ItemFields: []string{"title", "price"},
+				Processors: []spider.ItemProcessor{
+					spidercommon.Rename(map[string]string{"title": "name"}),
+					spidercommon.DropEmpty("name"),
+					spidercommon.ParseNumber("price"),
+					spider.ProcessFunc("enrich", func(cell data.DataCell) error {
+						item := cell["Data"].(map[string]interface{})
+						if item["price"] == nil {
+							return errors.New("no price")
+						}
+						item["cheap"] = item["price"].(float64) < 10
+						return nil
+					}),
+				},
+			},
+		}},
+	}
+	c := NewCollector(sp, "csv", 10)
+	if got := c.itemFields("r"); !reflect.DeepEqual(got, []string{"name", "price"}) {
+		t.Fatalf("itemFields = %v", got)
+	}
+
+	cell := data.GetDataCell("r", map[string]interface{}{"title": " Go \n in Action ", "price": "￥9.5"}, "u", "", "")
+	if !c.process(cell) {
+		t.Fatal("item dropped")
+	}
+	want := map[string]interface{}{"name": "Go in Action", "price": 9.5, "cheap": true}
+	if got := cell["Data"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("item = %v, want %v", got, want)
+	}
+	if got := c.itemFields("r"); !reflect.DeepEqual(got, []string{"name", "price", "cheap"}) {
+		t.Errorf("itemFields = %v", got)
+	}
+	// Context.Output and CreateItem keep using the fields before the pipeline.
+	if got := sp.GetRule("r").ItemFields; !reflect.DeepEqual(got, []string{"title", "price"}) {
+		t.Errorf("ItemFields = %v", got)
+	}
+
+	if c.process(data.GetDataCell("r", map[string]interface{}{"title": " "}, "u", "", "")) {
+		t.Error("empty item kept")
+	}
+	if c.process(data.GetDataCell("r", map[string]interface{}{"title": "a", "price": "free"}, "u", "", "")) {
+		t.Error("failed item kept")
+	}
+
+	stats := c.StageStats()
+	wantStats := []StageStats{
+		{Name: "NormalizeSpace", In: 3},
+		{Name: "Rename", In: 3},
+		{Name: "DropEmpty(name)", In: 3, Dropped: 1},
+		{Name: "ParseNumber(price)", In: 2},
+		{Name: "enrich", In: 2, Failed: 1},
+	}
+	if !reflect.DeepEqual(stats, wantStats) {
+		t.Errorf("stats = %+v\nwant %+v", stats, wantStats)
+	}
+}
+
+func TestCollector_SchemaField(t *testing.T) {
+	sp := &spider.Spider{
+		Name: "S",
+		RuleTree: &spider.RuleTree{Trunk: map[string]*spider.Rule{
+			"r": {
+				Schema:     spider.Schema{{Name: "price", Type: spider.TypeFloat}, {Name: "title"}},
+				Processors: []spider.ItemProcessor{spidercommon.Rename(map[string]string{"price": "cost"})},
+			},
+		}},
+	}
+	c := NewCollector(sp, "mysql", 10)
+	if f := c.schemaField("r", "cost"); f == nil || f.Name != "price" {
+		t.Errorf("schemaField(cost) = %v, want the price field", f)
+	}
+	if mysqlType(c.schemaField("r", "cost")) != `DOUBLE` {
+		t.Errorf("renamed typed column = %s, want DOUBLE", mysqlType(c.schemaField("r", "cost")))
+	}
+	if f := c.schemaField("r", "price"); f != nil {
+		t.Errorf("schemaField(price) = %v, want nil after the rename", f)
+	}
+}
+
+func TestCollector_ProcessPanic(t *testing.T) {
+	sp := &spider.Spider{
+		Name: "S",
+		Processors: []spider.ItemProcessor{spider.ProcessFunc("panic", func(cell data.DataCell) error {
+			panic("boom")
+		})},
+		RuleTree: &spider.RuleTree{Trunk: map[string]*spider.Rule{"r": {}}},
+	}
+	c := NewCollector(sp, "csv", 1)
+	if c.process(data.GetDataCell("r", map[string]interface{}{}, "u", "", "")) {
+		t.Error("item kept after panic")
+	}
+	if stats := c.StageStats(); len(stats) != 1 || stats[0].Failed != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -13,6 +13,7 @@ type Pipeline interface {
 	Start()
 	Stop()
 	CollectData(data.DataCell) result.VoidResult
+	// CollectFile also fails when a streamed file cannot be written.
 	CollectFile(data.FileCell) result.VoidResult
 }
 
@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"net/http"
 	"runtime/debug"
 	"sort"
 	"sync"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/andeya/pholcus/app/aid/history"
+	"github.com/andeya/pholcus/app/aid/proxy"
 	"github.com/andeya/pholcus/app/downloader/request"
 	"github.com/andeya/pholcus/logs"
 	"github.com/andeya/pholcus/runtime/cache"
@@ -24,6 +26,7 @@ type Matrix struct {
 	history         history.HistoryStore        // history
 	tempHistory     map[string]bool             // temp record [reqUnique(url+method)]true
 	failures        map[string]*request.Request // historical and current failed requests
+	proxySessions   *proxy.Sessions             // proxies of sticky sessions
 	tempHistoryLock sync.RWMutex
 	failureLock     sync.Mutex
 	sync.Mutex
@@ -31,13 +34,14 @@ type Matrix struct {
 
 func newMatrix(spiderName, spiderSubName string, maxPage int64) *Matrix {
 	matrix := &Matrix{
-		spiderName:  spiderName,
-		maxPage:     maxPage,
-		reqs:        make(map[int][]*request.Request),
-		priorities:  []int{},
-		history:     history.New(spiderName, spiderSubName),
-		tempHistory: make(map[string]bool),
-		failures:    make(map[string]*request.Request),
+		spiderName:    spiderName,
+		maxPage:       maxPage,
+		reqs:          make(map[int][]*request.Request),
+		priorities:    []int{},
+		history:       history.New(spiderName, spiderSubName),
+		tempHistory:   make(map[string]bool),
+		failures:      make(map[string]*request.Request),
+		proxySessions: proxy.NewSessions(proxy.Policy{}, nil),
 	}
 	if cache.Task.Mode != status.SERVER {
 		matrix.history.ReadSuccess(cache.Task.OutType, cache.Task.SuccessInherit)
@@ -112,8 +116,8 @@ func (m *Matrix) Pull() (req *request.Request) {
 			if req.GetProxy() != "" {
 				return
 			}
-			if sched.useProxy {
-				req.SetProxy(sched.proxy.GetOne(req.GetURL()).UnwrapOr(""))
+			if sched.usingProxy() {
+				req.SetProxy(m.proxySessions.Get(sched.proxy, req))
 			} else {
 				req.SetProxy("")
 			}
@@ -123,6 +127,14 @@ func (m *Matrix) Pull() (req *request.Request) {
 	return
 }
 
+// SetProxyPolicy sets how requests pulled from this Matrix get proxies;
+// jar is the cookie jar shared by the spider's requests.
+func (m *Matrix) SetProxyPolicy(policy proxy.Policy, jar http.CookieJar) {
+	m.Lock()
+	defer m.Unlock()
+	m.proxySessions = proxy.NewSessions(policy, jar)
+}
+
 // Use acquires a resource slot for this Matrix.
 func (m *Matrix) Use() {
 	defer func() {
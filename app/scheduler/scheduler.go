@@ -4,6 +4,7 @@ package scheduler
 import (
 	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/andeya/pholcus/app/aid/proxy"
 	"github.com/andeya/pholcus/logs"
@@ -14,7 +15,7 @@ import (
 type scheduler struct {
 	status       int          // running status
 	count        chan bool    // total concurrency count
-	useProxy     bool         // whether proxy IP is used
+	proxyOn      bool         // whether proxy IPs are enabled, see usingProxy
 	proxy        *proxy.Proxy // global proxy IP
 	matrices     []*Matrix    // request matrices per Spider instance
 	sync.RWMutex              // global read-write lock
@@ -33,27 +34,56 @@ func Init(threadNum int, proxyMinute int64) {
 	sched.count = make(chan bool, threadNum)
 
 	if proxyMinute > 0 {
+		sched.proxyOn = true
+		sched.proxy.UpdateTicker(proxyMinute)
 		if sched.proxy.Count() > 0 {
-			sched.useProxy = true
-			sched.proxy.UpdateTicker(proxyMinute)
-			logs.Log().Informational(" *     Using proxy IP, rotation interval: %v minutes\n", proxyMinute)
+			logs.Log().Informational(" *     Using proxy IP, pool retest interval: %v minutes\n", proxyMinute)
 		} else {
-			sched.useProxy = false
-			logs.Log().Informational(" *     Proxy IP list is empty, cannot use proxy\n")
+			logs.Log().Informational(" *     Proxy IP list is empty, proxies are used once a source provides some\n")
 		}
 	} else {
-		sched.useProxy = false
+		sched.proxyOn = false
 		logs.Log().Informational(" *     Not using proxy IP\n")
 	}
 
 	sched.status = status.RUN
 }
 
-// ReloadProxyLib reloads the proxy IP list from the config file.
+// ReloadProxyLib reloads the proxy IP list from all proxy sources.
 func ReloadProxyLib() {
 	sched.proxy.Update()
 }
 
+// SetProxySources replaces the sources of the proxy pool, by default those
+// of the config file; changes of the sources are merged while crawling.
+func SetProxySources(sources ...proxy.Source) {
+	sched.proxy.SetSources(sources...)
+}
+
+// ChangeProxy penalizes proxy for the host of u and returns the next proxy
+// to use for it; "" when proxies are not in use.
+func ChangeProxy(proxy, u string) string {
+	if !sched.usingProxy() {
+		return ""
+	}
+	sched.proxy.Penalize(proxy, u)
+	return sched.proxy.GetOne(u).UnwrapOr("")
+}
+
+// ReportProxy records the outcome of a request to u through proxy in the
+// proxy pool health; a no-op when proxies are not in use.
+func ReportProxy(proxyURL, u string, o proxy.Outcome, latency time.Duration) {
+	if !sched.usingProxy() || proxyURL == "" {
+		return
+	}
+	sched.proxy.Report(proxyURL, u, o, latency)
+}
+
+// ProxyHealth returns the proxy pool state of every host.
+func ProxyHealth() []proxy.HostHealth {
+	return sched.proxy.Health()
+}
+
 // AddMatrix registers a resource queue for the given spider and returns its Matrix.
 func AddMatrix(spiderName, spiderSubName string, maxPage int64) *Matrix {
 	matrix := newMatrix(spiderName, spiderSubName, maxPage)
@@ -89,6 +119,12 @@ func Stop() {
 	sched.matrices = []*Matrix{}
 }
 
+// usingProxy reports whether requests get proxy IPs: proxies are enabled
+// and the pool, which its sources may fill while crawling, has some online.
+func (sched *scheduler) usingProxy() bool {
+	return sched.proxyOn && sched.proxy.Count() > 0
+}
+
 // avgRes returns the average resources allocated per spider instance.
 func (sched *scheduler) avgRes() int32 {
 	avg := int32(cap(sched.count) / len(sched.matrices))
@@ -1,8 +1,11 @@
 package scheduler
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/andeya/pholcus/app/aid/proxy"
 	"github.com/andeya/pholcus/app/downloader/request"
 	"github.com/andeya/pholcus/runtime/cache"
 	"github.com/andeya/pholcus/runtime/status"
@@ -288,3 +291,25 @@ func TestMatrix_Pull_request_with_proxy_passthrough(t *testing.T) {
 		t.Errorf("Pull with existing proxy should preserve it, got %v", got)
 	}
 }
+
+func TestMatrix_Pull_proxy_added_after_Init(t *testing.T) {
+	SetProxySources()
+	Init(4, 5)
+	defer Init(4, 0)
+	m := AddMatrix("sp", "", -10)
+	m.Push(makeReq("http://a.com", "r"))
+	if got := m.Pull(); got == nil || got.GetProxy() != "" {
+		t.Fatalf("Pull with an empty pool = %v, want no proxy", got)
+	}
+
+	// A source fills the pool while crawling.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	addr := "http://" + srv.Listener.Addr().String()
+	SetProxySources(proxy.StaticSource{addr})
+	defer SetProxySources()
+	m.Push(makeReq(srv.URL+"/page", "r"))
+	if got := m.Pull(); got == nil || got.GetProxy() != addr {
+		t.Errorf("Pull after the pool was filled = %v, want proxy %s", got, addr)
+	}
+}
@@ -0,0 +1,303 @@
+package spider
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+)
+
+// Authenticator adds credentials to the requests of a spider. Set it in
+// Spider.Auth; the downloader calls Authorize before sending a request and
+// Unauthorized when the server answers 401.
+type Authenticator interface {
+	// Authorize sets the credentials of req, usually with SetAuthorization,
+	// which sends them without persisting them with the request.
+	Authorize(req *request.Request) error
+	// Unauthorized handles a 401 answer to req, e.g. by renewing a token;
+	// true retries req once.
+	Unauthorized(req *request.Request, resp *http.Response) (retry bool, err error)
+}
+
+var (
+	_ Authenticator = (*BasicAuth)(nil)
+	_ Authenticator = (*DigestAuth)(nil)
+	_ Authenticator = (*OAuth2)(nil)
+)
+
+// BasicAuth authenticates with HTTP Basic credentials.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authorize implements Authenticator.
+func (a *BasicAuth) Authorize(req *request.Request) error {
+	r := http.Request{Header: make(http.Header)}
+	r.SetBasicAuth(a.Username, a.Password)
+	req.SetAuthorization(r.Header.Get("Authorization"))
+	return nil
+}
+
+// Unauthorized implements Authenticator; wrong credentials are not retried.
+func (a *BasicAuth) Unauthorized(*request.Request, *http.Response) (bool, error) {
+	return false, nil
+}
+
+// DigestAuth authenticates with HTTP Digest credentials (RFC 7616, MD5 and
+// SHA-256). The first request of a spider is sent without credentials to
+// obtain the server's challenge.
+type DigestAuth struct {
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	challenge map[string]string // parameters of the last WWW-Authenticate: Digest
+	nc        uint32            // nonce count of the challenge
+}
+
+// Authorize implements Authenticator.
+func (a *DigestAuth) Authorize(req *request.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.challenge == nil {
+		return nil
+	}
+	u, err := url.Parse(req.GetURL())
+	if err != nil {
+		return err
+	}
+	method := req.GetMethod()
+	if method == "POST-M" {
+		method = "POST"
+	}
+	c := a.challenge
+	algorithm := c["algorithm"]
+	var h func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		h = md5.New
+	case "SHA-256":
+		h = sha256.New
+	default:
+		return fmt.Errorf("digest auth: unsupported algorithm %q", algorithm)
+	}
+	digest := func(s string) string {
+		d := h()
+		io.WriteString(d, s)
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	a.nc++
+	nc := fmt.Sprintf("%08x", a.nc)
+	cnonce := randomHex(8)
+	uri := u.RequestURI()
+	ha1 := digest(a.Username + ":" + c["realm"] + ":" + a.Password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = digest(ha1 + ":" + c["nonce"] + ":" + cnonce)
+	}
+	ha2 := digest(method + ":" + uri)
+
+	var qop string
+	for _, q := range strings.Split(c["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	fields := []string{
+		fmt.Sprintf(`username=%q`, a.Username),
+		fmt.Sprintf(`realm=%q`, c["realm"]),
+		fmt.Sprintf(`nonce=%q`, c["nonce"]),
+		fmt.Sprintf(`uri=%q`, uri),
+	}
+	if qop != "" {
+		fields = append(fields,
+			fmt.Sprintf(`response=%q`, digest(ha1+":"+c["nonce"]+":"+nc+":"+cnonce+":"+qop+":"+ha2)),
+			"qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce=%q`, cnonce))
+	} else {
+		fields = append(fields, fmt.Sprintf(`response=%q`, digest(ha1+":"+c["nonce"]+":"+ha2)))
+	}
+	if algorithm != "" {
+		fields = append(fields, "algorithm="+algorithm)
+	}
+	if opaque, ok := c["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf(`opaque=%q`, opaque))
+	}
+	req.SetAuthorization("Digest " + strings.Join(fields, ", "))
+	return nil
+}
+
+// Unauthorized implements Authenticator: it retries with the new challenge,
+// unless the rejected request already answered a current one.
+func (a *DigestAuth) Unauthorized(req *request.Request, resp *http.Response) (bool, error) {
+	var challenge map[string]string
+	for _, v := range resp.Header.Values("WWW-Authenticate") {
+		if len(v) > 7 && strings.EqualFold(v[:7], "Digest ") {
+			challenge = parseAuthParams(v[7:])
+			break
+		}
+	}
+	if challenge == nil {
+		return false, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sent := req.GetAuthorization()
+	if strings.HasPrefix(sent, "Digest ") && !strings.EqualFold(challenge["stale"], "true") &&
+		parseAuthParams(sent[7:])["nonce"] == challenge["nonce"] {
+		return false, nil // credentials rejected
+	}
+	if a.challenge == nil || a.challenge["nonce"] != challenge["nonce"] {
+		a.challenge, a.nc = challenge, 0
+	}
+	return true, nil
+}
+
+// OAuth2 authenticates with bearer tokens from an OAuth2 token endpoint,
+// using the refresh-token grant if RefreshToken is set and the
+// client-credentials grant otherwise. Tokens are renewed when they expire or
+// the server answers 401; concurrent requests share one renewal.
+type OAuth2 struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RefreshToken string       // replaced when the endpoint rotates it
+	AccessToken  string       // optional initial token
+	Client       *http.Client // for the token endpoint; nil uses a client with a 30s timeout
+
+	mu     sync.Mutex
+	expiry time.Time // zero if unknown
+}
+
+// tokenExpiryDelta renews tokens this long before they expire.
+const tokenExpiryDelta = 10 * time.Second
+
+// Authorize implements Authenticator.
+func (a *OAuth2) Authorize(req *request.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.AccessToken == "" || (!a.expiry.IsZero() && time.Now().Add(tokenExpiryDelta).After(a.expiry)) {
+		if err := a.renew(); err != nil {
+			return err
+		}
+	}
+	req.SetAuthorization("Bearer " + a.AccessToken)
+	return nil
+}
+
+// Unauthorized implements Authenticator: the token req was sent with is
+// renewed unless another request already did so.
+func (a *OAuth2) Unauthorized(req *request.Request, _ *http.Response) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if req.GetAuthorization() == "Bearer "+a.AccessToken {
+		if err := a.renew(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// renew fetches a new access token; a.mu must be held.
+func (a *OAuth2) renew() error {
+	form := url.Values{"client_id": {a.ClientID}}
+	if a.ClientSecret != "" {
+		form.Set("client_secret", a.ClientSecret)
+	}
+	if a.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", a.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(a.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.Scopes, " "))
+	}
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.PostForm(a.TokenURL, form)
+	if err != nil {
+		return fmt.Errorf("oauth2: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("oauth2: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth2: token endpoint returned %s: %s", resp.Status, body)
+	}
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("oauth2: %w", err)
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("oauth2: no access_token in response: %s", body)
+	}
+	a.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		a.RefreshToken = token.RefreshToken
+	}
+	a.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		a.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return nil
+}
+
+// parseAuthParams parses the comma-separated key=value parameters of an
+// authentication challenge or credentials.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = strings.TrimSpace(s[i+1:])
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			j := 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			val, s = b.String(), s[min(j+1, len(s)):]
+		} else if j := strings.IndexByte(s, ','); j >= 0 {
+			val, s = strings.TrimSpace(s[:j]), s[j:]
+		} else {
+			val, s = strings.TrimSpace(s), ""
+		}
+		params[key] = val
+		s = strings.TrimLeft(s, ", ")
+	}
+	return params
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
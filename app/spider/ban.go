@@ -0,0 +1,114 @@
+package spider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+)
+
+// ErrBanned marks a download whose response was recognized as a ban or
+// captcha page even after retrying with new identities.
+var ErrBanned = errors.New("banned")
+
+const (
+	DefaultBanRetries = 2       // default retries with a new identity after a ban
+	banPeekSize       = 1 << 20 // max body bytes inspected by ban detectors
+)
+
+// BanDetector recognizes a ban or captcha page. A response is banned when
+// any of the set criteria matches.
+type BanDetector struct {
+	StatusCodes []int                                       // e.g. 403, 429
+	BodyRegexp  *regexp.Regexp                              // matched against the start of the body
+	RedirectTo  *regexp.Regexp                              // matched against the final URL after redirects
+	Func        func(resp *http.Response, body []byte) bool // custom check, body as for BodyRegexp
+}
+
+// match returns why resp is banned, or "" if it is not.
+func (d *BanDetector) match(resp *http.Response, body func() []byte) string {
+	for _, code := range d.StatusCodes {
+		if resp.StatusCode == code {
+			return fmt.Sprintf("status %d", code)
+		}
+	}
+	if d.RedirectTo != nil && resp.Request != nil && resp.Request.URL != nil {
+		if u := resp.Request.URL.String(); d.RedirectTo.MatchString(u) {
+			return "redirected to " + u
+		}
+	}
+	if d.BodyRegexp != nil && d.BodyRegexp.Match(body()) {
+		return "body matches " + d.BodyRegexp.String()
+	}
+	if d.Func != nil && d.Func(resp, body()) {
+		return "custom detector"
+	}
+	return ""
+}
+
+// DetectBan checks resp against the spider's ban detectors and returns the
+// reason of the first match, or "" if none matches. The inspected part of
+// the body is put back, so resp can still be read in full.
+func (sp *Spider) DetectBan(resp *http.Response) string {
+	if resp == nil || len(sp.BanDetectors) == 0 {
+		return ""
+	}
+	var peeked []byte
+	var read bool
+	body := func() []byte {
+		if !read && resp.Body != nil {
+			read = true
+			peeked, _ = io.ReadAll(io.LimitReader(resp.Body, banPeekSize))
+			resp.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(peeked), resp.Body), body: resp.Body}
+		}
+		return peeked
+	}
+	for _, d := range sp.BanDetectors {
+		if reason := d.match(resp, body); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// peekedBody reads the bytes inspected by ban detectors before the rest of body.
+type peekedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (b *peekedBody) Close() error {
+	return b.body.Close()
+}
+
+// Unwrap returns the original body, e.g. to reach a *surfer.ChromeBody.
+func (b *peekedBody) Unwrap() io.ReadCloser {
+	return b.body
+}
+
+// GetBanRetries returns how often a banned request is retried with a new identity.
+func (sp *Spider) GetBanRetries() int {
+	switch {
+	case sp.BanRetries < 0:
+		return 0
+	case sp.BanRetries == 0:
+		return DefaultBanRetries
+	}
+	return sp.BanRetries
+}
+
+// CountResponse records a downloaded response for the ban statistics.
+func (sp *Spider) CountResponse(banned bool) {
+	atomic.AddUint64(&sp.responses, 1)
+	if banned {
+		atomic.AddUint64(&sp.banned, 1)
+	}
+}
+
+// GetBanStats returns the number of downloaded responses and how many of them were banned.
+func (sp *Spider) GetBanStats() (responses, banned uint64) {
+	return atomic.LoadUint64(&sp.responses), atomic.LoadUint64(&sp.banned)
+}
@@ -0,0 +1,102 @@
+package spider
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/app/downloader/surfer"
+)
+
+func banTestResponse(status int, finalURL, body string) *http.Response {
+	u, _ := url.Parse(finalURL)
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    &http.Request{URL: u},
+	}
+}
+
+func TestDetectBan(t *testing.T) {
+	sp := &Spider{BanDetectors: []*BanDetector{
+		{StatusCodes: []int{403, 429}},
+		{RedirectTo: regexp.MustCompile(`/captcha`)},
+		{BodyRegexp: regexp.MustCompile(`(?i)verify you are human`)},
+		{Func: func(resp *http.Response, body []byte) bool { return resp.Header.Get("X-Blocked") != "" }},
+	}}
+	blocked := banTestResponse(200, "http://example.com/", "ok")
+	blocked.Header.Set("X-Blocked", "1")
+	tests := []struct {
+		name   string
+		resp   *http.Response
+		banned bool
+	}{
+		{"ok", banTestResponse(200, "http://example.com/", "hello"), false},
+		{"status", banTestResponse(429, "http://example.com/", ""), true},
+		{"redirect", banTestResponse(200, "http://example.com/captcha?r=1", ""), true},
+		{"body", banTestResponse(200, "http://example.com/", "Please VERIFY you are human"), true},
+		{"func", blocked, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sp.DetectBan(tt.resp); (got != "") != tt.banned {
+				t.Errorf("DetectBan() = %q, want banned=%v", got, tt.banned)
+			}
+		})
+	}
+}
+
+func TestDetectBanKeepsBody(t *testing.T) {
+	sp := &Spider{BanDetectors: []*BanDetector{{BodyRegexp: regexp.MustCompile(`captcha`)}}}
+	resp := banTestResponse(200, "http://example.com/", "plain page")
+	if reason := sp.DetectBan(resp); reason != "" {
+		t.Fatalf("DetectBan() = %q, want none", reason)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "plain page" {
+		t.Errorf("body after DetectBan = %q, want %q", body, "plain page")
+	}
+}
+
+func TestDetectBanKeepsChromeCaptures(t *testing.T) {
+	sp := &Spider{BanDetectors: []*BanDetector{
+		{BodyRegexp: regexp.MustCompile(`captcha`)},
+		{Func: func(resp *http.Response, body []byte) bool { return false }},
+	}}
+	resp := banTestResponse(200, "http://example.com/", "")
+	resp.Body = surfer.NewChromeBody("<html>page</html>",
+		[]surfer.CapturedResponse{{URL: "http://example.com/api", Body: "{}"}}, []byte("png"), []byte("pdf"))
+	if reason := sp.DetectBan(resp); reason != "" {
+		t.Fatalf("DetectBan() = %q, want none", reason)
+	}
+	ctx := GetContext(sp, &request.Request{URL: "http://example.com/", Rule: "r"})
+	defer PutContext(ctx)
+	ctx.SetResponse(resp)
+	if got := ctx.GetCaptures(); len(got) != 1 || got[0].URL != "http://example.com/api" {
+		t.Errorf("captures = %v", got)
+	}
+	if string(ctx.GetScreenshot()) != "png" || string(ctx.GetPDF()) != "pdf" {
+		t.Errorf("screenshot = %q, pdf = %q", ctx.GetScreenshot(), ctx.GetPDF())
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "<html>page</html>" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestBanRetriesAndStats(t *testing.T) {
+	for _, tt := range []struct{ set, want int }{{0, DefaultBanRetries}, {-1, 0}, {5, 5}} {
+		if got := (&Spider{BanRetries: tt.set}).GetBanRetries(); got != tt.want {
+			t.Errorf("BanRetries %d: GetBanRetries() = %d, want %d", tt.set, got, tt.want)
+		}
+	}
+	sp := &Spider{}
+	sp.CountResponse(false)
+	sp.CountResponse(true)
+	if responses, banned := sp.GetBanStats(); responses != 2 || banned != 1 {
+		t.Errorf("GetBanStats() = %d, %d, want 2, 1", responses, banned)
+	}
+}
@@ -0,0 +1,41 @@
+package spider
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// maxBandwidthBurst bounds the bytes a bandwidth limiter grants at once, so
+// that limited downloads flow evenly instead of in one-second bursts.
+const maxBandwidthBurst = 32 << 10
+
+// NewBandwidthLimiter returns a limiter of bytesPerSec, or nil if it is not
+// positive. Its burst is the largest read it allows at once.
+func NewBandwidthLimiter(bytesPerSec int64) *rate.Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSec), int(min(bytesPerSec, maxBandwidthBurst)))
+}
+
+// GetBandwidthLimiter returns the limiter of Bandwidth, or nil if the
+// spider's bandwidth is unlimited. It is shared by all downloads of the
+// spider instance.
+func (sp *Spider) GetBandwidthLimiter() *rate.Limiter {
+	sp.limitOnce.Do(func() {
+		sp.limiter = NewBandwidthLimiter(sp.Bandwidth)
+	})
+	return sp.limiter
+}
+
+// AddDownloadSize records n downloaded bytes.
+func (sp *Spider) AddDownloadSize(n int64) {
+	atomic.AddUint64(&sp.downloaded, uint64(n))
+}
+
+// GetDownloadSize returns the response body bytes downloaded by the spider
+// instance, as received before content decoding.
+func (sp *Spider) GetDownloadSize() uint64 {
+	return atomic.LoadUint64(&sp.downloaded)
+}
@@ -0,0 +1,195 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andeya/pholcus/app/pipeline/collector/data"
+	"github.com/andeya/pholcus/app/spider"
+)
+
+// Built-in item pipeline stages for Spider.Processors and Rule.Processors.
+// Stages taking fields apply to those of the item, or to all if none given.
+
+type (
+	fieldProcessor struct {
+		name   string
+		fields []string
+		fn     func(v interface{}) (interface{}, error)
+	}
+	renamer struct {
+		names map[string]string
+	}
+)
+
+var (
+	spaceRegexp  = regexp.MustCompile(`\s+`)
+	numberRegexp = regexp.MustCompile(`[-+]?(\d[\d,]*(\.\d+)?|\.\d+)`)
+)
+
+// TrimSpace trims the leading and trailing white space of text fields.
+func TrimSpace(fields ...string) spider.ItemProcessor {
+	return newFieldProcessor("TrimSpace", fields, func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return strings.TrimSpace(s), nil
+		}
+		return v, nil
+	})
+}
+
+// NormalizeSpace trims text fields and collapses their runs of white space
+// to a single space.
+func NormalizeSpace(fields ...string) spider.ItemProcessor {
+	return newFieldProcessor("NormalizeSpace", fields, func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return spaceRegexp.ReplaceAllString(strings.TrimSpace(s), " "), nil
+		}
+		return v, nil
+	})
+}
+
+// StripHTML cleans text fields with CleanHtml at depth, trimming the result.
+func StripHTML(depth int, fields ...string) spider.ItemProcessor {
+	return newFieldProcessor("StripHTML", fields, func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return strings.TrimSpace(CleanHtml(s, depth)), nil
+		}
+		return v, nil
+	})
+}
+
+// ParseTime converts fields to time.Time with layout, or spider.TimeLayouts
+// and Unix timestamps if empty. Blank values become nil; others that fail
+// to parse drop the item.
+func ParseTime(layout string, fields ...string) spider.ItemProcessor {
+	f := &spider.Field{Type: spider.TypeTime, Layout: layout}
+	return newFieldProcessor("ParseTime", fields, f.Convert)
+}
+
+// ParseNumber converts fields to float64 from the first number in their
+// text, such as 1299.5 from "￥1,299.50元". Values without a number become nil.
+func ParseNumber(fields ...string) spider.ItemProcessor {
+	return newFieldProcessor("ParseNumber", fields, func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return v, nil
+		}
+		n := numberRegexp.FindString(s)
+		if n == "" {
+			return nil, nil
+		}
+		f, err := strconv.ParseFloat(strings.ReplaceAll(n, ",", ""), 64)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	})
+}
+
+// Rename renames the fields of the items by names, old name to new.
+func Rename(names map[string]string) spider.ItemProcessor {
+	return &renamer{names: names}
+}
+
+// DropIf drops the items for which fn returns true.
+func DropIf(name string, fn func(item map[string]interface{}) bool) spider.ItemProcessor {
+	return spider.ProcessFunc(name, func(cell data.DataCell) error {
+		if fn(cell["Data"].(map[string]interface{})) {
+			return spider.ErrDropItem
+		}
+		return nil
+	})
+}
+
+// DropEmpty drops the items missing any of fields or with it blank.
+func DropEmpty(fields ...string) spider.ItemProcessor {
+	return DropIf(stageName("DropEmpty", fields), func(item map[string]interface{}) bool {
+		for _, k := range fields {
+			switch v := item[k].(type) {
+			case nil:
+				return true
+			case string:
+				if strings.TrimSpace(v) == "" {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func newFieldProcessor(name string, fields []string, fn func(v interface{}) (interface{}, error)) *fieldProcessor {
+	return &fieldProcessor{name: stageName(name, fields), fields: fields, fn: fn}
+}
+
+// stageName names a stage after its fields, telling apart those of one kind.
+func stageName(name string, fields []string) string {
+	if len(fields) == 0 {
+		return name
+	}
+	return name + "(" + strings.Join(fields, ",") + ")"
+}
+
+// Name implements spider.ItemProcessor.
+func (p *fieldProcessor) Name() string { return p.name }
+
+// Process implements spider.ItemProcessor.
+func (p *fieldProcessor) Process(cell data.DataCell) error {
+	item := cell["Data"].(map[string]interface{})
+	fields := p.fields
+	if len(fields) == 0 {
+		fields = make([]string, 0, len(item))
+		for k := range item {
+			fields = append(fields, k)
+		}
+	}
+	for _, k := range fields {
+		v, ok := item[k]
+		if !ok {
+			continue
+		}
+		v, err := p.fn(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", k, err)
+		}
+		item[k] = v
+	}
+	return nil
+}
+
+// Name implements spider.ItemProcessor.
+func (p *renamer) Name() string { return "Rename" }
+
+// Process implements spider.ItemProcessor.
+func (p *renamer) Process(cell data.DataCell) error {
+	item := cell["Data"].(map[string]interface{})
+	renamed := make(map[string]interface{}, len(p.names))
+	for from, to := range p.names {
+		if v, ok := item[from]; ok {
+			delete(item, from)
+			renamed[to] = v
+		}
+	}
+	for k, v := range renamed {
+		item[k] = v
+	}
+	return nil
+}
+
+// MapFields implements spider.FieldMapper.
+func (p *renamer) MapFields(fields []string) []string {
+	out := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, k := range fields {
+		if to, ok := p.names[k]; ok {
+			k = to
+		}
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
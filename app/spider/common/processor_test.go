@@ -0,0 +1,65 @@
+package common
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andeya/pholcus/app/pipeline/collector/data"
+	"github.com/andeya/pholcus/app/spider"
+)
+
+func TestProcessors(t *testing.T) {
+	item := map[string]interface{}{
+		"title": "  <b>Go</b>  in\tAction ",
+		"body":  "<p>Hello <i>world</i></p>",
+		"price": "￥1,299.50元",
+		"stock": "none",
+		"date":  "2024-05-06",
+		"day":   "06/05/2024",
+		"n":     3,
+	}
+	cell := data.DataCell{"Data": item}
+	for _, p := range []spider.ItemProcessor{
+		StripHTML(4, "title", "body"),
+		NormalizeSpace(),
+		ParseNumber("price", "stock"),
+		ParseTime("", "date"),
+		ParseTime("02/01/2006", "day"),
+		Rename(map[string]string{"title": "name", "missing": "x"}),
+	} {
+		if err := p.Process(cell); err != nil {
+			t.Fatalf("%s: %v", p.Name(), err)
+		}
+	}
+	want := map[string]interface{}{
+		"name":  "Go in Action",
+		"body":  "Hello world",
+		"price": 1299.5,
+		"stock": nil,
+		"date":  time.Date(2024, 5, 6, 0, 0, 0, 0, time.Local),
+		"day":   time.Date(2024, 5, 6, 0, 0, 0, 0, time.Local),
+		"n":     3,
+	}
+	if !reflect.DeepEqual(item, want) {
+		t.Errorf("item = %#v\nwant %#v", item, want)
+	}
+
+	if err := ParseTime("", "name").Process(cell); err == nil {
+		t.Error("ParseTime: want an error")
+	}
+	if err := DropEmpty("stock").Process(cell); !errors.Is(err, spider.ErrDropItem) {
+		t.Errorf("DropEmpty(stock) = %v", err)
+	}
+	if err := DropEmpty("name").Process(cell); err != nil {
+		t.Errorf("DropEmpty(name) = %v", err)
+	}
+}
+
+func TestRename_MapFields(t *testing.T) {
+	got := Rename(map[string]string{"a": "c", "b": "x"}).(spider.FieldMapper).MapFields([]string{"a", "b", "c"})
+	if want := []string{"c", "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MapFields = %v, want %v", got, want)
+	}
+}
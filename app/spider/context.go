@@ -2,6 +2,7 @@ package spider
 
 import (
 	"bytes"
+	"errors"
 	"io"
 
 	"mime"
@@ -15,21 +16,31 @@ import (
 	"golang.org/x/net/html/charset"
 
 	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/app/downloader/surfer"
 	"github.com/andeya/pholcus/app/pipeline/collector/data"
 	"github.com/andeya/pholcus/common/goquery"
 	"github.com/andeya/pholcus/common/util"
+	"github.com/andeya/pholcus/config"
 	"github.com/andeya/pholcus/logs"
 )
 
+// ErrBodyTooLarge is reported when a response body exceeds the configured size limit.
+var ErrBodyTooLarge = errors.New("response body exceeds the configured size limit")
+
 // Context carries the state for a single crawl request through its lifecycle.
 type Context struct {
 	spider   *Spider
 	Request  *request.Request
-	Response *http.Response    // URL is copied from *request.Request
-	text     []byte            // response body as raw bytes
-	dom      *goquery.Document // parsed HTML DOM (lazy-initialized)
-	items    []data.DataCell   // collected text output results
-	files    []data.FileCell   // collected file output results
+	Response *http.Response            // URL is copied from *request.Request
+	text     []byte                    // response body as raw bytes
+	dom      *goquery.Document         // parsed HTML DOM (lazy-initialized)
+	json     []byte                    // JSON body with any JSONP callback stripped (lazy-initialized)
+	jsonDoc  interface{}               // decoded JSON body (lazy-initialized)
+	items    []data.DataCell           // collected text output results
+	files    []data.FileCell           // collected file output results
+	captures []surfer.CapturedResponse // XHR/fetch responses captured by the Chrome downloader
+	shot     []byte                    // full-page PNG screenshot taken by the Chrome downloader
+	pdf      []byte                    // page printed to PDF by the Chrome downloader
 	err      error
 	sync.Mutex
 }
@@ -69,6 +80,11 @@ func PutContext(ctx *Context) {
 	ctx.Request = nil
 	ctx.text = nil
 	ctx.dom = nil
+	ctx.json = nil
+	ctx.jsonDoc = nil
+	ctx.captures = nil
+	ctx.shot = nil
+	ctx.pdf = nil
 	ctx.err = nil
 	contextPool.Put(ctx)
 }
@@ -76,6 +92,21 @@ func PutContext(ctx *Context) {
 // SetResponse binds the HTTP response to this context.
 func (ctx *Context) SetResponse(resp *http.Response) *Context {
 	ctx.Response = resp
+	if resp != nil {
+		body := resp.Body
+		for {
+			w, ok := body.(interface{ Unwrap() io.ReadCloser })
+			if !ok {
+				break
+			}
+			body = w.Unwrap()
+		}
+		if body, ok := body.(*surfer.ChromeBody); ok {
+			ctx.captures = body.Captures()
+			ctx.shot = body.Screenshot()
+			ctx.pdf = body.PDF()
+		}
+	}
 	return ctx
 }
 
@@ -106,6 +137,15 @@ func (ctx *Context) AddQueue(req *request.Request) *Context {
 		return ctx
 	}
 
+	if req.GetTLS() == nil {
+		req.SetTLS(ctx.spider.GetTLS())
+	}
+	if req.GetProfile() == "" {
+		req.SetProfile(ctx.spider.GetProfile())
+	}
+	if ctx.spider.HTTP3 {
+		req.SetHTTP3(true)
+	}
 	prepareResult := req.
 		SetSpiderName(ctx.spider.GetName()).
 		SetEnableCookie(ctx.spider.GetEnableCookie()).
@@ -137,6 +177,40 @@ func jsToInt64(v interface{}) (int64, bool) {
 	return 0, false
 }
 
+// jsToActions converts a JS array of action objects into Chrome actions.
+func jsToActions(v interface{}) []surfer.ChromeAction {
+	var objs []map[string]interface{}
+	switch list := v.(type) {
+	case []map[string]interface{}:
+		objs = list
+	case []interface{}:
+		for _, o := range list {
+			if m, ok := o.(map[string]interface{}); ok {
+				objs = append(objs, m)
+			}
+		}
+	}
+	var actions []surfer.ChromeAction
+	for _, m := range objs {
+		var a surfer.ChromeAction
+		a.Type, _ = m["Type"].(string)
+		a.Selector, _ = m["Selector"].(string)
+		a.Value, _ = m["Value"].(string)
+		a.Optional, _ = m["Optional"].(bool)
+		if t, ok := jsToInt64(m["Times"]); ok {
+			a.Times = int(t)
+		}
+		if t, ok := jsToInt64(m["Pause"]); ok {
+			a.Pause = time.Duration(t)
+		}
+		if t, ok := jsToInt64(m["Timeout"]); ok {
+			a.Timeout = time.Duration(t)
+		}
+		actions = append(actions, a)
+	}
+	return actions
+}
+
 // JsAddQueue adds crawl requests from dynamic (JavaScript) rule definitions.
 func (ctx *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 	if ctx.spider.tryStop() != nil {
@@ -187,7 +261,22 @@ func (ctx *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 	if t, ok := jreq["Temp"].(map[string]interface{}); ok {
 		req.Temp = t
 	}
+	req.Actions = jsToActions(jreq["Actions"])
+	req.CaptureXHR, _ = jreq["CaptureXHR"].(string)
+	req.Screenshot, _ = jreq["Screenshot"].(bool)
+	req.PDF, _ = jreq["PDF"].(bool)
+	req.Profile, _ = jreq["Profile"].(string)
+	req.HTTP3, _ = jreq["HTTP3"].(bool)
 
+	if req.GetTLS() == nil {
+		req.SetTLS(ctx.spider.GetTLS())
+	}
+	if req.GetProfile() == "" {
+		req.SetProfile(ctx.spider.GetProfile())
+	}
+	if ctx.spider.HTTP3 {
+		req.SetHTTP3(true)
+	}
 	prepareResult := req.
 		SetSpiderName(ctx.spider.GetName()).
 		SetEnableCookie(ctx.spider.GetEnableCookie()).
@@ -210,6 +299,8 @@ func (ctx *Context) JsAddQueue(jreq map[string]interface{}) *Context {
 //
 // When item is map[int]interface{}, fields are mapped using the existing ItemFields of ruleName.
 // When item is map[string]interface{}, missing ItemFields are auto-added.
+// With a Rule.Schema the fields are coerced to their types, and an invalid
+// item is counted, logged and output to the Rule.Reject rule if set.
 // An empty ruleName defaults to the current rule.
 func (ctx *Context) Output(item interface{}, ruleName ...string) {
 	_ruleName, rule, found := ctx.getRule(ruleName...)
@@ -232,35 +323,80 @@ func (ctx *Context) Output(item interface{}, ruleName ...string) {
 		}
 		_item = item2
 	}
+	if len(rule.Schema) > 0 {
+		valid, err := rule.Schema.Coerce(_item).Split()
+		if err != nil {
+			ctx.reject(_ruleName, rule, _item, err)
+			return
+		}
+		_item = valid
+	}
+	ctx.appendItem(_ruleName, _item)
+}
+
+// appendItem collects an item of ruleName.
+func (ctx *Context) appendItem(ruleName string, item map[string]interface{}) {
 	ctx.Lock()
 	if ctx.spider.NotDefaultField {
-		ctx.items = append(ctx.items, data.GetDataCell(_ruleName, _item, "", "", ""))
+		ctx.items = append(ctx.items, data.GetDataCell(ruleName, item, "", "", ""))
 	} else {
-		ctx.items = append(ctx.items, data.GetDataCell(_ruleName, _item, ctx.GetURL(), ctx.GetReferer(), time.Now().Format("2006-01-02 15:04:05")))
+		ctx.items = append(ctx.items, data.GetDataCell(ruleName, item, ctx.GetURL(), ctx.GetReferer(), time.Now().Format("2006-01-02 15:04:05")))
 	}
 	ctx.Unlock()
 }
 
 // FileOutput collects a file result from the response body.
-// nameOrExt optionally specifies a file name or extension; empty keeps the original.
+// nameOrExt optionally specifies a file name or extension; empty keeps the original,
+// or index for URLs without a file name.
+// The body is streamed to disk by the file pipeline rather than buffered in memory,
+// unless it was already read via GetText/GetDom.
+// A screenshot or PDF taken by the Chrome downloader (see Request.Screenshot and
+// Request.PDF) is emitted alongside as <name>.png and <name>.pdf, also when the
+// body exceeds the size limit; CaptureOutput emits only those.
 // Errors are logged internally; no return value for JS VM compatibility.
 func (ctx *Context) FileOutput(nameOrExt ...string) {
 	if ctx.Response == nil || ctx.Response.Body == nil {
 		logs.Log().Warning(" *     [FileOutput]: Response or Body is nil for %s", ctx.GetURL())
 		return
 	}
-	body, err := io.ReadAll(ctx.Response.Body)
-	ctx.Response.Body.Close()
-	if err != nil {
-		logs.Log().Error(" *     [FileOutput]: %v", err)
+	baseName, ext := ctx.fileName(nameOrExt)
+	if max := config.Conf().Download.MaxFileSize; max > 0 && ctx.Response.ContentLength > max {
+		logs.Log().Error(" *     [FileOutput][%s]: %v (%d > %d bytes)", ctx.GetURL(), ErrBodyTooLarge, ctx.Response.ContentLength, max)
+	} else {
+		var cell data.FileCell
+		if ctx.text != nil {
+			cell = data.GetFileCell(ctx.GetRuleName(), baseName+ext, ctx.text)
+		} else {
+			// The file pipeline takes ownership of the body and closes it.
+			cell = data.GetFileStreamCell(ctx.GetRuleName(), baseName+ext, ctx.Response.Body)
+			ctx.Response.Body = http.NoBody
+		}
+		ctx.Lock()
+		ctx.files = append(ctx.files, cell)
+		ctx.Unlock()
+	}
+	ctx.outputCaptures(baseName)
+}
+
+// CaptureOutput collects only the screenshot and PDF taken by the Chrome
+// downloader as file results, named like those of FileOutput, e.g. to keep
+// how a page looked without its HTML.
+// Errors are logged internally; no return value for JS VM compatibility.
+func (ctx *Context) CaptureOutput(name ...string) {
+	if ctx.shot == nil && ctx.pdf == nil {
+		logs.Log().Warning(" *     [CaptureOutput]: no screenshot or PDF for %s", ctx.GetURL())
 		return
 	}
+	baseName, _ := ctx.fileName(name)
+	ctx.outputCaptures(baseName)
+}
 
+// fileName returns the base name and extension of the files output for
+// the response, from nameOrExt or the URL.
+func (ctx *Context) fileName(nameOrExt []string) (baseName, ext string) {
 	_, s := path.Split(ctx.GetURL())
 	n := strings.Split(s, "?")[0]
 
-	var baseName, ext string
-
 	if len(nameOrExt) > 0 {
 		p, n := path.Split(nameOrExt[0])
 		ext = path.Ext(n)
@@ -271,16 +407,29 @@ func (ctx *Context) FileOutput(nameOrExt ...string) {
 	if baseName == "" {
 		baseName = strings.TrimSuffix(n, path.Ext(n))
 	}
+	if baseName == "" {
+		baseName = "index"
+	}
 	if ext == "" {
 		ext = path.Ext(n)
 	}
 	if ext == "" {
 		ext = ".html"
 	}
+	return baseName, ext
+}
 
+// outputCaptures collects the screenshot and PDF of the Chrome downloader
+// as baseName.png and baseName.pdf.
+func (ctx *Context) outputCaptures(baseName string) {
 	ctx.Lock()
-	ctx.files = append(ctx.files, data.GetFileCell(ctx.GetRuleName(), baseName+ext, body))
-	ctx.Unlock()
+	defer ctx.Unlock()
+	if ctx.shot != nil {
+		ctx.files = append(ctx.files, data.GetFileCell(ctx.GetRuleName(), baseName+".png", ctx.shot))
+	}
+	if ctx.pdf != nil {
+		ctx.files = append(ctx.files, data.GetFileCell(ctx.GetRuleName(), baseName+".pdf", ctx.pdf))
+	}
 }
 
 // CreateItem builds a text result map keyed by field names using the ItemFields of ruleName.
@@ -405,12 +554,14 @@ func (ctx *Context) RunTimer(id string) bool {
 	return ctx.spider.RunTimer(id)
 }
 
-// ResetText replaces the downloaded text content and invalidates the DOM cache.
+// ResetText replaces the downloaded text content and invalidates the DOM and JSON caches.
 func (ctx *Context) ResetText(body string) *Context {
 	x := (*[2]uintptr)(unsafe.Pointer(&body))
 	h := [3]uintptr{x[0], x[1], x[1]}
 	ctx.text = *(*[]byte)(unsafe.Pointer(&h))
 	ctx.dom = nil
+	ctx.json = nil
+	ctx.jsonDoc = nil
 	return ctx
 }
 
@@ -447,6 +598,24 @@ func (ctx *Context) GetStatusCode() int {
 	return ctx.Response.StatusCode
 }
 
+// GetCaptures returns the XHR/fetch responses captured by the Chrome downloader
+// (see Request.CaptureXHR), in completion order.
+func (ctx *Context) GetCaptures() []surfer.CapturedResponse {
+	return ctx.captures
+}
+
+// GetScreenshot returns the full-page PNG screenshot taken by the Chrome
+// downloader (see Request.Screenshot), or nil.
+func (ctx *Context) GetScreenshot() []byte {
+	return ctx.shot
+}
+
+// GetPDF returns the page printed to PDF by the Chrome downloader
+// (see Request.PDF), or nil.
+func (ctx *Context) GetPDF() []byte {
+	return ctx.pdf
+}
+
 // GetRequest returns the original request.
 func (ctx *Context) GetRequest() *request.Request {
 	return ctx.Request
@@ -512,6 +681,12 @@ func (ctx *Context) GetKeyin() string {
 	return ctx.spider.GetKeyin()
 }
 
+// GetCookieJar returns the cookie jar of the spider, e.g. to import cookies
+// exported from a browser or to export the session after logging in.
+func (ctx *Context) GetCookieJar() *surfer.Jar {
+	return ctx.spider.GetCookieJar()
+}
+
 // GetLimit returns the maximum number of items to crawl.
 func (ctx *Context) GetLimit() int {
 	return int(ctx.spider.GetLimit())
@@ -675,12 +850,24 @@ func (ctx *Context) initDom() (*goquery.Document, error) {
 }
 
 // initText reads the response body and converts it to UTF-8 if needed.
+// Bodies larger than config.Conf().Download.MaxTextSize are rejected with ErrBodyTooLarge.
 func (ctx *Context) initText() error {
-	body, err := io.ReadAll(ctx.Response.Body)
-	ctx.Response.Body.Close()
+	defer ctx.Response.Body.Close()
+	var r io.Reader = ctx.Response.Body
+	max := config.Conf().Download.MaxTextSize
+	if max > 0 {
+		if ctx.Response.ContentLength > max {
+			return ErrBodyTooLarge
+		}
+		r = io.LimitReader(r, max+1)
+	}
+	body, err := io.ReadAll(r)
 	if err != nil {
 		return err
 	}
+	if max > 0 && int64(len(body)) > max {
+		return ErrBodyTooLarge
+	}
 
 	responseCT := ctx.Response.Header.Get("Content-Type")
 	requestCT := ctx.Request.Header.Get("Content-Type")
@@ -0,0 +1,107 @@
+package spider
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/app/downloader/surfer"
+	"github.com/andeya/pholcus/app/pipeline/collector/data"
+	"github.com/andeya/pholcus/config"
+)
+
+func TestJsToActions(t *testing.T) {
+	actions := jsToActions([]interface{}{
+		map[string]interface{}{"Type": "wait", "Selector": ".item", "Timeout": float64(time.Second)},
+		map[string]interface{}{"Type": "click", "Selector": ".more", "Times": int64(3), "Optional": true},
+		"ignored",
+	})
+	if len(actions) != 2 {
+		t.Fatalf("len = %d, want 2", len(actions))
+	}
+	want := surfer.ChromeAction{Type: "wait", Selector: ".item", Timeout: time.Second}
+	if actions[0] != want {
+		t.Errorf("actions[0] = %+v, want %+v", actions[0], want)
+	}
+	want = surfer.ChromeAction{Type: "click", Selector: ".more", Times: 3, Optional: true}
+	if actions[1] != want {
+		t.Errorf("actions[1] = %+v, want %+v", actions[1], want)
+	}
+	if jsToActions(nil) != nil {
+		t.Error("jsToActions(nil) should be nil")
+	}
+}
+
+func TestFileOutputChromeArtifacts(t *testing.T) {
+	ctx := GetContext(&Spider{}, &request.Request{URL: "http://example.com/list/page.html?p=2", Rule: "list"})
+	ctx.SetResponse(&http.Response{Body: io.NopCloser(strings.NewReader("<html></html>"))})
+	ctx.shot = []byte("png")
+	ctx.pdf = []byte("pdf")
+
+	ctx.FileOutput()
+	files := ctx.PullFiles()
+	if len(files) != 3 {
+		t.Fatalf("len(files) = %d, want 3", len(files))
+	}
+	for i, want := range []string{"page.html", "page.png", "page.pdf"} {
+		if name := files[i]["Name"]; name != want {
+			t.Errorf("files[%d] Name = %v, want %q", i, name, want)
+		}
+	}
+	if b, _ := files[1]["Bytes"].([]byte); string(b) != "png" {
+		t.Errorf("screenshot bytes = %q", b)
+	}
+	PutContext(ctx)
+}
+
+func chromeContext(url string, contentLength int64) *Context {
+	ctx := GetContext(&Spider{}, &request.Request{URL: url, Rule: "list"})
+	ctx.SetResponse(&http.Response{Body: io.NopCloser(strings.NewReader("<html></html>")), ContentLength: contentLength})
+	ctx.shot = []byte("png")
+	ctx.pdf = []byte("pdf")
+	return ctx
+}
+
+func fileNames(files []data.FileCell) []string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f["Name"].(string)
+	}
+	return names
+}
+
+func TestFileOutputChromeArtifacts_Index(t *testing.T) {
+	ctx := chromeContext("http://example.com/list/", -1)
+	defer PutContext(ctx)
+	ctx.FileOutput()
+	if got := strings.Join(fileNames(ctx.PullFiles()), ","); got != "index.html,index.png,index.pdf" {
+		t.Errorf("files = %s, want index.html,index.png,index.pdf", got)
+	}
+}
+
+func TestFileOutputChromeArtifacts_SizeLimit(t *testing.T) {
+	defer func(old int64) { config.Conf().Download.MaxFileSize = old }(config.Conf().Download.MaxFileSize)
+	config.Conf().Download.MaxFileSize = 10
+
+	ctx := chromeContext("http://example.com/list/page.html", 100)
+	defer PutContext(ctx)
+	ctx.FileOutput()
+	if got := strings.Join(fileNames(ctx.PullFiles()), ","); got != "page.png,page.pdf" {
+		t.Errorf("files = %s, want page.png,page.pdf", got)
+	}
+}
+
+func TestCaptureOutput(t *testing.T) {
+	ctx := chromeContext("http://example.com/list/page.html", -1)
+	defer PutContext(ctx)
+	ctx.CaptureOutput("shots/p1")
+	if got := strings.Join(fileNames(ctx.PullFiles()), ","); got != "shots/p1.png,shots/p1.pdf" {
+		t.Errorf("files = %s, want shots/p1.png,shots/p1.pdf", got)
+	}
+	if b, _ := io.ReadAll(ctx.Response.Body); string(b) != "<html></html>" {
+		t.Errorf("body = %q, want it unread", b)
+	}
+}
@@ -0,0 +1,69 @@
+package spider
+
+import (
+	"bytes"
+
+	"github.com/andeya/pholcus/app/aid/history"
+	"github.com/andeya/pholcus/app/downloader/surfer"
+	"github.com/andeya/pholcus/config"
+	"github.com/andeya/pholcus/logs"
+	"github.com/andeya/pholcus/runtime/cache"
+	"github.com/andeya/pholcus/runtime/status"
+)
+
+// GetCookieJar returns the cookie jar of this spider instance (name +
+// Keyin), creating it on first use. A new jar starts with the cookies saved
+// by the previous run when [cookie] persist is on, then those of CookieFile.
+func (sp *Spider) GetCookieJar() *surfer.Jar {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	if sp.jar != nil {
+		return sp.jar
+	}
+	sp.jar = surfer.NewJar()
+	if persistCookies() {
+		r := history.ReadCookies(cache.Task.OutType, sp.GetName(), sp.GetSubName())
+		if r.IsErr() {
+			logs.Log().Error(" *     Fail  [read cookies][%s]: %v\n", sp.GetName(), r.UnwrapErr())
+		} else if r := sp.jar.Load(bytes.NewReader(r.Unwrap())); r.IsErr() {
+			logs.Log().Error(" *     Fail  [read cookies][%s]: %v\n", sp.GetName(), r.UnwrapErr())
+		}
+	}
+	if sp.CookieFile != "" {
+		if r := sp.jar.ImportFile(sp.CookieFile); r.IsErr() {
+			logs.Log().Error(" *     Fail  [import cookies][%s]: %v\n", sp.GetName(), r.UnwrapErr())
+		}
+	}
+	return sp.jar
+}
+
+// GetInstanceName returns the name of this spider instance, i.e. the name
+// and, when set, the sub-name derived from Keyin.
+func (sp *Spider) GetInstanceName() string {
+	if sub := sp.GetSubName(); sub != "" {
+		return sp.GetName() + "__" + sub
+	}
+	return sp.GetName()
+}
+
+// SaveCookies stores the cookie jar for the next run when [cookie] persist is on.
+func (sp *Spider) SaveCookies() {
+	sp.lock.RLock()
+	jar := sp.jar
+	sp.lock.RUnlock()
+	if jar == nil || !persistCookies() {
+		return
+	}
+	var buf bytes.Buffer
+	if r := jar.Save(&buf); r.IsErr() {
+		logs.Log().Error(" *     Fail  [save cookies][%s]: %v\n", sp.GetName(), r.UnwrapErr())
+		return
+	}
+	if r := history.WriteCookies(cache.Task.OutType, sp.GetName(), sp.GetSubName(), buf.Bytes()); r.IsErr() {
+		logs.Log().Error(" *     Fail  [save cookies][%s]: %v\n", sp.GetName(), r.UnwrapErr())
+	}
+}
+
+func persistCookies() bool {
+	return config.Conf().Cookie.Persist && cache.Task.Mode != status.SERVER
+}
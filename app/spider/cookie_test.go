@@ -0,0 +1,36 @@
+package spider
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetCookieJarCookieFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cookies.txt")
+	os.WriteFile(file, []byte("# Netscape HTTP Cookie File\n.example.com\tTRUE\t/\tFALSE\t0\tsid\tabc\n"), 0644)
+
+	sp := &Spider{Name: "cookie", CookieFile: file, RuleTree: &RuleTree{}}
+	jar := sp.GetCookieJar()
+	if jar != sp.GetCookieJar() {
+		t.Error("GetCookieJar() returned a new jar on second call")
+	}
+	u, _ := url.Parse("http://www.example.com/")
+	if got := jar.Cookies(u); len(got) != 1 || got[0].Value != "abc" {
+		t.Errorf("Cookies() = %v, want sid=abc", got)
+	}
+	if sp.Copy().jar != nil {
+		t.Error("Copy() shares the cookie jar")
+	}
+}
+
+func TestGetInstanceName(t *testing.T) {
+	a := &Spider{Name: "cookie", RuleTree: &RuleTree{}}
+	a.SetKeyin("a")
+	b := &Spider{Name: "cookie", RuleTree: &RuleTree{}}
+	b.SetKeyin("b")
+	if a.GetInstanceName() == b.GetInstanceName() {
+		t.Errorf("instances with Keyin a and b share the name %q", a.GetInstanceName())
+	}
+}
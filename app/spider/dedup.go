@@ -0,0 +1,85 @@
+package spider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andeya/pholcus/common/util"
+	"github.com/andeya/pholcus/logs"
+)
+
+// DedupPolicy decides what the collector does with an item whose Dedup key
+// was seen before.
+type DedupPolicy string
+
+const (
+	DedupDrop    DedupPolicy = ""        // output the first item of each key, dropping the others
+	DedupLatest  DedupPolicy = "latest"  // hold the items to the end of the run, then output the last of each key
+	DedupChanged DedupPolicy = "changed" // output an item only if it differs from the last one of its key
+)
+
+// Dedup identifies the items of a Rule by key fields, for the collector to
+// output one per key, e.g. a product listed on many pages. Items missing all
+// key fields are output as they are.
+type Dedup struct {
+	Key    []string    `yaml:"key" json:"key"`       // fields identifying an item
+	Policy DedupPolicy `yaml:"policy" json:"policy"` // DedupDrop if empty
+	// Persist remembers the keys across runs in the history, like success
+	// records: DedupDrop then drops the items output by earlier runs, and
+	// DedupChanged those unchanged since. It is not supported by DedupLatest.
+	Persist bool `yaml:"persist" json:"persist"`
+}
+
+// Check reports an invalid definition.
+func (d *Dedup) Check() error {
+	if len(d.Key) == 0 {
+		return fmt.Errorf("no key fields")
+	}
+	switch d.Policy {
+	case DedupDrop, DedupChanged:
+	case DedupLatest:
+		if d.Persist {
+			return fmt.Errorf("policy %q cannot persist", d.Policy)
+		}
+	default:
+		return fmt.Errorf("unknown policy %q", d.Policy)
+	}
+	return nil
+}
+
+// ItemKey returns the key of item, false if it has none of the key fields.
+func (d *Dedup) ItemKey(item map[string]interface{}) (string, bool) {
+	values := make([]interface{}, len(d.Key))
+	var found bool
+	for i, k := range d.Key {
+		v := item[k]
+		if s, ok := v.(string); ok && strings.TrimSpace(s) == "" {
+			v = nil
+		}
+		values[i] = v
+		found = found || v != nil
+	}
+	if !found {
+		return "", false
+	}
+	return util.MakeMd5(values, 32), true
+}
+
+// ItemDigest returns a digest of the content of item, telling apart the
+// versions of an item with one key.
+func ItemDigest(item map[string]interface{}) string {
+	return util.MakeMd5(item, 32)
+}
+
+// checkDedup logs and disables the invalid Dedup of the rules.
+func (sp *Spider) checkDedup() {
+	for name, rule := range sp.RuleTree.Trunk {
+		if rule.Dedup == nil {
+			continue
+		}
+		if err := rule.Dedup.Check(); err != nil {
+			logs.Log().Error("spider %s: rule %s: dedup: %v", sp.GetName(), name, err)
+			rule.Dedup = nil
+		}
+	}
+}
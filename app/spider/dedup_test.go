@@ -0,0 +1,40 @@
+package spider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDedup_Check(t *testing.T) {
+	for _, tt := range []struct {
+		dedup Dedup
+		err   string
+	}{
+		{Dedup{Key: []string{"id"}}, ""},
+		{Dedup{Key: []string{"id"}, Policy: DedupChanged, Persist: true}, ""},
+		{Dedup{}, "no key fields"},
+		{Dedup{Key: []string{"id"}, Policy: "first"}, `unknown policy "first"`},
+		{Dedup{Key: []string{"id"}, Policy: DedupLatest, Persist: true}, "cannot persist"},
+	} {
+		err := tt.dedup.Check()
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%+v: err = %v, want %q", tt.dedup, err, tt.err)
+		}
+	}
+}
+
+func TestDedup_ItemKey(t *testing.T) {
+	d := &Dedup{Key: []string{"id", "shop"}}
+	k1, ok1 := d.ItemKey(map[string]interface{}{"id": "1", "v": 1})
+	k2, ok2 := d.ItemKey(map[string]interface{}{"id": "1", "shop": " ", "v": 2})
+	k3, _ := d.ItemKey(map[string]interface{}{"id": "1", "shop": "a"})
+	if !ok1 || !ok2 || k1 != k2 || k1 == k3 {
+		t.Errorf("keys = %q %v, %q %v, %q", k1, ok1, k2, ok2, k3)
+	}
+	if _, ok := d.ItemKey(map[string]interface{}{"v": 1}); ok {
+		t.Error("key of an item without key fields")
+	}
+	if ItemDigest(map[string]interface{}{"a": 1, "b": 2}) != ItemDigest(map[string]interface{}{"b": 2, "a": 1}) {
+		t.Error("digest depends on field order")
+	}
+}
@@ -0,0 +1,94 @@
+package spider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/andeya/pholcus/common/util"
+	"github.com/andeya/pholcus/logs"
+)
+
+// ErrNotJSON is reported when a response body is neither JSON nor JSONP.
+var ErrNotJSON = errors.New("response body is not JSON or JSONP")
+
+// GetJSON returns the response body decoded as JSON, with a JSONP callback
+// stripped automatically; objects are map[string]interface{}, arrays
+// []interface{} and numbers float64. It is parsed once and cached.
+// Errors are stored in ctx.err and can be retrieved via GetError().
+func (ctx *Context) GetJSON() interface{} {
+	if ctx.jsonDoc == nil {
+		b := ctx.getJSON("GetJSON")
+		if b == nil {
+			return nil
+		}
+		json.Unmarshal(b, &ctx.jsonDoc)
+	}
+	return ctx.jsonDoc
+}
+
+// JSON queries the JSON body with a gjson path like "data.items.#.name" or
+// "data.items.0.price", see https://github.com/tidwall/gjson; the result
+// converts to any type, e.g. JSON(path).Int().
+func (ctx *Context) JSON(path string) gjson.Result {
+	return gjson.GetBytes(ctx.getJSON("JSON"), path)
+}
+
+// JSONString returns the value at path as a string, "" if missing.
+func (ctx *Context) JSONString(path string) string {
+	return ctx.JSON(path).String()
+}
+
+// JSONInt returns the value at path as an integer, 0 if missing.
+func (ctx *Context) JSONInt(path string) int64 {
+	return ctx.JSON(path).Int()
+}
+
+// JSONFloat returns the value at path as a float, 0 if missing.
+func (ctx *Context) JSONFloat(path string) float64 {
+	return ctx.JSON(path).Float()
+}
+
+// JSONBool returns the value at path as a bool, false if missing.
+func (ctx *Context) JSONBool(path string) bool {
+	return ctx.JSON(path).Bool()
+}
+
+// JSONStrings returns the elements of the array at path as strings; a
+// single value gives one element, a missing one none.
+func (ctx *Context) JSONStrings(path string) []string {
+	results := ctx.JSON(path).Array()
+	strs := make([]string, len(results))
+	for i, r := range results {
+		strs[i] = r.String()
+	}
+	return strs
+}
+
+// JSONValue returns the value at path decoded like GetJSON, nil if missing.
+func (ctx *Context) JSONValue(path string) interface{} {
+	return ctx.JSON(path).Value()
+}
+
+// getJSON returns the JSON body, stripping a JSONP callback on first use.
+func (ctx *Context) getJSON(method string) []byte {
+	if ctx.json == nil {
+		text := ctx.GetText()
+		if ctx.text == nil { // GetText logged why
+			return nil
+		}
+		b := bytes.TrimSpace([]byte(text))
+		if !json.Valid(b) {
+			b = []byte(util.JSONPToJSON(string(b)))
+		}
+		if !json.Valid(b) {
+			ctx.err = ErrNotJSON
+			logs.Log().Error(" *     [%s][%s]: %v", method, ctx.GetURL(), ErrNotJSON)
+			return nil
+		}
+		ctx.json = b
+	}
+	return ctx.json
+}
@@ -0,0 +1,89 @@
+package spider
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/robertkrimen/otto"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+)
+
+func jsonContext(t *testing.T, body string) *Context {
+	ctx := GetContext(&Spider{}, &request.Request{URL: "http://example.com/api", Rule: "api"})
+	ctx.SetResponse(&http.Response{Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}})
+	t.Cleanup(func() { PutContext(ctx) })
+	return ctx
+}
+
+const jsonBody = `{"total": 2, "ok": true, "data": {"items": [
+	{"name": "Apple", "price": 1.5, "tags": ["fruit", "red"]},
+	{"name": "Pear", "price": 2, "note": "{a: 'b'}"}
+]}}`
+
+func TestContextJSON(t *testing.T) {
+	ctx := jsonContext(t, jsonBody)
+
+	doc, _ := ctx.GetJSON().(map[string]interface{})
+	if doc["total"] != float64(2) {
+		t.Fatalf("GetJSON = %v", ctx.GetJSON())
+	}
+	if ctx.JSONInt("total") != 2 || !ctx.JSONBool("ok") || ctx.JSONFloat("data.items.0.price") != 1.5 {
+		t.Error("typed getters mismatch")
+	}
+	if got := ctx.JSONString("data.items.1.note"); got != "{a: 'b'}" {
+		t.Errorf("string looking like JSONP = %q, want it unchanged", got)
+	}
+	if got := strings.Join(ctx.JSONStrings("data.items.#.name"), ","); got != "Apple,Pear" {
+		t.Errorf("JSONStrings = %q", got)
+	}
+	if got := ctx.JSON("data.items.#(price>1.8).name").String(); got != "Pear" {
+		t.Errorf("query = %q, want Pear", got)
+	}
+	if ctx.JSONValue("data.missing") != nil || ctx.JSONString("data.missing") != "" {
+		t.Error("missing path want zero values")
+	}
+	if tags, _ := ctx.JSONValue("data.items.0.tags").([]interface{}); len(tags) != 2 {
+		t.Errorf("JSONValue = %v", ctx.JSONValue("data.items.0.tags"))
+	}
+
+	ctx.ResetText(`{"total": 3}`)
+	if ctx.JSONInt("total") != 3 {
+		t.Error("ResetText did not reset the JSON cache")
+	}
+}
+
+func TestContextJSONP(t *testing.T) {
+	ctx := jsonContext(t, `jQuery1234_5678({status:0,list:[{id:1},{id:2}]});`)
+	if got := ctx.JSONInt("list.1.id"); got != 2 {
+		t.Errorf("JSONP list.1.id = %d, want 2", got)
+	}
+
+	ctx = jsonContext(t, `<html>not json</html>`)
+	if ctx.GetJSON() != nil || ctx.JSONString("a") != "" {
+		t.Error("HTML body want no JSON")
+	}
+	if !errors.Is(ctx.err, ErrNotJSON) {
+		t.Errorf("err = %v, want ErrNotJSON", ctx.err)
+	}
+}
+
+func TestContextJSONJS(t *testing.T) {
+	ctx := jsonContext(t, jsonBody)
+	vm := otto.New()
+	vm.Set("ctx", ctx)
+	v, err := vm.Run(`
+		var doc = ctx.GetJSON();
+		doc.data.items[1].name + "|" + ctx.JSONStrings("data.items.#.name").join(",") + "|" +
+			ctx.JSONFloat("data.items.0.price") + "|" + ctx.JSON("total").Int();
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.String(); got != "Pear|Apple,Pear|1.5|2" {
+		t.Errorf("JS result = %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package spider
+
+import (
+	"net/http"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+)
+
+type (
+	// DownloaderMiddleware hooks into the download of a request. Middlewares
+	// are registered globally with downloader.Use or per spider in
+	// Spider.Middlewares; global ones wrap the spider's.
+	//
+	// ProcessRequest hooks run in order before the request is sent; the first
+	// one returning a response or an error skips the download and the hooks
+	// after it. ProcessResponse and ProcessError then run in reverse order on
+	// the middlewares whose ProcessRequest ran.
+	DownloaderMiddleware interface {
+		// ProcessRequest may modify req, answer it with a response (e.g. from
+		// a cache) or fail it; (nil, nil) continues.
+		ProcessRequest(sp *Spider, req *request.Request) (*http.Response, error)
+		// ProcessResponse sees every response, whatever its status code. A
+		// non-nil response replaces resp, whose body is closed unless the new
+		// response reuses it; an error fails the download and closes the body.
+		ProcessResponse(sp *Spider, req *request.Request, resp *http.Response) (*http.Response, error)
+		// ProcessError sees download and hook errors. Returning a response
+		// recovers; otherwise the returned error, usually err, is passed on,
+		// err itself if nil.
+		ProcessError(sp *Spider, req *request.Request, err error) (*http.Response, error)
+	}
+
+	// MiddlewareFuncs adapts functions to a DownloaderMiddleware; nil hooks pass through.
+	MiddlewareFuncs struct {
+		Request  func(sp *Spider, req *request.Request) (*http.Response, error)
+		Response func(sp *Spider, req *request.Request, resp *http.Response) (*http.Response, error)
+		Error    func(sp *Spider, req *request.Request, err error) (*http.Response, error)
+	}
+)
+
+var _ DownloaderMiddleware = (*MiddlewareFuncs)(nil)
+
+// ProcessRequest implements DownloaderMiddleware.
+func (m *MiddlewareFuncs) ProcessRequest(sp *Spider, req *request.Request) (*http.Response, error) {
+	if m.Request == nil {
+		return nil, nil
+	}
+	return m.Request(sp, req)
+}
+
+// ProcessResponse implements DownloaderMiddleware.
+func (m *MiddlewareFuncs) ProcessResponse(sp *Spider, req *request.Request, resp *http.Response) (*http.Response, error) {
+	if m.Response == nil {
+		return nil, nil
+	}
+	return m.Response(sp, req, resp)
+}
+
+// ProcessError implements DownloaderMiddleware.
+func (m *MiddlewareFuncs) ProcessError(sp *Spider, req *request.Request, err error) (*http.Response, error) {
+	if m.Error == nil {
+		return nil, err
+	}
+	return m.Error(sp, req, err)
+}
@@ -54,8 +54,8 @@ type (
 	}
 )
 
-// RegisterDynamicSpiders loads and registers all dynamic (JS-based) spider
-// rules from config.Conf().SpiderDir.
+// RegisterDynamicSpiders loads and registers all dynamic (JS-based) and
+// declarative (see SpiderSpec) spider rules from config.Conf().SpiderDir.
 // Safe to call multiple times; only the first call performs registration.
 func RegisterDynamicSpiders() {
 	registerDynOnce.Do(doRegisterDynamicSpiders)
@@ -147,6 +147,9 @@ func doRegisterDynamicSpiders() {
 		}
 		sp.Register()
 	}
+	for _, s := range getSpiderSpecs() {
+		s.Spider().Register()
+	}
 }
 
 // wrapScriptCDATA wraps <Script> tag content in CDATA sections if not already wrapped,
@@ -0,0 +1,659 @@
+package spider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andeya/gust/result"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v2"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/common/goquery"
+	"github.com/andeya/pholcus/config"
+)
+
+type (
+	// SpiderSpec is the model of a declarative spider rule: seeds, field
+	// selectors and links are data, no script is needed. It is read from
+	// YAML or JSON files in config.Conf().SpiderDir.
+	SpiderSpec struct {
+		Name            string               `yaml:"name" json:"name"`
+		Description     string               `yaml:"description" json:"description"`
+		Pausetime       int64                `yaml:"pausetime" json:"pausetime"`
+		EnableLimit     bool                 `yaml:"limit" json:"limit"`
+		EnableCookie    bool                 `yaml:"cookie" json:"cookie"`
+		NotDefaultField bool                 `yaml:"notDefaultField" json:"notDefaultField"`
+		Downloader      string               `yaml:"downloader" json:"downloader"` // surf (default), phantom or chrome
+		Header          map[string]string    `yaml:"header" json:"header"`         // headers of every request
+		Seeds           []SeedSpec           `yaml:"seeds" json:"seeds"`
+		Rules           map[string]*RuleSpec `yaml:"rules" json:"rules"`
+	}
+
+	// SeedSpec is a start request. {keyin} in URL and PostData is replaced
+	// by the keyword entered in the UI, {page} by 1 to Pages.
+	SeedSpec struct {
+		URL      string `yaml:"url" json:"url"`
+		Rule     string `yaml:"rule" json:"rule"`
+		Method   string `yaml:"method" json:"method"`
+		PostData string `yaml:"postData" json:"postData"`
+		Pages    int    `yaml:"pages" json:"pages"`
+	}
+
+	// RuleSpec describes how a page is parsed. Without Items the page is one
+	// item, otherwise each match of Items is one, with the Fields selected
+	// relative to it. Items without any field value are dropped.
+	RuleSpec struct {
+		Items    *Selector   `yaml:"items" json:"items"`
+		Fields   []FieldSpec `yaml:"fields" json:"fields"`
+		Reject   string      `yaml:"reject" json:"reject"` // see Rule.Reject
+		Dedup    *Dedup      `yaml:"dedup" json:"dedup"`   // see Rule.Dedup
+		Follow   []LinkSpec  `yaml:"follow" json:"follow"`
+		Paginate *PageSpec   `yaml:"paginate" json:"paginate"`
+	}
+
+	// FieldSpec is an output field, typed and validated by Field.
+	FieldSpec struct {
+		Field    `yaml:",inline"`
+		Selector `yaml:",inline"`
+	}
+
+	// LinkSpec follows all the URLs it selects, resolved against the page
+	// URL, with Rule.
+	LinkSpec struct {
+		Rule     string `yaml:"rule" json:"rule"`
+		Selector `yaml:",inline"`
+	}
+
+	// PageSpec follows the next page link it selects with the same rule, up
+	// to Max pages in all (0 for no limit).
+	PageSpec struct {
+		Max      int `yaml:"max" json:"max"`
+		Selector `yaml:",inline"`
+	}
+
+	// Selector selects strings from a page by one of CSS, XPath or JSON, a
+	// gjson path. Regex is applied to each selected string, or to the whole
+	// page alone, and keeps its first group, or the match if it has none.
+	// The values are then passed through Filters:
+	//
+	//	trim, collapse, lower, upper  whitespace and case
+	//	number                        the first number, without thousands separators
+	//	absurl                        resolved against the page URL
+	//	prefix:s, suffix:s, default:s prepended, appended, used if empty
+	//	regex:re                      like Regex
+	//	replace:re=>s                 regexp.ReplaceAllString
+	Selector struct {
+		CSS     string   `yaml:"css" json:"css"`
+		XPath   string   `yaml:"xpath" json:"xpath"`
+		JSON    string   `yaml:"json" json:"json"`
+		Regex   string   `yaml:"regex" json:"regex"`
+		Attr    string   `yaml:"attr" json:"attr"` // attribute instead of the text, "html" for the inner HTML
+		All     bool     `yaml:"all" json:"all"`   // all values as []string instead of the first
+		Filters []string `yaml:"filters" json:"filters"`
+
+		re      *regexp.Regexp
+		filters []specFilter
+	}
+
+	specFilter func(ctx *Context, s string) string
+
+	// specScope is what selectors are relative to: an element, a JSON value
+	// or, both nil, the whole page.
+	specScope struct {
+		node *html.Node
+		json *gjson.Result
+	}
+)
+
+var numberRe = regexp.MustCompile(`-?\d[\d,]*(\.\d+)?`)
+
+// LoadSpiderSpec reads a declarative rule file, YAML unless it ends in
+// config.SpiderExtJSON.
+func LoadSpiderSpec(filename string) result.Result[*SpiderSpec] {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return result.TryErr[*SpiderSpec](err)
+	}
+	var s SpiderSpec
+	if strings.HasSuffix(filename, config.SpiderExtJSON) {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&s)
+	} else {
+		err = yaml.UnmarshalStrict(b, &s)
+	}
+	if err != nil {
+		return result.TryErr[*SpiderSpec](err)
+	}
+	if err = s.compile(); err != nil {
+		return result.TryErr[*SpiderSpec](err)
+	}
+	return result.Ok(&s)
+}
+
+// Spider builds the spider of the rule.
+func (s *SpiderSpec) Spider() *Spider {
+	sp := &Spider{
+		Name:            s.Name,
+		Description:     s.Description,
+		Pausetime:       s.Pausetime,
+		EnableCookie:    s.EnableCookie,
+		NotDefaultField: s.NotDefaultField,
+		RuleTree:        &RuleTree{Trunk: map[string]*Rule{}},
+	}
+	if s.EnableLimit {
+		sp.Limit = LIMIT
+	}
+	for _, seed := range s.Seeds {
+		if strings.Contains(seed.URL, "{keyin}") || strings.Contains(seed.PostData, "{keyin}") {
+			sp.Keyin = KEYIN
+		}
+	}
+	sp.RuleTree.Root = func(ctx *Context) {
+		for _, req := range s.seeds(ctx.GetKeyin()) {
+			ctx.AddQueue(req)
+		}
+	}
+	for name, r := range s.Rules {
+		rule := &Rule{Reject: r.Reject, Dedup: r.Dedup, ParseFunc: s.parseFunc(r)}
+		for _, f := range r.Fields {
+			rule.ItemFields = append(rule.ItemFields, f.Name)
+			rule.Schema = append(rule.Schema, f.Field)
+		}
+		sp.RuleTree.Trunk[name] = rule
+	}
+	return sp
+}
+
+// seeds returns the start requests.
+func (s *SpiderSpec) seeds(keyin string) []*request.Request {
+	var reqs []*request.Request
+	for _, seed := range s.Seeds {
+		pages := seed.Pages
+		if pages <= 0 {
+			pages = 1
+		}
+		for page := 1; page <= pages; page++ {
+			r := strings.NewReplacer("{keyin}", url.QueryEscape(keyin), "{page}", strconv.Itoa(page))
+			req := s.request(r.Replace(seed.URL), seed.Rule)
+			req.Method = seed.Method
+			req.PostData = r.Replace(seed.PostData)
+			if seed.Pages > 0 {
+				req.SetTemp("page", page)
+			}
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// request returns a request with the spider wide settings.
+func (s *SpiderSpec) request(u, rule string) *request.Request {
+	req := &request.Request{URL: u, Rule: rule, Temp: request.Temp{}, DownloaderID: specDownloaders[s.Downloader]}
+	if len(s.Header) > 0 {
+		req.Header = http.Header{}
+		for k, v := range s.Header {
+			req.Header.Set(k, v)
+		}
+	}
+	return req
+}
+
+// parseFunc returns the ParseFunc of r.
+func (s *SpiderSpec) parseFunc(r *RuleSpec) func(*Context) {
+	return func(ctx *Context) {
+		if len(r.Fields) > 0 {
+			for _, scope := range r.scopes(ctx) {
+				if item := r.item(ctx, scope); item != nil {
+					ctx.Output(item)
+				}
+			}
+		}
+		for _, req := range s.links(ctx, r) {
+			ctx.AddQueue(req)
+		}
+	}
+}
+
+// links returns the requests for the links and next page of a page of r.
+func (s *SpiderSpec) links(ctx *Context, r *RuleSpec) []*request.Request {
+	var reqs []*request.Request
+	add := func(sel *Selector, rule string) []*request.Request {
+		var added []*request.Request
+		for _, v := range sel.values(ctx, specScope{}) {
+			if u := resolveURL(ctx.GetURL(), v); v != "" && u != "" {
+				req := s.request(u, rule)
+				reqs = append(reqs, req)
+				added = append(added, req)
+			}
+		}
+		return added
+	}
+	for i := range r.Follow {
+		add(&r.Follow[i].Selector, r.Follow[i].Rule)
+	}
+	if p := r.Paginate; p != nil {
+		page, _ := jsToInt64(ctx.GetTemp("page", 1))
+		if p.Max <= 0 || page < int64(p.Max) {
+			for _, req := range add(&p.Selector, ctx.GetRuleName()) {
+				req.SetTemp("page", page+1)
+			}
+		}
+	}
+	return reqs
+}
+
+// scopes returns the scopes of the items on a page.
+func (r *RuleSpec) scopes(ctx *Context) []specScope {
+	sel := r.Items
+	switch {
+	case sel == nil:
+		return []specScope{{}}
+	case sel.JSON != "":
+		results := ctx.JSON(sel.JSON).Array()
+		scopes := make([]specScope, len(results))
+		for i := range results {
+			scopes[i].json = &results[i]
+		}
+		return scopes
+	}
+	var nodes []*html.Node
+	if sel.CSS != "" {
+		if dom := ctx.GetDom(); dom != nil {
+			nodes = dom.Find(sel.CSS).Nodes
+		}
+	} else {
+		nodes = ctx.XPath(sel.XPath)
+	}
+	scopes := make([]specScope, len(nodes))
+	for i, n := range nodes {
+		scopes[i].node = n
+	}
+	return scopes
+}
+
+// item returns the fields of an item, nil if all are empty.
+func (r *RuleSpec) item(ctx *Context, scope specScope) map[string]interface{} {
+	item := make(map[string]interface{}, len(r.Fields))
+	var found bool
+	for i := range r.Fields {
+		f := &r.Fields[i]
+		values := f.values(ctx, scope)
+		found = found || len(values) > 0 && values[0] != ""
+		if f.All {
+			if values == nil {
+				values = []string{}
+			}
+			item[f.Name] = values
+		} else {
+			item[f.Name] = values[0]
+		}
+	}
+	if !found {
+		return nil
+	}
+	return item
+}
+
+// values returns the selected values, only the first unless All is set.
+func (sel *Selector) values(ctx *Context, scope specScope) []string {
+	var values []string
+	switch {
+	case sel.CSS != "":
+		values = sel.cssValues(ctx, scope)
+	case sel.XPath != "":
+		values = sel.xpathValues(ctx, scope)
+	case sel.JSON != "":
+		var res gjson.Result
+		if scope.json != nil {
+			res = scope.json.Get(sel.JSON)
+		} else {
+			res = ctx.JSON(sel.JSON)
+		}
+		if res.IsArray() && (sel.All || strings.Contains(sel.JSON, "#")) {
+			for _, r := range res.Array() {
+				values = append(values, r.String())
+			}
+		} else if res.Exists() {
+			values = []string{res.String()}
+		}
+	default:
+		values = []string{scope.text(ctx)}
+	}
+	if sel.re != nil {
+		var matched []string
+		for _, v := range values {
+			for _, m := range sel.re.FindAllStringSubmatch(v, -1) {
+				matched = append(matched, submatch(m))
+				if !sel.All {
+					break
+				}
+			}
+		}
+		values = matched
+	}
+	if !sel.All {
+		// A missing value is "" for filters like default.
+		var first string
+		if len(values) > 0 {
+			first = values[0]
+		}
+		values = []string{first}
+	}
+	for i, v := range values {
+		v = strings.TrimSpace(v)
+		for _, f := range sel.filters {
+			v = f(ctx, v)
+		}
+		values[i] = v
+	}
+	return values
+}
+
+func (sel *Selector) cssValues(ctx *Context, scope specScope) []string {
+	var s *goquery.Selection
+	if scope.node != nil {
+		s = goquery.NewDocumentFromNode(scope.node).Find(sel.CSS)
+	} else if dom := ctx.GetDom(); dom != nil {
+		s = dom.Find(sel.CSS)
+	} else {
+		return nil
+	}
+	if !sel.All {
+		s = s.First()
+	}
+	var values []string
+	s.Each(func(_ int, s *goquery.Selection) {
+		switch sel.Attr {
+		case "":
+			values = append(values, s.Text())
+		case "html":
+			h, _ := s.Html()
+			values = append(values, h)
+		default:
+			if v := s.Attr(sel.Attr); v.IsSome() {
+				values = append(values, v.Unwrap())
+			}
+		}
+	})
+	return values
+}
+
+func (sel *Selector) xpathValues(ctx *Context, scope specScope) []string {
+	var from []*html.Node
+	if scope.node != nil {
+		from = append(from, scope.node)
+	}
+	if sel.Attr == "" && !sel.All {
+		if v := ctx.XPathString(sel.XPath, from...); v != "" {
+			return []string{v}
+		}
+		return nil
+	}
+	var values []string
+	for _, n := range ctx.XPath(sel.XPath, from...) {
+		switch sel.Attr {
+		case "":
+			values = append(values, htmlquery.InnerText(n))
+		case "html":
+			values = append(values, htmlquery.OutputHTML(n, false))
+		default:
+			if htmlquery.ExistsAttr(n, sel.Attr) {
+				values = append(values, htmlquery.SelectAttr(n, sel.Attr))
+			}
+		}
+	}
+	return values
+}
+
+// text returns the source of the scope for a Regex alone.
+func (scope specScope) text(ctx *Context) string {
+	switch {
+	case scope.node != nil:
+		return htmlquery.OutputHTML(scope.node, true)
+	case scope.json != nil:
+		return scope.json.Raw
+	}
+	return ctx.GetText()
+}
+
+// resolveURL resolves ref against base, "" unless it is an HTTP(S) URL.
+func resolveURL(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	u, err := b.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+var specDownloaders = map[string]int{
+	"":        request.SurfID,
+	"surf":    request.SurfID,
+	"phantom": request.PhantomID,
+	"chrome":  request.ChromeID,
+}
+
+// compile checks the rule and compiles its regexps and filters.
+func (s *SpiderSpec) compile() error {
+	if s.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if _, ok := specDownloaders[s.Downloader]; !ok {
+		return fmt.Errorf("unknown downloader %q", s.Downloader)
+	}
+	if len(s.Seeds) == 0 {
+		return fmt.Errorf("no seeds")
+	}
+	hasRule := func(name string) error {
+		if s.Rules[name] == nil {
+			return fmt.Errorf("undefined rule %q", name)
+		}
+		return nil
+	}
+	for _, seed := range s.Seeds {
+		if seed.URL == "" {
+			return fmt.Errorf("seed without url")
+		}
+		if err := hasRule(seed.Rule); err != nil {
+			return fmt.Errorf("seed %s: %v", seed.URL, err)
+		}
+	}
+	for name, r := range s.Rules {
+		if r == nil {
+			return fmt.Errorf("rule %s: empty", name)
+		}
+		if r.Items != nil {
+			if r.Items.Regex != "" || r.Items.CSS == "" && r.Items.XPath == "" && r.Items.JSON == "" {
+				return fmt.Errorf("rule %s: items must be selected by css, xpath or json", name)
+			}
+			if err := r.Items.compile(); err != nil {
+				return fmt.Errorf("rule %s: items: %v", name, err)
+			}
+		}
+		for i := range r.Fields {
+			f := &r.Fields[i]
+			if f.Name == "" {
+				return fmt.Errorf("rule %s: field without name", name)
+			}
+			if err := f.compile(); err != nil {
+				return fmt.Errorf("rule %s: field %s: %v", name, f.Name, err)
+			}
+			if err := f.check(); err != nil {
+				return fmt.Errorf("rule %s: field %s: %v", name, f.Name, err)
+			}
+		}
+		if r.Reject != "" {
+			if err := hasRule(r.Reject); err != nil {
+				return fmt.Errorf("rule %s: reject: %v", name, err)
+			}
+		}
+		if r.Dedup != nil {
+			if err := r.Dedup.Check(); err != nil {
+				return fmt.Errorf("rule %s: dedup: %v", name, err)
+			}
+		}
+		for i := range r.Follow {
+			l := &r.Follow[i]
+			l.All = true
+			if err := hasRule(l.Rule); err != nil {
+				return fmt.Errorf("rule %s: follow: %v", name, err)
+			}
+			if err := l.compile(); err != nil {
+				return fmt.Errorf("rule %s: follow: %v", name, err)
+			}
+		}
+		if r.Paginate != nil {
+			if err := r.Paginate.compile(); err != nil {
+				return fmt.Errorf("rule %s: paginate: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (sel *Selector) compile() error {
+	var n int
+	for _, s := range []string{sel.CSS, sel.XPath, sel.JSON} {
+		if s != "" {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("more than one of css, xpath and json")
+	}
+	if n == 0 && sel.Regex == "" {
+		return fmt.Errorf("no css, xpath, json or regex")
+	}
+	if sel.CSS != "" {
+		if _, err := cascadia.Compile(sel.CSS); err != nil {
+			return fmt.Errorf("css %q: %v", sel.CSS, err)
+		}
+	}
+	if sel.XPath != "" {
+		if _, err := xpath.Compile(sel.XPath); err != nil {
+			return fmt.Errorf("xpath %q: %v", sel.XPath, err)
+		}
+	}
+	if sel.Regex != "" {
+		re, err := regexp.Compile(sel.Regex)
+		if err != nil {
+			return err
+		}
+		sel.re = re
+	}
+	sel.filters = sel.filters[:0]
+	for _, name := range sel.Filters {
+		f, err := newSpecFilter(name)
+		if err != nil {
+			return err
+		}
+		sel.filters = append(sel.filters, f)
+	}
+	return nil
+}
+
+// newSpecFilter returns the filter named like "number" or "prefix:https:".
+func newSpecFilter(filter string) (specFilter, error) {
+	name, arg, hasArg := strings.Cut(filter, ":")
+	if needArg := name == "prefix" || name == "suffix" || name == "default" || name == "regex" || name == "replace"; needArg != hasArg {
+		return nil, fmt.Errorf("filter %q: bad argument", filter)
+	}
+	switch name {
+	case "trim":
+		return func(_ *Context, s string) string { return strings.TrimSpace(s) }, nil
+	case "collapse":
+		return func(_ *Context, s string) string { return strings.Join(strings.Fields(s), " ") }, nil
+	case "lower":
+		return func(_ *Context, s string) string { return strings.ToLower(s) }, nil
+	case "upper":
+		return func(_ *Context, s string) string { return strings.ToUpper(s) }, nil
+	case "number":
+		return func(_ *Context, s string) string {
+			return strings.ReplaceAll(numberRe.FindString(s), ",", "")
+		}, nil
+	case "absurl":
+		return func(ctx *Context, s string) string {
+			if s == "" {
+				return ""
+			}
+			return resolveURL(ctx.GetURL(), s)
+		}, nil
+	case "prefix":
+		return func(_ *Context, s string) string { return arg + s }, nil
+	case "suffix":
+		return func(_ *Context, s string) string { return s + arg }, nil
+	case "default":
+		return func(_ *Context, s string) string {
+			if s == "" {
+				return arg
+			}
+			return s
+		}, nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %v", filter, err)
+		}
+		return func(_ *Context, s string) string {
+			if m := re.FindStringSubmatch(s); m != nil {
+				return submatch(m)
+			}
+			return ""
+		}, nil
+	case "replace":
+		pattern, repl, ok := strings.Cut(arg, "=>")
+		re, err := regexp.Compile(pattern)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("filter %q: want replace:regexp=>replacement", filter)
+		}
+		return func(_ *Context, s string) string { return re.ReplaceAllString(s, repl) }, nil
+	}
+	return nil, fmt.Errorf("unknown filter %q", filter)
+}
+
+// submatch returns the first group of a regexp match m, or the match if
+// the regexp has no group.
+func submatch(m []string) string {
+	if len(m) > 1 {
+		return m[1]
+	}
+	return m[0]
+}
+
+// getSpiderSpecs loads all declarative rule files from the configured directory.
+func getSpiderSpecs() (specs []*SpiderSpec) {
+	var files []string
+	for _, ext := range []string{config.SpiderExtYAML, config.SpiderExtYML, config.SpiderExtJSON} {
+		matches, _ := filepath.Glob(path.Join(config.Conf().SpiderDir, "*"+ext))
+		files = append(files, matches...)
+	}
+	for _, filename := range files {
+		s, err := LoadSpiderSpec(filename).Split()
+		if err != nil {
+			log.Printf("[E] declarative rule [%s]: %v\n", filename, err)
+			continue
+		}
+		specs = append(specs, s)
+	}
+	return
+}
@@ -0,0 +1,193 @@
+package spider
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+)
+
+const specYAML = `
+name: books
+description: book list
+header: {Accept-Language: zh-CN}
+seeds:
+  - url: "http://example.com/search?q={keyin}&p={page}"
+    rule: list
+    pages: 2
+rules:
+  list:
+    items: {css: li.book}
+    fields:
+      - {name: title, css: a, filters: [collapse]}
+      - {name: price, xpath: ".//span[@class='price']", filters: [number], type: float, min: 0}
+      - {name: link, css: a, attr: href, filters: [absurl]}
+      - {name: id, regex: 'data-id="(\d+)"'}
+      - {name: tags, css: i, all: true, filters: [upper]}
+    follow:
+      - {css: li.book a, attr: href, rule: detail}
+    paginate: {css: a.next, attr: href, max: 3}
+    dedup: {key: [id], policy: changed, persist: true}
+  detail:
+    fields:
+      - {name: isbn, xpath: "//meta[@name='isbn']", attr: content}
+`
+
+const specPage = `<html><body><ul>
+<li class="book" data-id="7"><a href="/b/7">Go  in
+ Action</a><span class="price">￥1,299.50</span><i>go</i><i>web</i></li>
+<li class="book" data-id="8"><a href="b/8#top">Pholcus</a></li>
+<li class="book"></li>
+</ul><a class="next" href="?p=9">next</a><a href="javascript:void(0)" class="next">x</a></body></html>`
+
+func writeSpec(t *testing.T, name, content string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func specContext(t *testing.T, sp *Spider, req *request.Request, body string) *Context {
+	ctx := GetContext(sp, req)
+	ctx.SetResponse(&http.Response{Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}})
+	t.Cleanup(func() { PutContext(ctx) })
+	return ctx
+}
+
+func TestLoadSpiderSpec(t *testing.T) {
+	s, err := LoadSpiderSpec(writeSpec(t, "books.pholcus.yaml", specYAML)).Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := s.Spider()
+	if sp.Name != "books" || sp.Keyin != KEYIN || len(sp.RuleTree.Trunk) != 2 {
+		t.Fatalf("spider = %+v", sp)
+	}
+	if want := []string{"title", "price", "link", "id", "tags"}; !reflect.DeepEqual(sp.GetRule("list").ItemFields, want) {
+		t.Errorf("ItemFields = %v, want %v", sp.GetRule("list").ItemFields, want)
+	}
+	if f := sp.GetRule("list").Schema.Field("price"); f == nil || f.Type != TypeFloat || *f.Min != 0 {
+		t.Errorf("price field = %+v", f)
+	}
+	if d := sp.GetRule("list").Dedup; d == nil || d.Policy != DedupChanged || !d.Persist || d.Key[0] != "id" {
+		t.Errorf("dedup = %+v", d)
+	}
+	seeds := s.seeds("go lang")
+	if len(seeds) != 2 || seeds[1].URL != "http://example.com/search?q=go+lang&p=2" || seeds[1].Rule != "list" {
+		t.Fatalf("seeds = %+v", seeds)
+	}
+	if seeds[0].Header.Get("Accept-Language") != "zh-CN" {
+		t.Error("header not set on seeds")
+	}
+}
+
+func TestSpiderSpecParse(t *testing.T) {
+	s := LoadSpiderSpec(writeSpec(t, "books.pholcus.yml", specYAML)).Unwrap()
+	sp := s.Spider()
+	req := &request.Request{URL: "http://example.com/search?q=go", Rule: "list", Temp: request.Temp{}}
+	req.SetTemp("page", 2)
+	ctx := specContext(t, sp, req, specPage)
+
+	r := s.Rules["list"]
+	var items []map[string]interface{}
+	for _, scope := range r.scopes(ctx) {
+		if item := r.item(ctx, scope); item != nil {
+			items = append(items, item)
+		}
+	}
+	want := []map[string]interface{}{
+		{"title": "Go in Action", "price": "1299.50", "link": "http://example.com/b/7", "id": "7", "tags": []string{"GO", "WEB"}},
+		{"title": "Pholcus", "price": "", "link": "http://example.com/b/8", "id": "8", "tags": []string{}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("items = %v\nwant %v", items, want)
+	}
+
+	var links []string
+	for _, req := range s.links(ctx, r) {
+		links = append(links, req.Rule+" "+req.URL)
+	}
+	wantLinks := []string{"detail http://example.com/b/7", "detail http://example.com/b/8", "list http://example.com/search?p=9"}
+	if !reflect.DeepEqual(links, wantLinks) {
+		t.Errorf("links = %v, want %v", links, wantLinks)
+	}
+
+	req.SetTemp("page", 3)
+	for _, req := range s.links(ctx, r) {
+		if req.Rule == "list" {
+			t.Errorf("followed page %s beyond max", req.URL)
+		}
+	}
+}
+
+func TestSpiderSpecJSON(t *testing.T) {
+	s := LoadSpiderSpec(writeSpec(t, "api.pholcus.json", `{
+		"name": "api",
+		"downloader": "chrome",
+		"seeds": [{"url": "http://example.com/api", "rule": "api"}],
+		"rules": {"api": {
+			"items": {"json": "data.list"},
+			"fields": [
+				{"name": "name", "json": "name", "filters": ["default:none"]},
+				{"name": "code", "json": "code", "regex": "^(\\w+)-(\\d+)", "filters": ["lower"]},
+				{"name": "num", "json": "code", "filters": ["regex:(\\w)(\\w)-(\\d+)"]}
+			],
+			"follow": [{"json": "data.list.#.url", "rule": "api"}]
+		}}
+	}`)).Unwrap()
+	sp := s.Spider()
+	if sp.Keyin != "" || s.seeds("")[0].DownloaderID != request.ChromeID {
+		t.Fatalf("spider = %+v", sp)
+	}
+	ctx := specContext(t, sp, &request.Request{URL: "http://example.com/api", Rule: "api"},
+		`cb({"data": {"list": [{"name": "a", "code": "AB-1", "url": "/x"}, {"code": "CD-2"}]}})`)
+
+	r := s.Rules["api"]
+	var names, codes, nums []string
+	for _, scope := range r.scopes(ctx) {
+		item := r.item(ctx, scope)
+		names = append(names, item["name"].(string))
+		codes = append(codes, item["code"].(string))
+		nums = append(nums, item["num"].(string))
+	}
+	// Regexps keep their first group.
+	if strings.Join(names, ",") != "a,none" || strings.Join(codes, ",") != "ab,cd" || strings.Join(nums, ",") != "A,C" {
+		t.Errorf("names = %v, codes = %v, nums = %v", names, codes, nums)
+	}
+	if links := s.links(ctx, r); len(links) != 1 || links[0].URL != "http://example.com/x" {
+		t.Errorf("links = %v", links)
+	}
+}
+
+func TestLoadSpiderSpec_Invalid(t *testing.T) {
+	for _, tt := range []struct{ spec, err string }{
+		{"seeds: [{url: 'http://a', rule: r}]\nrules: {r: {}}", "missing name"},
+		{"name: x\nrules: {r: {}}", "no seeds"},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]", `undefined rule "r"`},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {fields: [{name: f}]}}", "no css, xpath, json or regex"},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {fields: [{name: f, css: a, xpath: //a}]}}", "more than one"},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {fields: [{name: f, xpath: '//a['}]}}", "xpath"},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {fields: [{name: f, css: a, filters: [shout]}]}}", `unknown filter "shout"`},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {fields: [{name: f, css: a, filters: [prefix]}]}}", "bad argument"},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {follow: [{css: a, rule: y}]}}", `undefined rule "y"`},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {fields: [{name: f, css: a, type: decimal}]}}", `unknown type "decimal"`},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {reject: y}}", `reject: undefined rule "y"`},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {dedup: {policy: latest}}}", "dedup: no key fields"},
+		{"name: x\nseeds: [{url: 'http://a', rule: r}]\nrules: {r: {}}\nlimits: true", "field limits not found"},
+	} {
+		_, err := LoadSpiderSpec(writeSpec(t, "x.pholcus.yaml", tt.spec)).Split()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: err = %v, want %q", tt.spec, err, tt.err)
+		}
+	}
+	spec := `{"name": "x", "seeds": [{"url": "http://a", "rule": "r"}], "rules": {"r": {"feilds": []}}}`
+	if _, err := LoadSpiderSpec(writeSpec(t, "x.pholcus.json", spec)).Split(); err == nil || !strings.Contains(err.Error(), `unknown field "feilds"`) {
+		t.Errorf("JSON err = %v, want unknown field", err)
+	}
+}
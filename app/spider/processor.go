@@ -0,0 +1,66 @@
+package spider
+
+import (
+	"errors"
+
+	"github.com/andeya/pholcus/app/pipeline/collector/data"
+)
+
+// ErrDropItem is returned by an ItemProcessor to drop an item quietly.
+var ErrDropItem = errors.New("item dropped")
+
+type (
+	// ItemProcessor is a stage of the item pipeline the collector runs on
+	// every item before output: first the Spider.Processors, then those of
+	// the item's Rule.Processors. Built-in stages are in app/spider/common.
+	ItemProcessor interface {
+		// Name identifies the stage in the pipeline counters.
+		Name() string
+		// Process cleans, enriches or checks the fields in cell["Data"] in
+		// place. ErrDropItem drops the item; other errors drop and log it.
+		Process(cell data.DataCell) error
+	}
+
+	// FieldMapper is implemented by processors that rename or remove fields,
+	// so that outputs create the columns of the processed items from the
+	// Rule.ItemFields.
+	FieldMapper interface {
+		MapFields(fields []string) []string
+	}
+
+	processFunc struct {
+		name string
+		fn   func(cell data.DataCell) error
+	}
+)
+
+// ProcessFunc returns an ItemProcessor calling fn.
+func ProcessFunc(name string, fn func(cell data.DataCell) error) ItemProcessor {
+	return &processFunc{name: name, fn: fn}
+}
+
+// Name implements ItemProcessor.
+func (p *processFunc) Name() string { return p.name }
+
+// Process implements ItemProcessor.
+func (p *processFunc) Process(cell data.DataCell) error { return p.fn(cell) }
+
+// GetProcessors returns the item pipeline of the rule ruleName.
+func (sp *Spider) GetProcessors(ruleName string) []ItemProcessor {
+	rule := sp.GetRule(ruleName)
+	if rule == nil || len(rule.Processors) == 0 {
+		return sp.Processors
+	}
+	return append(sp.Processors[:len(sp.Processors):len(sp.Processors)], rule.Processors...)
+}
+
+// MapItemFields returns fields, e.g. the Rule.ItemFields, as named after
+// the item pipeline of ruleName.
+func (sp *Spider) MapItemFields(ruleName string, fields []string) []string {
+	for _, p := range sp.GetProcessors(ruleName) {
+		if m, ok := p.(FieldMapper); ok {
+			fields = m.MapFields(fields)
+		}
+	}
+	return fields
+}
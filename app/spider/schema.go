@@ -0,0 +1,496 @@
+package spider
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andeya/gust/result"
+	"github.com/andeya/gust/syncutil"
+
+	"github.com/andeya/pholcus/common/util"
+	"github.com/andeya/pholcus/logs"
+)
+
+// FieldType is the type an item field is coerced to by Context.Output.
+type FieldType string
+
+const (
+	TypeAny    FieldType = ""       // kept as output
+	TypeString FieldType = "string" // string
+	TypeInt    FieldType = "int"    // int64
+	TypeFloat  FieldType = "float"  // float64
+	TypeBool   FieldType = "bool"   // bool
+	TypeTime   FieldType = "time"   // time.Time
+	TypeList   FieldType = "list"   // []interface{}
+	TypeObject FieldType = "object" // map[string]interface{}
+)
+
+// RejectField is the field with the validation errors of the items output to
+// a Rule.Reject rule.
+const RejectField = "Error"
+
+// TimeLayouts are tried in turn to parse time fields without a Layout;
+// numbers are taken as Unix seconds, or milliseconds if above 1e12.
+var TimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+type (
+	// Schema defines the typed fields of the items of a Rule. Fields missing
+	// from the schema are output as they are.
+	Schema []Field
+
+	// Field is a typed item field. Text is converted to the Type, e.g.
+	// "1,299" to int64 1299; an empty text is a missing value for all but
+	// string fields.
+	Field struct {
+		Name     string    `yaml:"name" json:"name"`
+		Type     FieldType `yaml:"type" json:"type"`
+		Required bool      `yaml:"required" json:"required"` // must be present
+		NotEmpty bool      `yaml:"notEmpty" json:"notEmpty"` // must not be blank text, an empty list or object
+		Pattern  string    `yaml:"pattern" json:"pattern"`   // regexp string values must match
+		Min      *float64  `yaml:"min" json:"min"`           // least number, or length of strings and lists
+		Max      *float64  `yaml:"max" json:"max"`           // greatest number, or length of strings and lists
+		Layout   string    `yaml:"layout" json:"layout"`     // time layout, TimeLayouts if empty
+		Elem     *Field    `yaml:"elem" json:"elem"`         // definition of list elements
+		Fields   Schema    `yaml:"fields" json:"fields"`     // fields of objects
+	}
+)
+
+// fieldPatterns caches compiled Field.Pattern.
+var fieldPatterns syncutil.SyncMap[string, *regexp.Regexp]
+
+// Field returns the definition of the field name, nil if undefined.
+func (s Schema) Field(name string) *Field {
+	for i := range s {
+		if s[i].Name == name {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+// Names returns the field names in order.
+func (s Schema) Names() []string {
+	names := make([]string, len(s))
+	for i, f := range s {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Coerce returns a copy of item with the fields converted to their types,
+// or an error listing every field that could not be converted or failed
+// validation.
+func (s Schema) Coerce(item map[string]interface{}) result.Result[map[string]interface{}] {
+	var errs []string
+	out := s.coerce(item, "", &errs)
+	if len(errs) > 0 {
+		return result.FmtErr[map[string]interface{}]("%s", strings.Join(errs, "; "))
+	}
+	return result.Ok(out)
+}
+
+// Check reports an invalid definition like an unknown type or a bad pattern.
+func (s Schema) Check() error {
+	for i := range s {
+		if err := s[i].check(); err != nil {
+			return fmt.Errorf("field %s: %v", s[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func (s Schema) coerce(item map[string]interface{}, path string, errs *[]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(item))
+	for k, v := range item {
+		out[k] = v
+	}
+	for i := range s {
+		f := &s[i]
+		v, err := f.coerce(item[f.Name], path+f.Name, errs)
+		if err != nil {
+			*errs = append(*errs, path+f.Name+": "+err.Error())
+			continue
+		}
+		if v == nil {
+			delete(out, f.Name)
+		} else {
+			out[f.Name] = v
+		}
+	}
+	return out
+}
+
+// Convert returns v converted to the field type and validated as
+// Schema.Coerce does, nil for a missing value.
+func (f *Field) Convert(v interface{}) (interface{}, error) {
+	var errs []string
+	v, err := f.coerce(v, f.Name, &errs)
+	if err == nil && len(errs) > 0 {
+		err = fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return v, err
+}
+
+func (f *Field) check() error {
+	switch f.Type {
+	case TypeAny, TypeString, TypeInt, TypeFloat, TypeBool, TypeTime, TypeList, TypeObject:
+	default:
+		return fmt.Errorf("unknown type %q", f.Type)
+	}
+	if f.Pattern != "" {
+		if _, err := f.pattern(); err != nil {
+			return err
+		}
+	}
+	if f.Elem != nil {
+		if err := f.Elem.check(); err != nil {
+			return fmt.Errorf("elem: %v", err)
+		}
+	}
+	return f.Fields.Check()
+}
+
+// coerce converts and validates v; nil is a missing value.
+func (f *Field) coerce(v interface{}, path string, errs *[]string) (interface{}, error) {
+	if s, ok := v.(string); ok && strings.TrimSpace(s) == "" && f.Type != TypeString && f.Type != TypeAny {
+		v = nil
+	}
+	if v == nil {
+		switch {
+		case f.Required:
+			return nil, fmt.Errorf("required")
+		case f.NotEmpty:
+			return nil, fmt.Errorf("empty")
+		}
+		return nil, nil
+	}
+	v, err := f.convert(v, path, errs)
+	if err != nil {
+		return nil, err
+	}
+	return v, f.validate(v)
+}
+
+func (f *Field) convert(v interface{}, path string, errs *[]string) (interface{}, error) {
+	switch f.Type {
+	case TypeString:
+		return toText(v), nil
+	case TypeInt:
+		return toInt(v)
+	case TypeFloat:
+		return toFloat(v)
+	case TypeBool:
+		return toBool(v)
+	case TypeTime:
+		return toTime(v, f.Layout)
+	case TypeList:
+		list, err := toList(v)
+		if err != nil || f.Elem == nil {
+			return list, err
+		}
+		for i, e := range list {
+			if list[i], err = f.Elem.coerce(e, fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+		}
+		return list, nil
+	case TypeObject:
+		obj, err := toObject(v)
+		if err != nil {
+			return nil, err
+		}
+		return f.Fields.coerce(obj, path+".", errs), nil
+	}
+	return v, nil
+}
+
+func (f *Field) validate(v interface{}) error {
+	var (
+		size float64
+		what string // what Min and Max bound
+	)
+	switch v := v.(type) {
+	case string:
+		if f.NotEmpty && strings.TrimSpace(v) == "" {
+			return fmt.Errorf("empty")
+		}
+		if f.Pattern != "" {
+			re, err := f.pattern()
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%q does not match %s", v, f.Pattern)
+			}
+		}
+		size, what = float64(utf8.RuneCountInString(v)), "length"
+	case []interface{}:
+		if f.NotEmpty && len(v) == 0 {
+			return fmt.Errorf("empty")
+		}
+		size, what = float64(len(v)), "length"
+	case map[string]interface{}:
+		if f.NotEmpty && len(v) == 0 {
+			return fmt.Errorf("empty")
+		}
+	case int64:
+		size, what = float64(v), "value"
+	case float64:
+		size, what = v, "value"
+	}
+	if what == "" {
+		return nil
+	}
+	if f.Min != nil && size < *f.Min {
+		return fmt.Errorf("%s %v less than %v", what, size, *f.Min)
+	}
+	if f.Max != nil && size > *f.Max {
+		return fmt.Errorf("%s %v greater than %v", what, size, *f.Max)
+	}
+	return nil
+}
+
+func (f *Field) pattern() (*regexp.Regexp, error) {
+	if re := fieldPatterns.Load(f.Pattern); re.IsSome() {
+		return re.Unwrap(), nil
+	}
+	re, err := regexp.Compile(f.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	fieldPatterns.Store(f.Pattern, re)
+	return re, nil
+}
+
+// toText returns v as text, JSON for lists and objects.
+func toText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	case fmt.Stringer:
+		return v.String()
+	}
+	return util.JSONString(v)
+}
+
+// numberText strips spaces and thousands separators from s.
+func numberText(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+}
+
+func toInt(v interface{}) (int64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("%v overflows int", v)
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			return int64(f), nil
+		}
+	case reflect.String:
+		s := numberText(rv.String())
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+			return int64(f), nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not an int", util.JSONString(v))
+}
+
+func toFloat(v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		if f, err := strconv.ParseFloat(numberText(rv.String()), 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not a float", util.JSONString(v))
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "1", "t", "true", "y", "yes", "on":
+			return true, nil
+		case "0", "f", "false", "n", "no", "off":
+			return false, nil
+		}
+	default:
+		if n, err := toInt(v); err == nil && (n == 0 || n == 1) {
+			return n == 1, nil
+		}
+	}
+	return false, fmt.Errorf("%s is not a bool", util.JSONString(v))
+}
+
+func toTime(v interface{}, layout string) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		s := strings.TrimSpace(t)
+		layouts := TimeLayouts
+		if layout != "" {
+			layouts = []string{layout}
+		}
+		for _, l := range layouts {
+			if t, err := time.ParseInLocation(l, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		if layout != "" {
+			break
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return unixTime(n), nil
+		}
+	default:
+		if n, err := toInt(v); err == nil {
+			return unixTime(n), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s is not a time", util.JSONString(v))
+}
+
+// unixTime returns the time of Unix seconds or milliseconds.
+func unixTime(n int64) time.Time {
+	if n > 1e12 || n < -1e12 {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+func toList(v interface{}) ([]interface{}, error) {
+	if s, ok := v.(string); ok {
+		var list []interface{}
+		if err := json.Unmarshal([]byte(s), &list); err != nil {
+			return nil, fmt.Errorf("%q is not a list", s)
+		}
+		return list, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%s is not a list", util.JSONString(v))
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, nil
+}
+
+func toObject(v interface{}) (map[string]interface{}, error) {
+	if s, ok := v.(string); ok {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &obj); err != nil {
+			return nil, fmt.Errorf("%q is not an object", s)
+		}
+		return obj, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("%s is not an object", util.JSONString(v))
+	}
+	obj := make(map[string]interface{}, rv.Len())
+	for it := rv.MapRange(); it.Next(); {
+		obj[it.Key().String()] = it.Value().Interface()
+	}
+	return obj, nil
+}
+
+// initSchema puts the schema fields of the rules into their ItemFields and
+// those of the reject rules, so that outputs create them in order.
+func (rt *RuleTree) initSchema() {
+	for _, r := range rt.Trunk {
+		for _, name := range r.Schema.Names() {
+			upsertField(r, name)
+		}
+	}
+	for name, r := range rt.Trunk {
+		if r.Reject == "" {
+			continue
+		}
+		reject := rt.Trunk[r.Reject]
+		if reject == nil {
+			logs.Log().Error(" *     rule %s: undefined reject rule %s", name, r.Reject)
+			continue
+		}
+		for _, f := range r.ItemFields {
+			upsertField(reject, f)
+		}
+		upsertField(reject, RejectField)
+	}
+}
+
+func upsertField(r *Rule, field string) {
+	for _, f := range r.ItemFields {
+		if f == field {
+			return
+		}
+	}
+	r.ItemFields = append(r.ItemFields, field)
+}
+
+// reject counts and logs an item of ruleName failing the schema, and outputs
+// it with the errors to the reject rule if set.
+func (ctx *Context) reject(ruleName string, rule *Rule, item map[string]interface{}, err error) {
+	atomic.AddUint64(&ctx.spider.rejected, 1)
+	logs.Log().Warning(" *     [Output][%s][%s]: invalid item: %v", ctx.GetURL(), ruleName, err)
+	if rule.Reject == "" {
+		return
+	}
+	reject := ctx.spider.GetRule(rule.Reject)
+	if reject == nil {
+		logs.Log().Error("spider %s: undefined reject rule %s", ctx.spider.GetName(), rule.Reject)
+		return
+	}
+	rejected := make(map[string]interface{}, len(item)+1)
+	for k, v := range item {
+		ctx.spider.UpsertItemField(reject, k)
+		rejected[k] = v
+	}
+	ctx.spider.UpsertItemField(reject, RejectField)
+	rejected[RejectField] = err.Error()
+	ctx.appendItem(rule.Reject, rejected)
+}
+
+// GetRejected returns the number of items that failed their rule's schema.
+func (sp *Spider) GetRejected() uint64 {
+	return atomic.LoadUint64(&sp.rejected)
+}
@@ -0,0 +1,144 @@
+package spider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+)
+
+func bound(f float64) *float64 { return &f }
+
+func TestSchema_Coerce(t *testing.T) {
+	schema := Schema{
+		{Name: "title", Type: TypeString, Required: true, NotEmpty: true},
+		{Name: "price", Type: TypeFloat, Min: bound(0)},
+		{Name: "stock", Type: TypeInt},
+		{Name: "sale", Type: TypeBool},
+		{Name: "date", Type: TypeTime},
+		{Name: "day", Type: TypeTime, Layout: "02/01/2006"},
+		{Name: "tags", Type: TypeList, Elem: &Field{Type: TypeString, Pattern: `^\w+$`}, Max: bound(3)},
+		{Name: "spec", Type: TypeObject, Fields: Schema{{Name: "weight", Type: TypeInt, Required: true}}},
+		{Name: "note", Type: TypeInt},
+	}
+	item, err := schema.Coerce(map[string]interface{}{
+		"title": "Go",
+		"price": "1,299.50",
+		"stock": 12.0,
+		"sale":  "yes",
+		"date":  "2024-05-06 07:08:09",
+		"day":   "06/05/2024",
+		"tags":  []string{"go", "web"},
+		"spec":  `{"weight": "350", "color": "red"}`,
+		"note":  " ",
+		"extra": 1,
+	}).Split()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"title": "Go",
+		"price": 1299.5,
+		"stock": int64(12),
+		"sale":  true,
+		"date":  time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local),
+		"day":   time.Date(2024, 5, 6, 0, 0, 0, 0, time.Local),
+		"tags":  []interface{}{"go", "web"},
+		"spec":  map[string]interface{}{"weight": int64(350), "color": "red"},
+		"extra": 1,
+	}
+	for k, w := range want {
+		if got := item[k]; !reflect.DeepEqual(got, w) {
+			if gt, ok := got.(time.Time); !ok || !gt.Equal(w.(time.Time)) {
+				t.Errorf("%s = %#v, want %#v", k, got, w)
+			}
+		}
+	}
+	if _, ok := item["note"]; ok || len(item) != len(want) {
+		t.Errorf("item = %v, want blank note dropped", item)
+	}
+}
+
+func TestSchema_CoerceErrors(t *testing.T) {
+	schema := Schema{
+		{Name: "title", Required: true},
+		{Name: "name", Type: TypeString, NotEmpty: true, Max: bound(3)},
+		{Name: "price", Type: TypeFloat, Min: bound(0)},
+		{Name: "stock", Type: TypeInt},
+		{Name: "tags", Type: TypeList, Elem: &Field{Type: TypeInt}},
+		{Name: "spec", Type: TypeObject, Fields: Schema{{Name: "weight", Type: TypeInt, Required: true}}},
+		{Name: "sku", Pattern: `^\d+$`},
+	}
+	_, err := schema.Coerce(map[string]interface{}{
+		"name":  "Pholcus",
+		"price": -1,
+		"stock": "1.5",
+		"tags":  []interface{}{1, "x"},
+		"spec":  map[string]interface{}{},
+		"sku":   "A1",
+	}).Split()
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	for _, msg := range []string{
+		"title: required",
+		"name: length 7 greater than 3",
+		"price: value -1 less than 0",
+		`stock: "1.5" is not an int`,
+		`tags: [1]: "x" is not an int`,
+		"spec.weight: required",
+		`sku: "A1" does not match`,
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("error %q lacks %q", err, msg)
+		}
+	}
+}
+
+func TestSchema_Check(t *testing.T) {
+	if err := (Schema{{Name: "a", Type: "decimal"}}).Check(); err == nil || !strings.Contains(err.Error(), "unknown type") {
+		t.Errorf("unknown type: err = %v", err)
+	}
+	if err := (Schema{{Name: "a", Elem: &Field{Pattern: "("}}}).Check(); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("bad pattern: err = %v", err)
+	}
+}
+
+func TestContextOutput_Schema(t *testing.T) {
+	sp := &Spider{Name: "schema", RuleTree: &RuleTree{Trunk: map[string]*Rule{
+		"item": {
+			Schema: Schema{{Name: "name", Required: true}, {Name: "price", Type: TypeFloat}},
+			Reject: "invalid",
+		},
+		"invalid": {},
+	}}}
+	sp.RuleTree.initSchema()
+	if got := sp.GetRule("invalid").ItemFields; !reflect.DeepEqual(got, []string{"name", "price", RejectField}) {
+		t.Errorf("reject ItemFields = %v", got)
+	}
+	ctx := GetContext(sp, &request.Request{URL: "http://example.com", Rule: "item"})
+	defer PutContext(ctx)
+
+	ctx.Output(map[string]interface{}{"name": "a", "price": "2.5"}, "item")
+	ctx.Output(map[string]interface{}{"price": "cheap"}, "item")
+	ctx.Output(map[int]interface{}{0: "b", 1: 3}, "item")
+
+	items := ctx.PullItems()
+	if len(items) != 3 || sp.GetRejected() != 1 {
+		t.Fatalf("items = %v, rejected = %d", items, sp.GetRejected())
+	}
+	if got := items[0]["Data"].(map[string]interface{})["price"]; got != 2.5 {
+		t.Errorf("price = %#v, want 2.5", got)
+	}
+	if items[1]["RuleName"] != "invalid" {
+		t.Fatalf("rejected item went to %v", items[1]["RuleName"])
+	}
+	if e, _ := items[1]["Data"].(map[string]interface{})[RejectField].(string); !strings.Contains(e, "name: required") || !strings.Contains(e, "price:") {
+		t.Errorf("reject error = %q", e)
+	}
+	if got := items[2]["Data"].(map[string]interface{})["price"]; got != 3.0 {
+		t.Errorf("price = %#v, want 3", got)
+	}
+}
@@ -1,14 +1,23 @@
 package spider
 
 import (
+	"context"
 	"errors"
 	"math"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/time/rate"
+
+	"github.com/andeya/pholcus/app/aid/proxy"
 	"github.com/andeya/pholcus/app/downloader/request"
+	"github.com/andeya/pholcus/app/downloader/surfer"
+	"github.com/andeya/pholcus/app/downloader/surfer/agent"
 	"github.com/andeya/pholcus/app/scheduler"
 	"github.com/andeya/pholcus/common/util"
+	"github.com/andeya/pholcus/config"
 	"github.com/andeya/pholcus/logs"
 	"github.com/andeya/pholcus/runtime/status"
 )
@@ -31,19 +40,41 @@ type (
 		Limit           int64                                                      // request limit (0 = unlimited; set to LIMIT for custom limit logic in rules)
 		Keyin           string                                                     // custom input config (set to KEYIN in rules to enable)
 		EnableCookie    bool                                                       // whether requests carry cookies
+		CookieFile      string                                                     // cookies (JSON or cookies.txt) to start the cookie jar with
+		TLS             *surfer.TLSOptions                                         // HTTPS settings of requests without their own; nil uses config.ini [tls]
+		Auth            Authenticator                                              // credentials added to every request, e.g. &BasicAuth{...}
+		Signer          surfer.Signer                                              // signs every send attempt of the Surf downloader, e.g. (&surfer.HMACSigner{...}).Sign
+		Profile         string                                                     // browser identity of requests, see agent.LookupProfile; agent.RandomProfile picks one
+		HTTP3           bool                                                       // Surf tries HTTP/3 first for all requests, falling back to HTTP/2 and HTTP/1.1
+		ProxyPolicy     proxy.Policy                                               // how requests share proxy IPs; the zero value rotates them per request
+		Middlewares     []DownloaderMiddleware                                     // download hooks of this spider, run inside the global ones
+		Processors      []ItemProcessor                                            // item pipeline run by the collector on the items of all rules
+		BanDetectors    []*BanDetector                                             // recognize ban/captcha pages, which are retried with a new identity
+		BanRetries      int                                                        // retries after a ban (0 = DefaultBanRetries, <0 = none)
+		Bandwidth       int64                                                      // max download rate in bytes/s (0 = unlimited), within config.ini [download] bandwidth
 		NotDefaultField bool                                                       // disable default output fields Url/ParentUrl/DownloadTime
 		Namespace       func(sp *Spider) string                                    // namespace for output file/path naming
 		SubNamespace    func(self *Spider, dataCell map[string]interface{}) string // sub-namespace, may depend on specific data content
 		RuleTree        *RuleTree                                                  // crawl rule tree
 
 		// System-assigned fields
-		id        int
-		subName   string            // secondary identifier derived from Keyin
-		reqMatrix *scheduler.Matrix // request scheduling matrix
-		timer     *Timer
-		status    int
-		lock      sync.RWMutex
-		once      sync.Once
+		id         int
+		subName    string            // secondary identifier derived from Keyin
+		reqMatrix  *scheduler.Matrix // request scheduling matrix
+		timer      *Timer
+		status     int
+		stopped    context.CancelFunc // cancels stopCtx on Stop
+		stopCtx    context.Context
+		responses  uint64 // downloaded responses, for ban statistics
+		banned     uint64 // responses recognized as bans
+		rejected   uint64 // items failing their rule's schema
+		downloaded uint64 // response body bytes read
+		limiter    *rate.Limiter
+		limitOnce  sync.Once
+		jar        *surfer.Jar
+		profile    string // random profile kept for the cookie session
+		lock       sync.RWMutex
+		once       sync.Once
 	}
 	// RuleTree defines the crawl rule tree.
 	RuleTree struct {
@@ -53,6 +84,10 @@ type (
 	// Rule defines a single crawl rule node.
 	Rule struct {
 		ItemFields []string                                           // result field names (optional; preserves field order)
+		Schema     Schema                                             // typed result fields, validated by Output (optional)
+		Reject     string                                             // rule receiving the items failing Schema with their errors; "" drops them
+		Processors []ItemProcessor                                    // item pipeline run after the spider's Processors
+		Dedup      *Dedup                                             // item deduplication by key fields (optional)
 		ParseFunc  func(*Context)                                     // content parsing function
 		AidFunc    func(*Context, map[string]interface{}) interface{} // auxiliary helper function
 	}
@@ -61,6 +96,8 @@ type (
 // Register adds this spider to the global species list.
 func (sp *Spider) Register() *Spider {
 	sp.status = status.STOPPED
+	sp.RuleTree.initSchema()
+	sp.checkDedup()
 	return Species.Add(sp)
 }
 
@@ -177,6 +214,54 @@ func (sp *Spider) GetEnableCookie() bool {
 	return sp.EnableCookie
 }
 
+// GetProfile returns the browser identity profile inherited by requests
+// that set none. With agent.RandomProfile, requests get random profiles,
+// except that a cookie session keeps the first one so that it stays coherent.
+func (sp *Spider) GetProfile() string {
+	if sp.Profile != agent.RandomProfile {
+		return sp.Profile
+	}
+	if !sp.EnableCookie {
+		return pickProfile()
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	if sp.profile == "" {
+		sp.profile = pickProfile()
+	}
+	return sp.profile
+}
+
+func pickProfile() string {
+	if p := agent.PickProfile(""); p != nil {
+		return p.Name
+	}
+	return ""
+}
+
+// GetTLS returns the HTTPS settings inherited by requests that set none:
+// the spider's own, otherwise those of config.ini [tls]; nil means defaults.
+func (sp *Spider) GetTLS() *surfer.TLSOptions {
+	if sp.TLS != nil {
+		return sp.TLS
+	}
+	c := config.Conf().TLS
+	opts := &surfer.TLSOptions{
+		InsecureSkipVerify: c.Insecure,
+		ClientCert:         c.ClientCert,
+		ClientKey:          c.ClientKey,
+	}
+	for _, f := range strings.Split(c.RootCAs, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			opts.RootCAs = append(opts.RootCAs, f)
+		}
+	}
+	if opts.IsZero() {
+		return nil
+	}
+	return opts
+}
+
 // SetPausetime sets a custom pause interval. Only overwrites an existing value when runtime[0] is true.
 func (sp *Spider) SetPausetime(pause int64, runtime ...bool) {
 	if sp.Pausetime == 0 || len(runtime) > 0 && runtime[0] {
@@ -217,6 +302,10 @@ func (sp *Spider) Copy() *Spider {
 		ghost.RuleTree.Trunk[k].ItemFields = make([]string, len(v.ItemFields))
 		copy(ghost.RuleTree.Trunk[k].ItemFields, v.ItemFields)
 
+		ghost.RuleTree.Trunk[k].Schema = v.Schema
+		ghost.RuleTree.Trunk[k].Reject = v.Reject
+		ghost.RuleTree.Trunk[k].Processors = v.Processors
+		ghost.RuleTree.Trunk[k].Dedup = v.Dedup
 		ghost.RuleTree.Trunk[k].ParseFunc = v.ParseFunc
 		ghost.RuleTree.Trunk[k].AidFunc = v.AidFunc
 	}
@@ -224,6 +313,18 @@ func (sp *Spider) Copy() *Spider {
 	ghost.Description = sp.Description
 	ghost.Pausetime = sp.Pausetime
 	ghost.EnableCookie = sp.EnableCookie
+	ghost.CookieFile = sp.CookieFile
+	ghost.TLS = sp.TLS
+	ghost.Auth = sp.Auth
+	ghost.Signer = sp.Signer
+	ghost.Profile = sp.Profile
+	ghost.HTTP3 = sp.HTTP3
+	ghost.ProxyPolicy = sp.ProxyPolicy
+	ghost.Middlewares = sp.Middlewares
+	ghost.Processors = sp.Processors
+	ghost.BanDetectors = sp.BanDetectors
+	ghost.BanRetries = sp.BanRetries
+	ghost.Bandwidth = sp.Bandwidth
 	ghost.Limit = sp.Limit
 	ghost.Keyin = sp.Keyin
 
@@ -245,6 +346,11 @@ func (sp *Spider) ReqmatrixInit() *Spider {
 	} else {
 		sp.reqMatrix = scheduler.AddMatrix(sp.GetName(), sp.GetSubName(), math.MinInt64)
 	}
+	var jar http.CookieJar
+	if sp.ProxyPolicy.Sticky == proxy.StickySession && sp.GetEnableCookie() {
+		jar = sp.GetCookieJar()
+	}
+	sp.reqMatrix.SetProxyPolicy(sp.ProxyPolicy, jar)
 	return sp
 }
 
@@ -304,6 +410,9 @@ func (sp *Spider) Stop() {
 		return
 	}
 	sp.status = status.STOP
+	if sp.stopped != nil {
+		sp.stopped()
+	}
 	if sp.timer != nil {
 		sp.timer.drop()
 		sp.timer = nil
@@ -324,6 +433,20 @@ func (sp *Spider) IsStopping() bool {
 	return sp.status == status.STOP
 }
 
+// StopContext returns a context cancelled when the spider is stopped, e.g.
+// to abandon the bandwidth waits of its downloads.
+func (sp *Spider) StopContext() context.Context {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	if sp.stopCtx == nil {
+		sp.stopCtx, sp.stopped = context.WithCancel(context.Background())
+		if sp.status == status.STOP {
+			sp.stopped()
+		}
+	}
+	return sp.stopCtx
+}
+
 // tryStop returns ErrForcedStop if the spider is being stopped, nil otherwise.
 func (sp *Spider) tryStop() error {
 	if sp.IsStopping() {
@@ -340,6 +463,7 @@ func (sp *Spider) Defer() {
 	}
 	sp.reqMatrix.Wait()
 	sp.reqMatrix.TryFlushFailure()
+	sp.SaveCookies()
 }
 
 // OutDefaultField reports whether default fields (Url/ParentUrl/DownloadTime) should be included in output.
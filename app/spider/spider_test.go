@@ -0,0 +1,30 @@
+package spider
+
+import (
+	"testing"
+
+	"github.com/andeya/pholcus/app/downloader/surfer/agent"
+)
+
+func TestGetProfile(t *testing.T) {
+	if got := (&Spider{Profile: "firefox-linux"}).GetProfile(); got != "firefox-linux" {
+		t.Errorf("fixed GetProfile() = %q, want firefox-linux", got)
+	}
+	session := &Spider{Profile: agent.RandomProfile, EnableCookie: true}
+	first := session.GetProfile()
+	if agent.LookupProfile(first) == nil {
+		t.Fatalf("random GetProfile() = %q, not a registered profile", first)
+	}
+	for i := 0; i < 10; i++ {
+		if got := session.GetProfile(); got != first {
+			t.Fatalf("cookie session profile changed from %q to %q", first, got)
+		}
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		seen[(&Spider{Profile: agent.RandomProfile}).GetProfile()] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("random profiles without cookies = %v, want variety", seen)
+	}
+}
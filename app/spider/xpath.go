@@ -0,0 +1,104 @@
+package spider
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/andeya/gust/syncutil"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+
+	"github.com/andeya/pholcus/logs"
+)
+
+// xpathExprs caches compiled XPath expressions of the rules. They are only
+// used through Select, which runs a clone of the query, so that goroutines
+// can share them.
+var xpathExprs syncutil.SyncMap[string, *xpath.Expr]
+
+// XPath returns the nodes matching the XPath expression expr in the
+// document of GetDom, or relative to from[0] if given. Attribute nodes like
+// //a/@href are returned as elements whose text is the attribute value.
+func (ctx *Context) XPath(expr string, from ...*html.Node) []*html.Node {
+	root, e := ctx.xpathQuery("XPath", expr, from, true)
+	if e == nil {
+		return nil
+	}
+	return htmlquery.QuerySelectorAll(root, e)
+}
+
+// XPathOne returns the first node matching expr, or nil; see XPath.
+func (ctx *Context) XPathOne(expr string, from ...*html.Node) *html.Node {
+	root, e := ctx.xpathQuery("XPathOne", expr, from, true)
+	if e == nil {
+		return nil
+	}
+	return htmlquery.QuerySelector(root, e)
+}
+
+// XPathStrings returns the trimmed texts of the nodes matching expr, e.g.
+// the values of //a/@href or the texts of //li/text(); see XPath.
+func (ctx *Context) XPathStrings(expr string, from ...*html.Node) []string {
+	nodes := ctx.XPath(expr, from...)
+	texts := make([]string, len(nodes))
+	for i, n := range nodes {
+		texts[i] = strings.TrimSpace(htmlquery.InnerText(n))
+	}
+	return texts
+}
+
+// XPathString evaluates expr: the trimmed text of the first matching node,
+// or the value of an expression like count(//a) or string(//title).
+func (ctx *Context) XPathString(expr string, from ...*html.Node) string {
+	// Evaluate runs the query of e in place, so e must not be shared.
+	root, e := ctx.xpathQuery("XPathString", expr, from, false)
+	if e == nil {
+		return ""
+	}
+	switch v := e.Evaluate(htmlquery.CreateXPathNavigator(root)).(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case *xpath.NodeIterator:
+		if v.MoveNext() {
+			return strings.TrimSpace(v.Current().Value())
+		}
+	}
+	return ""
+}
+
+// XPathAttr returns the attribute name of the first element matching expr;
+// see XPath.
+func (ctx *Context) XPathAttr(expr, name string, from ...*html.Node) string {
+	return htmlquery.SelectAttr(ctx.XPathOne(expr, from...), name)
+}
+
+// xpathQuery returns the node to evaluate expr from and the compiled
+// expr, from xpathExprs if shared; nil if the document is unavailable or
+// expr invalid.
+func (ctx *Context) xpathQuery(method, expr string, from []*html.Node, shared bool) (*html.Node, *xpath.Expr) {
+	var root *html.Node
+	if len(from) > 0 && from[0] != nil {
+		root = from[0]
+	} else if dom := ctx.GetDom(); dom != nil && len(dom.Nodes) > 0 {
+		root = dom.Nodes[0]
+	} else {
+		return nil, nil
+	}
+	if e := xpathExprs.Load(expr); shared && e.IsSome() {
+		return root, e.Unwrap()
+	}
+	e, err := xpath.Compile(expr)
+	if err != nil {
+		logs.Log().Error(" *     [%s][%s]: %q: %v", method, ctx.GetURL(), expr, err)
+		return nil, nil
+	}
+	if shared {
+		xpathExprs.Store(expr, e)
+	}
+	return root, e
+}
@@ -0,0 +1,112 @@
+package spider
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/robertkrimen/otto"
+
+	"github.com/andeya/pholcus/app/downloader/request"
+)
+
+const xpathPage = `<html><head><title> Shop </title></head><body>
+<ul>
+  <li class="item"><a href="/p/1">Apple</a><span>1.50</span></li>
+  <li class="item"><a href="/p/2">Pear</a><span>2.00</span></li>
+</ul>
+<dl><dt>Brand</dt><dd>Acme</dd><dt>Origin</dt><dd>Spain</dd></dl>
+</body></html>`
+
+func xpathContext(t *testing.T) *Context {
+	ctx := GetContext(&Spider{}, &request.Request{URL: "http://example.com/list", Rule: "list"})
+	ctx.SetResponse(&http.Response{Body: io.NopCloser(strings.NewReader(xpathPage)), Header: http.Header{}})
+	t.Cleanup(func() { PutContext(ctx) })
+	return ctx
+}
+
+func TestContextXPath(t *testing.T) {
+	ctx := xpathContext(t)
+
+	if got := ctx.XPathStrings("//li/a/@href"); strings.Join(got, ",") != "/p/1,/p/2" {
+		t.Errorf("XPathStrings(@href) = %q", got)
+	}
+	if got := ctx.XPathString("//dt[text()='Origin']/following-sibling::dd[1]"); got != "Spain" {
+		t.Errorf("sibling axis = %q, want Spain", got)
+	}
+	if got := ctx.XPathString("string(//title)"); got != "Shop" {
+		t.Errorf("string(//title) = %q, want Shop", got)
+	}
+	if got := ctx.XPathString("count(//li)"); got != "2" {
+		t.Errorf("count(//li) = %q, want 2", got)
+	}
+	if got := ctx.XPathAttr("//a[text()='Pear']", "href"); got != "/p/2" {
+		t.Errorf("XPathAttr = %q, want /p/2", got)
+	}
+
+	items := ctx.XPath("//li[@class='item']")
+	if len(items) != 2 {
+		t.Fatalf("len(XPath) = %d, want 2", len(items))
+	}
+	if got := ctx.XPathString("./span", items[1]); got != "2.00" {
+		t.Errorf("relative XPathString = %q, want 2.00", got)
+	}
+	if ctx.XPathOne("//table") != nil {
+		t.Error("XPathOne without match want nil")
+	}
+	if ctx.XPath("//li[") != nil || ctx.XPathString("//li[") != "" {
+		t.Error("invalid expression want no result")
+	}
+	// The same parsed document serves CSS selectors.
+	if got := ctx.GetDom().Find("li a").First().Text(); got != "Apple" {
+		t.Errorf("GetDom after XPath = %q", got)
+	}
+}
+
+func TestContextXPathConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		ctx := xpathContext(t)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if got := ctx.XPathString("//li/span"); got != "1.50" {
+					t.Errorf("XPathString = %q, want 1.50", got)
+					return
+				}
+				if got := ctx.XPathString("count(//li)"); got != "2" {
+					t.Errorf("count(//li) = %q, want 2", got)
+					return
+				}
+				if got := ctx.XPathStrings("//li/a"); len(got) != 2 {
+					t.Errorf("XPathStrings = %q", got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestContextXPathJS(t *testing.T) {
+	ctx := xpathContext(t)
+	vm := otto.New()
+	vm.Set("ctx", ctx)
+	v, err := vm.Run(`
+		var items = ctx.XPath("//li");
+		var prices = [];
+		for (var i = 0; i < items.length; i++) {
+			prices.push(ctx.XPathString("./span", items[i]));
+		}
+		ctx.XPathStrings("//li/a").join(",") + "|" + prices.join(",") + "|" + ctx.XPathAttr("//li/a", "href");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.String(); got != "Apple,Pear|1.50,2.00|/p/1" {
+		t.Errorf("JS result = %q", got)
+	}
+}
@@ -22,7 +22,8 @@ type Table struct {
 	args             []interface{} // data
 	sqlCode          string
 	customPrimaryKey bool
-	size             int // approximate content size
+	replace          bool // FlushInsert replaces rows with the same key
+	size             int  // approximate content size
 }
 
 type mysqlConst struct {
@@ -93,6 +94,7 @@ func (m *Table) Clone() *Table {
 		tableName:        m.tableName,
 		columnNames:      m.columnNames,
 		customPrimaryKey: m.customPrimaryKey,
+		replace:          m.replace,
 	}
 }
 
@@ -119,6 +121,13 @@ func (t *Table) CustomPrimaryKey(primaryKeyCode string) *Table {
 	return t
 }
 
+// Replace makes FlushInsert use REPLACE INTO, overwriting the rows with the
+// same primary or unique key.
+func (t *Table) Replace() *Table {
+	t.replace = true
+	return t
+}
+
 // Create generates and executes a CREATE TABLE statement. Requires prior SetTableName() and AddColumn().
 func (t *Table) Create() (r result.VoidResult) {
 	defer r.Catch()
@@ -159,24 +168,36 @@ func (t *Table) Truncate() (r result.VoidResult) {
 	return result.OkVoid()
 }
 
-func (t *Table) addRow(value []string) *Table {
-	for i, count := 0, len(value); i < count; i++ {
-		t.args = append(t.args, value[i])
-	}
+func (t *Table) addRow(values []interface{}) *Table {
+	t.args = append(t.args, values...)
 	t.rowsCount++
 	return t
 }
 
 // AutoInsert adds a row for insert, flushing automatically when buffer is full or size limit is reached.
 func (t *Table) AutoInsert(value []string) *Table {
+	values := make([]interface{}, len(value))
+	for i, v := range value {
+		values[i] = v
+	}
+	return t.AutoInsertValues(values)
+}
+
+// AutoInsertValues is AutoInsert for typed values like int64 or time.Time;
+// nil inserts NULL.
+func (t *Table) AutoInsertValues(values []interface{}) *Table {
 	mc := getMysqlConst()
 	if t.rowsCount > 100 {
 		t.FlushInsert().Unwrap()
-		return t.AutoInsert(value)
+		return t.AutoInsertValues(values)
 	}
 	var nsize int
-	for _, v := range value {
-		nsize += len(v)
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			nsize += len(s)
+		} else {
+			nsize += 8
+		}
 	}
 	if nsize > mc.maxPkt {
 		logs.Log().Error("%v", "packet for query is too large. Try adjusting the 'maxallowedpacket'variable in the 'config.ini'")
@@ -185,9 +206,9 @@ func (t *Table) AutoInsert(value []string) *Table {
 	t.size += nsize
 	if t.size > mc.maxPkt {
 		t.FlushInsert().Unwrap()
-		return t.AutoInsert(value)
+		return t.AutoInsertValues(values)
 	}
-	return t.addRow(value)
+	return t.addRow(values)
 }
 
 // FlushInsert executes the buffered INSERT. Create and AutoInsert must be called first.
@@ -202,7 +223,11 @@ func (t *Table) FlushInsert() (r result.VoidResult) {
 		return result.OkVoid()
 	}
 
-	t.sqlCode = `INSERT INTO ` + t.tableName + `(`
+	t.sqlCode = `INSERT INTO `
+	if t.replace {
+		t.sqlCode = `REPLACE INTO `
+	}
+	t.sqlCode += t.tableName + `(`
 
 	for _, v := range t.columnNames {
 		t.sqlCode += v[0] + ","
@@ -171,6 +171,17 @@ func TestTable_AutoInsert_MultipleColumns(t *testing.T) {
 	}
 }
 
+func TestTable_AutoInsertValues(t *testing.T) {
+	tbl := New().Unwrap().SetTableName("t").AddColumn("a BIGINT", "b DATETIME", "c MEDIUMTEXT")
+	tbl = tbl.AutoInsertValues([]interface{}{int64(7), nil, "foo"})
+	if tbl.rowsCount != 1 || len(tbl.args) != 3 {
+		t.Fatalf("rowsCount = %d, args = %v", tbl.rowsCount, tbl.args)
+	}
+	if tbl.args[0] != int64(7) || tbl.args[1] != nil || tbl.args[2] != "foo" {
+		t.Errorf("args = %v, want typed values kept and nil as NULL", tbl.args)
+	}
+}
+
 func TestNew_Unwrap(t *testing.T) {
 	r := New()
 	tbl := r.Unwrap()
@@ -291,6 +302,24 @@ func TestTable_FlushInsert_WithMock(t *testing.T) {
 	}
 }
 
+func TestTable_FlushInsert_Replace(t *testing.T) {
+	_, mock, teardown := setupMockDB(t)
+	defer teardown()
+
+	mock.ExpectExec(regexp.QuoteMeta("REPLACE INTO `t`(`id`,`v`) VALUES (?,?);")).
+		WithArgs("k", "v").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tbl := New().Unwrap().SetTableName("t").CustomPrimaryKey("id VARCHAR(255) NOT NULL PRIMARY KEY").AddColumn("v VARCHAR(255)").Replace()
+	r := tbl.Clone().AutoInsert([]string{"k", "v"}).FlushInsert()
+	if r.IsErr() {
+		t.Errorf("FlushInsert() = %v", r.UnwrapErr())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("mock: %v", err)
+	}
+}
+
 func TestTable_SelectAll_WithMock(t *testing.T) {
 	_, mock, teardown := setupMockDB(t)
 	defer teardown()
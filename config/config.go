@@ -29,6 +29,9 @@ const (
 	HistoryDir    string = WorkRoot + "/" + HistoryTag    // History dir for excel/csv output
 	SpiderExt     string = ".pholcus.xml"                 // Dynamic rule extension (recommended)
 	SpiderExtOld  string = ".pholcus.html"                // Dynamic rule extension (legacy)
+	SpiderExtYAML string = ".pholcus.yaml"                // Declarative rule extension
+	SpiderExtYML  string = ".pholcus.yml"                 // Declarative rule extension (short)
+	SpiderExtJSON string = ".pholcus.json"                // Declarative rule extension (JSON)
 )
 
 // Config holds all runtime-configurable values, initialized with defaults.
@@ -48,6 +51,12 @@ type Config struct {
 	Kafka      KafkaConfig      `ini:"kafka"`
 	Log        LogConfig        `ini:"log"`
 	Run        RunConfig        `ini:"run"`
+	Download   DownloadConfig   `ini:"download"`
+	Chrome     ChromeConfig     `ini:"chrome"`
+	TLS        TLSConfig        `ini:"tls"`
+	Cookie     CookieConfig     `ini:"cookie"`
+	DNS        DNSConfig        `ini:"dns"`
+	Proxy      ProxyConfig      `ini:"proxy"`
 }
 
 type MgoConfig struct {
@@ -115,6 +124,57 @@ type RunConfig struct {
 	FailureInherit bool   `ini:"failure"`
 }
 
+// DownloadConfig holds downloader limits; sizes are in bytes, 0 means unlimited.
+type DownloadConfig struct {
+	MaxTextSize int64 `ini:"maxtextsize"`
+	MaxFileSize int64 `ini:"maxfilesize"`
+	Bandwidth   int64 `ini:"bandwidth"` // max download rate of all spiders in bytes/s, 0 means unlimited
+}
+
+// ChromeConfig holds the browser pool settings of the Chrome downloader.
+type ChromeConfig struct {
+	MaxTabs      int   `ini:"maxtabs"`      // max tabs open at once
+	Browsers     int   `ini:"browsers"`     // browser processes tabs are spread over
+	RecycleAfter int   `ini:"recycleafter"` // restart a browser after this many pages, 0 means never
+	TabTimeout   int64 `ini:"tabtimeout"`   // max lifetime of a tab in seconds
+	Isolate      bool  `ini:"isolate"`      // separate browser profile (cookies, storage) per spider
+}
+
+// TLSConfig holds the default HTTPS settings; file paths point to PEM files.
+type TLSConfig struct {
+	Insecure   bool   `ini:"insecure"`   // skip server certificate verification
+	RootCAs    string `ini:"rootcas"`    // extra trusted CA files, comma separated
+	ClientCert string `ini:"clientcert"` // client certificate for mutual TLS
+	ClientKey  string `ini:"clientkey"`  // private key of clientcert
+}
+
+// CookieConfig holds the settings of the per-spider cookie jars.
+type CookieConfig struct {
+	Persist bool `ini:"persist"` // keep cookies between runs, stored with the output type's history
+}
+
+// DNSConfig holds the host name resolution settings of the Surf downloader.
+type DNSConfig struct {
+	Servers string `ini:"servers"` // DNS servers (ip or ip:port), comma separated; empty uses the system resolver
+	MaxAge  int64  `ini:"maxage"`  // max seconds an answer is cached, also the cache time of system resolver answers
+	Hosts   string `ini:"hosts"`   // static overrides like /etc/hosts, comma separated host=ip pairs
+}
+
+// ProxyConfig holds the proxy sources merged into the pool besides proxylib.
+type ProxyConfig struct {
+	Watch    bool   `ini:"watch"`    // reload proxylib when the file changes
+	URLs     string `ini:"urls"`     // provider endpoints returning proxies as text or JSON, comma separated
+	Interval int64  `ini:"interval"` // seconds between fetches of the provider endpoints
+	Static   string `ini:"static"`   // fixed proxies, comma separated
+
+	Check       string `ini:"check"`       // how proxies are checked for being online: tcp, connect (HTTP CONNECT) or icmp
+	FileCheck   string `ini:"filecheck"`   // check of the proxylib proxies, empty uses check
+	URLCheck    string `ini:"urlcheck"`    // check of the provider proxies, empty uses check
+	StaticCheck string `ini:"staticcheck"` // check of the static proxies, empty uses check
+	Threads     int    `ini:"threads"`     // max concurrent proxy checks and speed tests
+	TestURL     string `ini:"testurl"`     // URL proxies are speed tested with and CONNECT to; empty tests with the crawled host
+}
+
 // defaultConf returns a Config populated with built-in defaults.
 func defaultConf() Config {
 	return Config{
@@ -160,6 +220,25 @@ func defaultConf() Config {
 			SuccessInherit: true,
 			FailureInherit: true,
 		},
+		Download: DownloadConfig{
+			MaxTextSize: 64 << 20,
+		},
+		Chrome: ChromeConfig{
+			MaxTabs:      4,
+			Browsers:     1,
+			RecycleAfter: 200,
+			TabTimeout:   120,
+			Isolate:      true,
+		},
+		DNS: DNSConfig{
+			MaxAge: 300,
+		},
+		Proxy: ProxyConfig{
+			Watch:    true,
+			Interval: 300,
+			Check:    "tcp",
+			Threads:  1000,
+		},
 	}
 }
 
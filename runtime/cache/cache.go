@@ -36,9 +36,12 @@ type Report struct {
 	Keyin      string
 	DataNum    uint64
 	FileNum    uint64
-	// DataSize   uint64
+	DataSize   uint64 // downloaded response body bytes, as received before content decoding
 	// FileSize uint64
-	Time time.Duration
+	Responses uint64 // downloaded responses
+	Banned    uint64 // responses recognized as ban/captcha pages
+	Rejected  uint64 // items failing their rule's schema
+	Time      time.Duration
 }
 
 var (
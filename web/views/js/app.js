@@ -111,6 +111,16 @@ ws.onmessage = function (m) {
             ;
             break;
 
+        // 代理池状态
+        case "proxies":
+            layer.open({
+                type: 1,
+                title: '代理池',
+                area: ['800px', '500px'],
+                content: proxyPoolHtml(data.hosts),
+            });
+            break;
+
         case "exit":
             layer.closeAll();
             selectMode(unset);
@@ -248,6 +258,13 @@ function pauseRecover() {
     });
 };
 
+// 查看代理池状态
+function proxyPool() {
+    ws.onsend({
+        'operate': 'proxies'
+    });
+};
+
 // ********************************* 打印log信息 ************************************** \\
 
 
@@ -187,25 +187,51 @@ var FailureInheritHtml = function (FailureInherit) {
 }
 
 var btnHtml = function (mode, status) {
+    var proxies = '<button type="button" class="btn btn-default" onclick="proxyPool()">Proxies</button>\
+            ';
     if (parseInt(mode) != offline) {
-        return '<button type="submit" id="btn-run" class="btn btn-primary" data-type="run">Run</button>';
+        return proxies + '<button type="submit" id="btn-run" class="btn btn-primary" data-type="run">Run</button>';
     }
     switch (status) {
         case _stopped:
-            return '<button type="button" id="btn-pause" class="btn btn-warning" onclick="pauseRecover()" disabled="disabled">Pause</button>\
+            return proxies + '<button type="button" id="btn-pause" class="btn btn-warning" onclick="pauseRecover()" disabled="disabled">Pause</button>\
             <button type="submit" id="btn-run" class="btn btn-primary" data-type="run">Run</button>';
         case _stop:
-            return '<button type="button" id="btn-pause" class="btn btn-warning" onclick="pauseRecover()" disabled="disabled">Pause</button>\
+            return proxies + '<button type="button" id="btn-pause" class="btn btn-warning" onclick="pauseRecover()" disabled="disabled">Pause</button>\
             <button type="submit" id="btn-run" class="btn btn-danger" data-type="stop" disabled="disabled">Stopping...</button>';
         case _run:
-            return '<button type="button" id="btn-pause" class="btn btn-warning" onclick="pauseRecover()" style="display:inline-block;" >Pause</button>\
+            return proxies + '<button type="button" id="btn-pause" class="btn btn-warning" onclick="pauseRecover()" style="display:inline-block;" >Pause</button>\
             <button type="submit" id="btn-run" class="btn btn-danger" data-type="stop">Stop</button>';
         case _pause:
-            return '<button type="button" id="btn-pause" class="btn btn-info" onclick="pauseRecover()" style="display:inline-block;" >Go on...</button>\
+            return proxies + '<button type="button" id="btn-pause" class="btn btn-info" onclick="pauseRecover()" style="display:inline-block;" >Go on...</button>\
             <button type="submit" id="btn-run" class="btn btn-danger" data-type="stop">Stop</button>';
     }
 }
 
+//代理池状态表格
+var proxyPoolHtml = function (hosts) {
+    if (!hosts || hosts.length == 0) {
+        return '<div style="padding:20px;">暂无代理使用记录</div>';
+    }
+    var now = new Date();
+    var html = '<table class="table table-condensed table-striped" style="margin:0;">\
+        <tr><th>Host</th><th>Proxy</th><th>Score</th><th>Latency</th><th>OK / Timeout / Fail / Ban</th><th>Cooldown</th></tr>';
+    for (var i in hosts) {
+        var h = hosts[i];
+        for (var j in h.Proxies) {
+            var p = h.Proxies[j];
+            var until = new Date(p.CoolUntil);
+            html += '<tr><td>' + h.Host + '</td><td>' + p.Proxy + '</td><td>' + p.Score.toFixed(2) + '</td><td>'
+                + Math.round(p.Latency / 1e6) + ' ms</td><td>' + p.Successes + ' / ' + p.Timeouts + ' / ' + p.Failures + ' / ' + p.Bans
+                + '</td><td>' + (until > now ? until.toLocaleTimeString() : '') + '</td></tr>';
+        }
+        for (var j in h.Evicted) {
+            html += '<tr class="danger"><td>' + h.Host + '</td><td>' + h.Evicted[j] + '</td><td colspan="4">evicted</td></tr>';
+        }
+    }
+    return html + '</table>';
+}
+
 var logBoxHtml = function (m) {
     if (m == client) {
         return '<div class="box log client">\
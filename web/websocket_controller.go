@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/andeya/pholcus/app"
+	"github.com/andeya/pholcus/app/scheduler"
 	"github.com/andeya/pholcus/app/spider"
 	"github.com/andeya/pholcus/common/util"
 	ws "github.com/andeya/pholcus/common/websocket"
@@ -218,6 +219,11 @@ func init() {
 		WSController.Write(sessID, map[string]interface{}{"operate": "pauseRecover"})
 	}
 
+	// Show the proxy pool state.
+	wsAPI["proxies"] = func(sessID string, req map[string]interface{}) {
+		WSController.Write(sessID, map[string]interface{}{"operate": "proxies", "hosts": scheduler.ProxyHealth()})
+	}
+
 	// Exit current mode.
 	wsAPI["exit"] = func(sessID string, req map[string]interface{}) {
 		app.LogicApp = app.LogicApp.ReInit(status.UNSET, 0, "")